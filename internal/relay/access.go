@@ -0,0 +1,78 @@
+package relay
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// accessRules restricts the requests forwarded to a hospital. A nil
+// *accessRules allows everything.
+type accessRules struct {
+	methods  map[string]bool // upper-case; nil allows any method
+	prefixes []string        // cleaned, without trailing slash; nil allows any path
+}
+
+// compileAccessRules normalizes a hospital's allowlists, or returns nil when
+// both are empty
+func compileAccessRules(methods, paths []string) *accessRules {
+	if len(methods) == 0 && len(paths) == 0 {
+		return nil
+	}
+
+	rules := &accessRules{}
+	if len(methods) > 0 {
+		rules.methods = make(map[string]bool, len(methods)+1)
+		for _, m := range methods {
+			rules.methods[strings.ToUpper(m)] = true
+		}
+		// HEAD is a body-less GET
+		if rules.methods[http.MethodGet] {
+			rules.methods[http.MethodHead] = true
+		}
+	}
+	for _, p := range paths {
+		rules.prefixes = append(rules.prefixes, cleanPathPrefix(p))
+	}
+	return rules
+}
+
+// cleanPathPrefix makes "/instances/", "/instances" and "instances" the same
+// prefix, "/instances"
+func cleanPathPrefix(p string) string {
+	return path.Clean("/" + p)
+}
+
+// allows reports whether a request may be forwarded. A prefix matches the
+// path itself and anything below it, but not siblings sharing its name:
+// "/instances" matches "/instances" and "/instances/1", not "/instancesX".
+func (a *accessRules) allows(method, urlPath string) bool {
+	if a == nil {
+		return true
+	}
+	if a.methods != nil && !a.methods[method] {
+		return false
+	}
+	if a.prefixes == nil {
+		return true
+	}
+
+	// Match on the cleaned path so "/instances/../admin" cannot slip through
+	p := path.Clean("/" + urlPath)
+	for _, prefix := range a.prefixes {
+		if prefix == "/" || p == prefix || strings.HasPrefix(p, prefix+"/") {
+			return true
+		}
+	}
+	return false
+}
+
+// allowsRequest reports whether the hospital's allowlists permit a request
+func (h *HospitalConfig) allowsRequest(method, urlPath string) bool {
+	rules := h.access
+	if rules == nil {
+		// Not compiled by the registry (e.g. built in code); do it now
+		rules = compileAccessRules(h.AllowedMethods, h.AllowedPaths)
+	}
+	return rules.allows(method, urlPath)
+}
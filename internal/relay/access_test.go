@@ -0,0 +1,39 @@
+package relay
+
+import "testing"
+
+func TestAccessRulesAllows(t *testing.T) {
+	tests := []struct {
+		name    string
+		methods []string
+		paths   []string
+		method  string
+		path    string
+		want    bool
+	}{
+		{"no rules", nil, nil, "DELETE", "/anything", true},
+		{"exact prefix", nil, []string{"/a"}, "GET", "/a", true},
+		{"below prefix", nil, []string{"/a"}, "GET", "/a/b", true},
+		{"sibling sharing name", nil, []string{"/a"}, "GET", "/ab", false},
+		{"trailing slash rule, bare path", nil, []string{"/a/"}, "GET", "/a", true},
+		{"trailing slash rule, sibling", nil, []string{"/a/"}, "GET", "/ab", false},
+		{"bare rule, trailing slash path", nil, []string{"/a"}, "GET", "/a/", true},
+		{"rule without leading slash", nil, []string{"a"}, "GET", "/a/1", true},
+		{"dot segments", nil, []string{"/a"}, "GET", "/a/../admin", false},
+		{"root prefix", nil, []string{"/"}, "GET", "/anything", true},
+		{"method allowed", []string{"get"}, nil, "GET", "/a", true},
+		{"head implied by get", []string{"GET"}, nil, "HEAD", "/a", true},
+		{"method mismatch", []string{"GET"}, nil, "POST", "/a", false},
+		{"method mismatch on allowed path", []string{"GET"}, []string{"/a"}, "DELETE", "/a/1", false},
+		{"method allowed on denied path", []string{"GET"}, []string{"/a"}, "GET", "/b", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			rules := compileAccessRules(tt.methods, tt.paths)
+			if got := rules.allows(tt.method, tt.path); got != tt.want {
+				t.Errorf("allows(%s %s) with methods %q, paths %q = %v, want %v",
+					tt.method, tt.path, tt.methods, tt.paths, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package relay
+
+import (
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// requestIDHeader carries the relay's request ID back to the client
+const requestIDHeader = "X-Relay-Request-Id"
+
+// outcomeOK marks a request that was forwarded successfully in the access log
+const outcomeOK = "ok"
+
+// startRequestLog assigns a request ID to r, echoes it to the client along
+// with the relay version and returns a logger that tags every message with it
+func startRequestLog(logger *slog.Logger, w http.ResponseWriter) (string, *slog.Logger) {
+	requestID := uuid.NewString()
+	w.Header().Set(requestIDHeader, requestID)
+	w.Header().Set(versionHeader, Version)
+	return requestID, requestLogger(logger, requestID)
+}
+
+// logAccess writes the single access-log line for a finished request.
+// outcome is outcomeOK or the failure reason used in the metrics.
+func logAccess(logger *slog.Logger, r *http.Request, hospitalCode string, rec *responseRecorder, start time.Time, outcome string) {
+	logger.Info("access",
+		"hospital_code", hospitalCode,
+		"method", r.Method,
+		"path", r.URL.Path,
+		"status", rec.status,
+		"bytes", rec.bytes,
+		"duration", time.Since(start).String(),
+		"outcome", outcome)
+}
+
+// logSlowRequest warns about and counts a forwarded request that took longer
+// than threshold. A zero threshold disables the check.
+func logSlowRequest(logger *slog.Logger, mode, hospitalCode, path string, elapsed, threshold time.Duration) {
+	if threshold <= 0 || elapsed < threshold {
+		return
+	}
+	slowRequests.WithLabelValues(mode, hospitalCode).Inc()
+	logger.Warn("Slow request",
+		"hospital_code", hospitalCode,
+		"path", path,
+		"duration", elapsed.String(),
+		"threshold", threshold.String())
+}
@@ -0,0 +1,237 @@
+package relay
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/acme"
+	"golang.org/x/crypto/acme/autocert"
+)
+
+const (
+	// acmeFallbackWait is how long a handshake waits for autocert before it
+	// is served the fallback certificate; issuance continues in the background
+	acmeFallbackWait = 5 * time.Second
+
+	// Retry delays for hosts whose issuance failed, doubling in between
+	acmeRetryMin = time.Minute
+	acmeRetryMax = 30 * time.Minute
+
+	// maxFallbackHosts bounds the hosts tracked while on the fallback
+	// certificate; SNI names are chosen by clients
+	maxFallbackHosts = 1000
+
+	// fallbackHostExpiry is how long after its retry time a failed host is
+	// forgotten if it does not connect again
+	fallbackHostExpiry = acmeRetryMax
+)
+
+// acmeFallback serves autocert certificates, and a fallback certificate for
+// hosts whose certificate is not available yet, e.g. while Let's Encrypt is
+// unreachable or rate limiting. Such hosts keep getting the fallback without
+// waiting on ACME, and issuance is retried in the background with backoff
+// when they connect again, until the real certificate can be served.
+type acmeFallback struct {
+	manager  *autocert.Manager
+	fallback *tls.Certificate
+	logger   *slog.Logger
+
+	mu    sync.Mutex
+	hosts map[string]*fallbackHost // hosts currently served the fallback
+}
+
+// fallbackHost is the issuance state of a host served the fallback certificate
+type fallbackHost struct {
+	issuing  bool      // an issuance attempt is running
+	served   bool      // the fallback has been served for the host
+	failures int       // failed attempts in a row
+	nextTry  time.Time // no new attempt before this
+}
+
+// newACMEFallback loads the configured fallback certificate, or creates a
+// self-signed one for the apex domains
+func newACMEFallback(manager *autocert.Manager, cfg *TLSConfig, domains []string, logger *slog.Logger) (*acmeFallback, error) {
+	var cert tls.Certificate
+	if cfg.FallbackCertFile != "" {
+		loaded, err := tls.LoadX509KeyPair(cfg.FallbackCertFile, cfg.FallbackKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load fallback certificate: %w", err)
+		}
+		cert = loaded
+		logger.Info("Fallback TLS certificate loaded",
+			"cert", cfg.FallbackCertFile,
+			"subject", cert.Leaf.Subject.String(),
+			"not_after", cert.Leaf.NotAfter)
+	} else {
+		generated, err := selfSignedCertificate(domains)
+		if err != nil {
+			return nil, fmt.Errorf("failed to create self-signed fallback certificate: %w", err)
+		}
+		cert = generated
+		logger.Info("Self-signed fallback TLS certificate created",
+			"dns_names", cert.Leaf.DNSNames,
+			"not_after", cert.Leaf.NotAfter)
+	}
+
+	return &acmeFallback{
+		manager:  manager,
+		fallback: &cert,
+		logger:   logger,
+		hosts:    make(map[string]*fallbackHost),
+	}, nil
+}
+
+// getCertificate implements tls.Config.GetCertificate
+func (f *acmeFallback) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	// TLS-ALPN-01 challenges are answered by autocert itself
+	if slices.Contains(hello.SupportedProtos, acme.ALPNProto) {
+		return f.manager.GetCertificate(hello)
+	}
+
+	// Only names the relay would get a certificate for are tracked or served
+	// the fallback; autocert refuses the others the same way
+	host := strings.ToLower(strings.TrimSuffix(hello.ServerName, "."))
+	if host == "" {
+		return nil, fmt.Errorf("acme/autocert: missing server name")
+	}
+	if f.manager.HostPolicy != nil {
+		if err := f.manager.HostPolicy(hello.Context(), host); err != nil {
+			return nil, err
+		}
+	}
+
+	f.mu.Lock()
+	h, onFallback := f.hosts[host]
+	if onFallback && (h.issuing || time.Now().Before(h.nextTry)) {
+		f.mu.Unlock()
+		return f.fallback, nil
+	}
+	if !onFallback {
+		if len(f.hosts) >= maxFallbackHosts {
+			f.expireHosts()
+		}
+		if len(f.hosts) >= maxFallbackHosts {
+			f.mu.Unlock()
+			return f.manager.GetCertificate(hello)
+		}
+		h = &fallbackHost{}
+		f.hosts[host] = h
+	}
+	h.issuing = true
+	f.mu.Unlock()
+
+	type result struct {
+		cert *tls.Certificate
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		cert, err := f.manager.GetCertificate(hello)
+		f.finish(host, err)
+		done <- result{cert, err}
+	}()
+
+	timer := time.NewTimer(acmeFallbackWait)
+	defer timer.Stop()
+	select {
+	case r := <-done:
+		if r.err != nil {
+			return f.fallback, nil
+		}
+		return r.cert, nil
+	case <-timer.C:
+		f.mu.Lock()
+		if h, ok := f.hosts[host]; ok {
+			h.served = true
+		}
+		f.mu.Unlock()
+		f.logger.Warn("ACME certificate not ready, serving fallback certificate while issuance continues", "host", host)
+		return f.fallback, nil
+	}
+}
+
+// expireHosts forgets failed hosts that have not connected again since
+// their retry time plus fallbackHostExpiry; f.mu must be held
+func (f *acmeFallback) expireHosts() {
+	cutoff := time.Now().Add(-fallbackHostExpiry)
+	for host, h := range f.hosts {
+		if !h.issuing && h.nextTry.Before(cutoff) {
+			delete(f.hosts, host)
+		}
+	}
+}
+
+// finish records the outcome of an issuance attempt for host
+func (f *acmeFallback) finish(host string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	h := f.hosts[host]
+	if err == nil {
+		if h.served {
+			f.logger.Info("ACME certificate obtained, serving it instead of the fallback certificate", "host", host)
+		}
+		delete(f.hosts, host)
+		return
+	}
+
+	retry := acmeRetryMin << min(h.failures, 5)
+	h.issuing = false
+	h.served = true
+	h.failures++
+	h.nextTry = time.Now().Add(min(retry, acmeRetryMax))
+	f.logger.Warn("ACME certificate unavailable, serving fallback certificate",
+		"host", host,
+		"failures", h.failures,
+		"retry_in", min(retry, acmeRetryMax).String(),
+		"error", err)
+}
+
+// selfSignedCertificate creates a certificate for domains and their
+// subdomains, valid for a year
+func selfSignedCertificate(domains []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	var names []string
+	for _, d := range domains {
+		names = append(names, d, "*."+d)
+	}
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: domains[0], Organization: []string{"gordion-relay fallback"}},
+		DNSNames:              names,
+		NotBefore:             now.Add(-time.Hour),
+		NotAfter:              now.AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	leaf, err := x509.ParseCertificate(der)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key, Leaf: leaf}, nil
+}
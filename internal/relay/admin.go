@@ -0,0 +1,156 @@
+package relay
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/json"
+	"log/slog"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/minasoft-technology/gordion-relay/internal/security/timetoken"
+)
+
+// maxAdminTokenDuration caps the lifetime of tokens issued via /admin/tokens
+const maxAdminTokenDuration = 7 * 24 * time.Hour
+
+// tokenRequest is the body of POST /admin/tokens
+type tokenRequest struct {
+	HospitalCode string   `json:"hospital_code"`
+	Path         string   `json:"path"`
+	Duration     Duration `json:"duration"`     // e.g. "15m"
+	IP           string   `json:"ip,omitempty"` // Bind the token to this client's network
+}
+
+// tokenResponse is returned by POST /admin/tokens
+type tokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+	URL       string    `json:"url"`
+}
+
+// secureTokenEqual compares two secrets in constant time. Both are hashed
+// first so the comparison does not leak the expected token's length either.
+func secureTokenEqual(a, b string) bool {
+	ha := sha256.Sum256([]byte(a))
+	hb := sha256.Sum256([]byte(b))
+	return subtle.ConstantTimeCompare(ha[:], hb[:]) == 1
+}
+
+// adminAuthorized reports whether r carries "Authorization: Bearer <admin_token>"
+func adminAuthorized(r *http.Request, adminToken string) bool {
+	provided, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return ok && secureTokenEqual(provided, adminToken)
+}
+
+// adminTokenHandler issues download tokens signed with a hospital's key.
+// Callers authenticate with "Authorization: Bearer <admin_token>".
+func adminTokenHandler(cfg *Config, hospitals *hospitalRegistry, logger *slog.Logger) http.HandlerFunc {
+	adminToken := cfg.AdminToken
+	scheme := timetoken.TokenScheme(cfg.TokenScheme)
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !adminAuthorized(r, adminToken) {
+			logger.Warn("Rejected admin token request", "remote_addr", cfg.ClientIP(r))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		var req tokenRequest
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 4096)).Decode(&req); err != nil {
+			http.Error(w, "Invalid request body: "+err.Error(), http.StatusBadRequest)
+			return
+		}
+		duration := req.Duration.ToDuration()
+		switch {
+		case req.HospitalCode == "":
+			http.Error(w, "hospital_code is required", http.StatusBadRequest)
+			return
+		case !strings.HasPrefix(req.Path, "/"):
+			http.Error(w, "path must start with /", http.StatusBadRequest)
+			return
+		case duration <= 0 || duration > maxAdminTokenDuration:
+			http.Error(w, "duration must be between 1s and "+maxAdminTokenDuration.String(), http.StatusBadRequest)
+			return
+		case req.IP != "" && net.ParseIP(req.IP) == nil:
+			http.Error(w, "ip is not a valid IP address", http.StatusBadRequest)
+			return
+		}
+
+		hospital, ok := hospitals.byCode(req.HospitalCode)
+		if !ok {
+			http.Error(w, "Unknown hospital", http.StatusNotFound)
+			return
+		}
+
+		issued := time.Now()
+		token, err := timetoken.GenerateTokenForIP(scheme, hospital.downloadKey(), req.Path, req.IP, duration)
+		if err != nil {
+			logger.Error("Failed to generate token", "hospital_code", hospital.Code, "error", err)
+			http.Error(w, "Failed to generate token", http.StatusInternalServerError)
+			return
+		}
+
+		logger.Info("Issued download token via admin API",
+			"hospital_code", hospital.Code,
+			"path", req.Path,
+			"duration", duration.String(),
+			"ip", req.IP,
+			"remote_addr", cfg.ClientIP(r))
+
+		resp := tokenResponse{
+			Token:     token,
+			ExpiresAt: time.Unix(issued.Unix()+int64(duration.Seconds()), 0).UTC(),
+			URL:       "https://" + hospital.Subdomain + req.Path + "?token=" + token,
+		}
+		if err := writeJSON(w, http.StatusOK, resp); err != nil {
+			logger.Debug("Failed to write token response", "error", err)
+		}
+	}
+}
+
+// adminDisconnectHandler serves POST /admin/disconnect?hospital=CODE.
+// disconnect closes the hospital's tunnel and reports whether one was connected.
+func adminDisconnectHandler(cfg *Config, disconnect func(hospitalCode string) bool, logger *slog.Logger) http.HandlerFunc {
+	adminToken := cfg.AdminToken
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !adminAuthorized(r, adminToken) {
+			logger.Warn("Rejected admin disconnect request", "remote_addr", cfg.ClientIP(r))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		hospitalCode := r.URL.Query().Get("hospital")
+		if hospitalCode == "" {
+			http.Error(w, "hospital is required", http.StatusBadRequest)
+			return
+		}
+
+		disconnected := disconnect(hospitalCode)
+		logger.Warn("Admin disconnect requested",
+			"hospital_code", hospitalCode,
+			"disconnected", disconnected,
+			"remote_addr", cfg.ClientIP(r))
+
+		if !disconnected {
+			http.Error(w, "Hospital not connected", http.StatusNotFound)
+			return
+		}
+		if err := writeJSON(w, http.StatusOK, map[string]string{"hospital": hospitalCode, "status": "disconnected"}); err != nil {
+			logger.Debug("Failed to write disconnect response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,29 @@
+package relay
+
+import "testing"
+
+func TestSecureTokenEqual(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b string
+		want bool
+	}{
+		{"equal", "admintoken-1234567890abcdef", "admintoken-1234567890abcdef", true},
+		{"unequal", "admintoken-1234567890abcdef", "admintoken-1234567890abcdeX", false},
+		{"prefix", "admintoken", "admintoken-1234567890abcdef", false},
+		{"different lengths", "short", "a-much-longer-token", false},
+		{"both empty", "", "", true},
+		{"one empty", "", "admintoken-1234567890abcdef", false},
+		{"case differs", "AdminToken", "admintoken", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := secureTokenEqual(tt.a, tt.b); got != tt.want {
+				t.Errorf("secureTokenEqual(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+			}
+			if got := secureTokenEqual(tt.b, tt.a); got != tt.want {
+				t.Errorf("secureTokenEqual(%q, %q) = %v, want %v", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Rate limit backends (RateLimitConfig.Backend)
+const (
+	RateLimitBackendMemory = "memory"
+	RateLimitBackendRedis  = "redis"
+)
+
+// AttemptStore tracks failed authentication attempts per client. The
+// in-memory store is per process; the Redis store shares blocks across
+// relay replicas.
+type AttemptStore interface {
+	// Record counts a failed attempt and reports the attempts in the current
+	// window and whether the client is now blocked
+	Record(ctx context.Context, client string) (attempts int, blocked bool, err error)
+	// IsBlocked reports whether the client is currently blocked
+	IsBlocked(ctx context.Context, client string) (bool, error)
+	// Clear forgets the client's failures, e.g. after a successful login
+	Clear(ctx context.Context, client string) error
+}
+
+// newAttemptStore creates the store selected by cfg.Backend
+func newAttemptStore(cfg RateLimitConfig) (AttemptStore, error) {
+	switch cfg.Backend {
+	case RateLimitBackendRedis:
+		return newRedisAttemptStore(cfg)
+	default:
+		return newMemoryAttemptStore(cfg), nil
+	}
+}
+
+// authAttempts tracks failed authentication attempts for rate limiting
+type authAttempts struct {
+	Count        int
+	LastAttempt  time.Time
+	BlockedUntil time.Time
+}
+
+// memoryAttemptStore keeps attempts in a process-local map
+type memoryAttemptStore struct {
+	limits RateLimitConfig
+
+	mu       sync.RWMutex
+	attempts map[string]*authAttempts
+}
+
+func newMemoryAttemptStore(limits RateLimitConfig) *memoryAttemptStore {
+	return &memoryAttemptStore{
+		limits:   limits,
+		attempts: make(map[string]*authAttempts),
+	}
+}
+
+func (m *memoryAttemptStore) Record(_ context.Context, client string) (int, bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	attempts, exists := m.attempts[client]
+	if !exists {
+		attempts = &authAttempts{}
+		m.attempts[client] = attempts
+	}
+
+	now := time.Now()
+
+	// Start a new window if the previous failure is old enough
+	if now.Sub(attempts.LastAttempt) > m.limits.WindowDuration.ToDuration() {
+		attempts.Count = 0
+	}
+
+	attempts.Count++
+	attempts.LastAttempt = now
+
+	if attempts.Count >= m.limits.MaxAttempts {
+		attempts.BlockedUntil = now.Add(m.limits.BlockDuration.ToDuration())
+		return attempts.Count, true, nil
+	}
+	return attempts.Count, false, nil
+}
+
+func (m *memoryAttemptStore) IsBlocked(_ context.Context, client string) (bool, error) {
+	m.mu.RLock()
+	attempts, exists := m.attempts[client]
+	m.mu.RUnlock()
+	if !exists {
+		return false, nil
+	}
+	return time.Now().Before(attempts.BlockedUntil), nil
+}
+
+func (m *memoryAttemptStore) Clear(_ context.Context, client string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	delete(m.attempts, client)
+	return nil
+}
+
+// cleanup periodically drops clients that have not failed for a day
+func (m *memoryAttemptStore) cleanup(ctx context.Context) {
+	ticker := time.NewTicker(1 * time.Hour)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.mu.Lock()
+			now := time.Now()
+			for client, attempts := range m.attempts {
+				if now.Sub(attempts.LastAttempt) > 24*time.Hour {
+					delete(m.attempts, client)
+				}
+			}
+			m.mu.Unlock()
+		}
+	}
+}
+
+// redisKeyPrefix namespaces rate-limit keys in a shared Redis
+const redisKeyPrefix = "gordion-relay:auth:"
+
+// redisAttemptStore keeps attempts in Redis so every replica sees them.
+// The failure counter expires WindowDuration after the last failure and a
+// separate key marks the client as blocked for BlockDuration.
+type redisAttemptStore struct {
+	limits RateLimitConfig
+	client *redis.Client
+}
+
+func newRedisAttemptStore(limits RateLimitConfig) (*redisAttemptStore, error) {
+	opts, err := redis.ParseURL(limits.RedisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate_limit.redis_url: %w", err)
+	}
+	return &redisAttemptStore{limits: limits, client: redis.NewClient(opts)}, nil
+}
+
+func (r *redisAttemptStore) countKey(client string) string {
+	return redisKeyPrefix + client + ":count"
+}
+
+func (r *redisAttemptStore) blockedKey(client string) string {
+	return redisKeyPrefix + client + ":blocked"
+}
+
+func (r *redisAttemptStore) Record(ctx context.Context, client string) (int, bool, error) {
+	var incr *redis.IntCmd
+	_, err := r.client.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		incr = pipe.Incr(ctx, r.countKey(client))
+		pipe.PExpire(ctx, r.countKey(client), r.limits.WindowDuration.ToDuration())
+		return nil
+	})
+	if err != nil {
+		return 0, false, fmt.Errorf("failed to record attempt: %w", err)
+	}
+
+	count := int(incr.Val())
+	if count < r.limits.MaxAttempts {
+		return count, false, nil
+	}
+	if err := r.client.Set(ctx, r.blockedKey(client), count, r.limits.BlockDuration.ToDuration()).Err(); err != nil {
+		return count, false, fmt.Errorf("failed to block client: %w", err)
+	}
+	return count, true, nil
+}
+
+func (r *redisAttemptStore) IsBlocked(ctx context.Context, client string) (bool, error) {
+	n, err := r.client.Exists(ctx, r.blockedKey(client)).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check block: %w", err)
+	}
+	return n > 0, nil
+}
+
+func (r *redisAttemptStore) Clear(ctx context.Context, client string) error {
+	if err := r.client.Del(ctx, r.countKey(client), r.blockedKey(client)).Err(); err != nil {
+		return fmt.Errorf("failed to clear attempts: %w", err)
+	}
+	return nil
+}
+
+// Close releases the Redis connection pool
+func (r *redisAttemptStore) Close() error {
+	return r.client.Close()
+}
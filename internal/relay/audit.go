@@ -0,0 +1,102 @@
+package relay
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"time"
+
+	"github.com/minasoft-technology/gordion-relay/internal/security/timetoken"
+)
+
+// AuditConfig holds the download audit trail settings
+type AuditConfig struct {
+	Output string `json:"output,omitempty"` // "stdout" (default) or a file path; entries are JSON lines
+}
+
+// auditLogger records every download token check, separately from the
+// operational logs. A nil *auditLogger records nothing.
+type auditLogger struct {
+	logger *slog.Logger
+	closer io.Closer
+}
+
+// newAuditLogger opens the configured audit sink, or returns nil when
+// auditing is disabled
+func newAuditLogger(cfg *AuditConfig) (*auditLogger, error) {
+	if cfg == nil {
+		return nil, nil
+	}
+	if cfg.Output == "" || cfg.Output == "stdout" {
+		return &auditLogger{logger: slog.New(slog.NewJSONHandler(os.Stdout, nil))}, nil
+	}
+
+	f, err := os.OpenFile(cfg.Output, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open audit log: %w", err)
+	}
+	return &auditLogger{logger: slog.New(slog.NewJSONHandler(f, nil)), closer: f}, nil
+}
+
+// Close releases the audit file, if any
+func (a *auditLogger) Close() error {
+	if a == nil || a.closer == nil {
+		return nil
+	}
+	return a.closer.Close()
+}
+
+// tokenValidation records the outcome of a download token check. payload is
+// nil when the token could not be decoded; err is nil on success.
+func (a *auditLogger) tokenValidation(requestID string, hospital *HospitalConfig, requestedPath, clientIP string, payload *timetoken.TokenPayload, err error) {
+	if a == nil {
+		return
+	}
+	attrs := []any{
+		"event", "token_validation",
+		"request_id", requestID,
+		"hospital", hospital.Code,
+		"hospital_id", hospital.HospitalID,
+		"path", requestedPath,
+		"client_ip", clientIP,
+	}
+	if payload != nil {
+		attrs = append(attrs,
+			"jti", payload.Jti,
+			"token_path", payload.Path,
+			"token_expires", time.Unix(payload.Exp, 0).UTC())
+	}
+	if err != nil {
+		attrs = append(attrs, "result", "denied", "reason", tokenFailureReason(err), "error", err.Error())
+	} else {
+		attrs = append(attrs, "result", "allowed")
+	}
+	a.logger.Info("audit", attrs...)
+}
+
+// errTokenMissing stands in for a request that carried no token
+var errTokenMissing = errors.New("missing token")
+
+// tokenFailureReason maps a validation error to a stable audit reason
+func tokenFailureReason(err error) string {
+	switch {
+	case errors.Is(err, errTokenMissing):
+		return "missing"
+	case errors.Is(err, timetoken.ErrTokenExpired):
+		return "expired"
+	case errors.Is(err, timetoken.ErrTokenNotYetValid):
+		return "not_yet_valid"
+	case errors.Is(err, timetoken.ErrTokenPathMismatch):
+		return "path_mismatch"
+	case errors.Is(err, timetoken.ErrTokenIPMismatch):
+		return "ip_mismatch"
+	case errors.Is(err, timetoken.ErrTokenReplayed):
+		return "replayed"
+	case errors.Is(err, timetoken.ErrTokenInvalid):
+		return "invalid"
+	default:
+		return "error"
+	}
+}
@@ -0,0 +1,198 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// CircuitBreakerConfig controls per-hospital circuit breaking. When a
+// hospital's recent requests fail too often, its requests are answered with
+// 503 right away until a probe request succeeds.
+type CircuitBreakerConfig struct {
+	FailureRatio float64  `json:"failure_ratio"` // Failed share of requests in a window that opens the breaker (default: 0.5)
+	MinRequests  int      `json:"min_requests"`  // Requests a window needs before the ratio counts (default: 10)
+	Window       Duration `json:"window"`        // Length of the counting window (default: 30s)
+	OpenDuration Duration `json:"open_duration"` // How long the breaker stays open before a probe (default: 30s)
+}
+
+// Circuit breaker states as shown in /status
+const (
+	breakerClosed   = "closed"
+	breakerOpen     = "open"
+	breakerHalfOpen = "half_open"
+)
+
+// circuitBreaker tracks one hospital. Closed counts outcomes per window;
+// open rejects everything until OpenDuration has passed; half-open lets a
+// single probe through, whose result closes or reopens the breaker.
+type circuitBreaker struct {
+	state       string
+	windowStart time.Time
+	requests    int
+	failures    int
+	openedAt    time.Time
+	probeAt     time.Time // zero when no probe is in flight
+}
+
+// circuitBreakers holds the breakers of all hospitals. A nil
+// *circuitBreakers lets every request through.
+type circuitBreakers struct {
+	cfg    CircuitBreakerConfig
+	logger *slog.Logger
+
+	mu       sync.Mutex
+	breakers map[string]*circuitBreaker // hospital code -> breaker
+}
+
+// newCircuitBreakers returns nil when circuit breaking is disabled
+func newCircuitBreakers(cfg *CircuitBreakerConfig, logger *slog.Logger) *circuitBreakers {
+	if cfg == nil {
+		return nil
+	}
+	return &circuitBreakers{
+		cfg:      *cfg,
+		logger:   logger,
+		breakers: make(map[string]*circuitBreaker),
+	}
+}
+
+// get returns the hospital's breaker, creating a closed one; callers hold mu
+func (c *circuitBreakers) get(hospitalCode string, now time.Time) *circuitBreaker {
+	b, ok := c.breakers[hospitalCode]
+	if !ok {
+		b = &circuitBreaker{state: breakerClosed, windowStart: now}
+		c.breakers[hospitalCode] = b
+	}
+	return b
+}
+
+// allow reports whether a request for the hospital may be forwarded. When it
+// may not, retryAfter is the time until the next probe. probe is set for the
+// request that tests a half-open breaker; pass it on to record.
+func (c *circuitBreakers) allow(hospitalCode string) (probe bool, retryAfter time.Duration, ok bool) {
+	if c == nil {
+		return false, 0, true
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	b := c.get(hospitalCode, now)
+	openFor := c.cfg.OpenDuration.ToDuration()
+	switch b.state {
+	case breakerOpen:
+		if wait := openFor - now.Sub(b.openedAt); wait > 0 {
+			return false, wait, false
+		}
+		b.state = breakerHalfOpen
+		c.logger.Info("Circuit breaker half-open, probing hospital", "hospital_code", hospitalCode)
+		fallthrough
+	case breakerHalfOpen:
+		// A probe that never reported back does not block recovery forever
+		if !b.probeAt.IsZero() && now.Sub(b.probeAt) < openFor {
+			return false, openFor - now.Sub(b.probeAt), false
+		}
+		b.probeAt = now
+		return true, 0, true
+	default:
+		return false, 0, true
+	}
+}
+
+// abandon gives up a request's probe without an outcome, for requests turned
+// away before they were forwarded, so the next request can probe instead
+func (c *circuitBreakers) abandon(hospitalCode string, probe bool) {
+	if c == nil || !probe {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if b := c.get(hospitalCode, time.Now()); b.state == breakerHalfOpen {
+		b.probeAt = time.Time{}
+	}
+}
+
+// record counts the outcome of a forwarded request and moves the breaker on
+func (c *circuitBreakers) record(hospitalCode string, probe, failed bool) {
+	if c == nil {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	b := c.get(hospitalCode, now)
+	switch b.state {
+	case breakerHalfOpen:
+		if !probe {
+			return // started before the breaker opened
+		}
+		b.probeAt = time.Time{}
+		if failed {
+			b.state, b.openedAt = breakerOpen, now
+			c.logger.Warn("Circuit breaker probe failed, reopening", "hospital_code", hospitalCode)
+			return
+		}
+		b.state, b.windowStart, b.requests, b.failures = breakerClosed, now, 0, 0
+		c.logger.Info("Circuit breaker closed", "hospital_code", hospitalCode)
+	case breakerClosed:
+		if now.Sub(b.windowStart) >= c.cfg.Window.ToDuration() {
+			b.windowStart, b.requests, b.failures = now, 0, 0
+		}
+		b.requests++
+		if !failed {
+			return
+		}
+		b.failures++
+		if b.requests >= c.cfg.MinRequests && float64(b.failures)/float64(b.requests) >= c.cfg.FailureRatio {
+			b.state, b.openedAt = breakerOpen, now
+			c.logger.Warn("Circuit breaker opened",
+				"hospital_code", hospitalCode,
+				"failures", b.failures,
+				"requests", b.requests,
+				"open_duration", c.cfg.OpenDuration.ToDuration().String())
+		}
+	}
+}
+
+// state returns the hospital's breaker state for /status, "" when disabled
+func (c *circuitBreakers) state(hospitalCode string) string {
+	if c == nil {
+		return ""
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if b, ok := c.breakers[hospitalCode]; ok {
+		return b.state
+	}
+	return breakerClosed
+}
+
+// breakerFailure reports whether a forwarding error counts against the
+// hospital. Viewers leaving, oversized request bodies and requests that
+// never left the agent's queue or found no room on an edge are not the
+// hospital's fault.
+func breakerFailure(ctx context.Context, err error) bool {
+	if err == nil || ctx.Err() != nil {
+		return false
+	}
+	switch {
+	case errors.Is(err, errRequestTooLarge), errors.Is(err, context.Canceled),
+		errors.Is(err, errQueueFull), errors.Is(err, errQueueTimeout), errors.Is(err, errEdgeBusy):
+		return false
+	}
+	return true
+}
+
+// setCircuitRetryAfter sets Retry-After to the time until the next probe,
+// in whole seconds rounded up
+func setCircuitRetryAfter(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(max(1, int(math.Ceil(retryAfter.Seconds())))))
+}
@@ -0,0 +1,117 @@
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"log/slog"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+// certReloadInterval is how often certificate files are checked for changes
+const certReloadInterval = time.Minute
+
+// certReloader serves a file-based certificate and picks up renewals without
+// a restart: the files are polled and, when either changed, the pair is
+// loaded and swapped in. A pair that fails to load keeps the old certificate.
+type certReloader struct {
+	certFile string
+	keyFile  string
+	logger   *slog.Logger
+
+	cert atomic.Pointer[tls.Certificate]
+
+	// Only touched by the polling goroutine after construction
+	loaded fileStamp // files of the served certificate
+	failed fileStamp // files of the last pair that failed to load
+}
+
+// fileStamp identifies a version of the certificate and key files
+type fileStamp struct {
+	certMod, keyMod   time.Time
+	certSize, keySize int64
+}
+
+// newCertReloader loads the certificate pair, failing if it cannot be used
+func newCertReloader(certFile, keyFile string, logger *slog.Logger) (*certReloader, error) {
+	c := &certReloader{certFile: certFile, keyFile: keyFile, logger: logger}
+	stamp, err := c.stamp()
+	if err != nil {
+		return nil, err
+	}
+	cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load TLS certificate: %w", err)
+	}
+	c.cert.Store(&cert)
+	c.loaded = stamp
+	return c, nil
+}
+
+// getCertificate implements tls.Config.GetCertificate
+func (c *certReloader) getCertificate(*tls.ClientHelloInfo) (*tls.Certificate, error) {
+	return c.cert.Load(), nil
+}
+
+// stamp stats both files
+func (c *certReloader) stamp() (fileStamp, error) {
+	certInfo, err := os.Stat(c.certFile)
+	if err != nil {
+		return fileStamp{}, fmt.Errorf("failed to stat certificate: %w", err)
+	}
+	keyInfo, err := os.Stat(c.keyFile)
+	if err != nil {
+		return fileStamp{}, fmt.Errorf("failed to stat key: %w", err)
+	}
+	return fileStamp{
+		certMod:  certInfo.ModTime(),
+		keyMod:   keyInfo.ModTime(),
+		certSize: certInfo.Size(),
+		keySize:  keyInfo.Size(),
+	}, nil
+}
+
+// run polls the files every interval until ctx is done
+func (c *certReloader) run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			c.reload()
+		}
+	}
+}
+
+// reload swaps in the certificate pair if the files changed since the last
+// load. A pair that fails to load is reported once and retried when the
+// files change again, e.g. after the key has been written too.
+func (c *certReloader) reload() {
+	stamp, err := c.stamp()
+	if err != nil {
+		c.logger.Error("Failed to check TLS certificate, keeping current certificate", "error", err)
+		return
+	}
+	if stamp == c.loaded || stamp == c.failed {
+		return
+	}
+	cert, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		c.failed = stamp
+		c.logger.Error("Failed to reload TLS certificate, keeping current certificate",
+			"cert", c.certFile,
+			"error", err)
+		return
+	}
+	c.cert.Store(&cert)
+	c.loaded, c.failed = stamp, fileStamp{}
+	c.logger.Info("TLS certificate reloaded",
+		"cert", c.certFile,
+		"subject", cert.Leaf.Subject.String(),
+		"not_after", cert.Leaf.NotAfter)
+}
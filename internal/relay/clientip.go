@@ -0,0 +1,65 @@
+package relay
+
+import (
+	"net"
+	"net/http"
+	"strings"
+)
+
+// ClientIP returns the originating client IP for r.
+//
+// X-Forwarded-For is only honored when the direct peer is a trusted proxy; the
+// client is then the rightmost entry that is not itself a trusted proxy. If the
+// header is absent or malformed the direct peer address is used, so an
+// untrusted client cannot spoof its address.
+func (c *Config) ClientIP(r *http.Request) string {
+	peer := parseHostIP(r.RemoteAddr)
+	if peer == nil {
+		return r.RemoteAddr
+	}
+	if !ipInNets(peer, c.trustedNets) {
+		return peer.String()
+	}
+
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+
+	for i := len(hops) - 1; i >= 0; i-- {
+		ip := net.ParseIP(strings.TrimSpace(hops[i]))
+		if ip == nil {
+			break
+		}
+		if !ipInNets(ip, c.trustedNets) {
+			return ip.String()
+		}
+	}
+
+	return peer.String()
+}
+
+// forwardedFor returns the X-Forwarded-For chain and X-Real-IP to send to the
+// hospital backend: the chain r arrived with plus the relay-observed peer,
+// and the client IP as resolved by ClientIP.
+func (c *Config) forwardedFor(r *http.Request) (chain, realIP string) {
+	var hops []string
+	for _, header := range r.Header.Values("X-Forwarded-For") {
+		for _, hop := range strings.Split(header, ",") {
+			if hop = strings.TrimSpace(hop); hop != "" {
+				hops = append(hops, hop)
+			}
+		}
+	}
+
+	peer := r.RemoteAddr
+	if ip := parseHostIP(r.RemoteAddr); ip != nil {
+		peer = ip.String()
+	}
+	// Don't repeat the peer if it is already the last hop
+	if len(hops) == 0 || hops[len(hops)-1] != peer {
+		hops = append(hops, peer)
+	}
+
+	return strings.Join(hops, ", "), c.ClientIP(r)
+}
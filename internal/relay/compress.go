@@ -0,0 +1,161 @@
+package relay
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+// Content codings the relay can apply to responses
+const (
+	encodingBrotli = "br"
+	encodingGzip   = "gzip"
+)
+
+// defaultCompressTypes are compressed when compression.content_types is unset.
+// Pixel data (application/dicom) is already compact and is left alone.
+var defaultCompressTypes = []string{
+	"application/dicom+json",
+	"application/dicom+xml",
+	"application/json",
+	"application/javascript",
+	"application/xml",
+	"image/svg+xml",
+	"text/*",
+}
+
+// CompressionConfig holds response compression settings
+type CompressionConfig struct {
+	ContentTypes []string `json:"content_types,omitempty"` // Media types to compress; "text/*" matches a whole type (default: JSON, XML, text)
+	MinSize      int64    `json:"min_size,omitempty"`      // Skip bodies known to be smaller (default: 1024 bytes)
+	Level        int      `json:"level,omitempty"`         // 1 (fastest) to 9 (smallest) (default: 5)
+}
+
+// compressible reports whether a response should vary on Accept-Encoding,
+// i.e. whether it is compressed for clients that accept it
+func (c *CompressionConfig) compressible(r *http.Request, resp *http.Response) bool {
+	if r.Method == http.MethodHead || resp.StatusCode != http.StatusOK {
+		return false
+	}
+	if resp.Header.Get("Content-Encoding") != "" || resp.Header.Get("Content-Range") != "" {
+		return false
+	}
+	if resp.ContentLength >= 0 && resp.ContentLength < c.MinSize {
+		return false
+	}
+	if strings.Contains(resp.Header.Get("Cache-Control"), "no-transform") {
+		return false
+	}
+
+	mediaType, _, err := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if err != nil || mediaType == "text/event-stream" {
+		return false
+	}
+	for _, t := range c.ContentTypes {
+		if prefix, ok := strings.CutSuffix(t, "/*"); ok {
+			if strings.HasPrefix(mediaType, prefix+"/") {
+				return true
+			}
+		} else if mediaType == t {
+			return true
+		}
+	}
+	return false
+}
+
+// negotiateEncoding picks the coding to use for an Accept-Encoding header,
+// preferring brotli, or "" when the client accepts neither. "*" applies only
+// to codings the header does not name (RFC 9110, section 12.5.3).
+func negotiateEncoding(acceptEncoding string) string {
+	var brQ, gzipQ, anyQ float64
+	var brNamed, gzipNamed bool
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			parsed, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			q = parsed
+		}
+		switch strings.ToLower(strings.TrimSpace(coding)) {
+		case encodingBrotli:
+			brQ, brNamed = q, true
+		case encodingGzip, "x-gzip":
+			gzipQ, gzipNamed = q, true
+		case "*":
+			anyQ = q
+		}
+	}
+	if !brNamed {
+		brQ = anyQ
+	}
+	if !gzipNamed {
+		gzipQ = anyQ
+	}
+
+	switch {
+	case brQ > 0 && brQ >= gzipQ:
+		return encodingBrotli
+	case gzipQ > 0:
+		return encodingGzip
+	default:
+		return ""
+	}
+}
+
+// compressWriter compresses a response body on its way to the client. Each
+// Flush pushes out everything written so far so streaming still works.
+type compressWriter struct {
+	http.ResponseWriter
+	enc interface {
+		io.WriteCloser
+		Flush() error
+	}
+}
+
+// startCompression adjusts the response headers for the chosen coding and
+// returns a writer for the body. Call before WriteHeader; Close when done.
+func startCompression(w http.ResponseWriter, encoding string, level int) *compressWriter {
+	h := w.Header()
+	h.Del("Content-Length")
+	h.Set("Content-Encoding", encoding)
+	// The compressed body is a different representation of the resource
+	if etag := h.Get("ETag"); etag != "" && !strings.HasPrefix(etag, "W/") {
+		h.Set("ETag", "W/"+etag)
+	}
+
+	cw := &compressWriter{ResponseWriter: w}
+	if encoding == encodingBrotli {
+		cw.enc = brotli.NewWriterLevel(w, level)
+	} else {
+		gz, _ := gzip.NewWriterLevel(w, level) // level is validated in config
+		cw.enc = gz
+	}
+	return cw
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	return cw.enc.Write(b)
+}
+
+// Flush implements http.Flusher
+func (cw *compressWriter) Flush() {
+	if cw.enc.Flush() != nil {
+		return
+	}
+	if flusher, ok := cw.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Close writes the end of the compressed stream
+func (cw *compressWriter) Close() error {
+	return cw.enc.Close()
+}
@@ -0,0 +1,25 @@
+package relay
+
+import "testing"
+
+func TestNegotiateEncoding(t *testing.T) {
+	tests := []struct {
+		header string
+		want   string
+	}{
+		{"", ""},
+		{"gzip", encodingGzip},
+		{"gzip, br", encodingBrotli},
+		{"br;q=0.5, gzip", encodingGzip},
+		{"*", encodingBrotli},
+		{"br;q=0, *", encodingGzip},
+		{"*, br;q=0", encodingGzip},
+		{"gzip;q=0, br;q=0, *", ""},
+		{"identity", ""},
+	}
+	for _, tt := range tests {
+		if got := negotiateEncoding(tt.header); got != tt.want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", tt.header, got, tt.want)
+		}
+	}
+}
@@ -2,9 +2,19 @@ package relay
 
 import (
 	"encoding/json"
+	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 	"time"
+
+	"github.com/minasoft-technology/gordion-relay/internal/security/timetoken"
+	"github.com/redis/go-redis/v9"
+	"golang.org/x/crypto/acme/autocert"
 )
 
 // Duration wraps time.Duration for JSON unmarshaling
@@ -34,7 +44,17 @@ type Config struct {
 	// Server configuration
 	Mode       string `json:"mode"`        // "websocket" or "grpc" (default: "websocket")
 	ListenAddr string `json:"listen_addr"` // e.g., ":443"
-	Domain     string `json:"domain"`      // e.g., "zenpacs.com.tr"
+	Domain     string `json:"domain"`      // e.g., "zenpacs.com.tr"; primary domain, alias for the first entry of domains
+
+	// All apex domains served, e.g. ["zenpacs.com.tr", "zenpacs.io"].
+	// Domain is added if missing; defaults to [Domain].
+	Domains []string `json:"domains,omitempty"`
+
+	// Optional regex applied to the subdomain part of the host; the first
+	// capture group (or group named "code") is the hospital code.
+	// Default: the last label, so "viewer.ankara" resolves to "ankara".
+	SubdomainPattern string `json:"subdomain_pattern,omitempty"`
+	subdomainRe      *regexp.Regexp
 
 	// TLS configuration
 	TLS TLSConfig `json:"tls"`
@@ -45,13 +65,162 @@ type Config struct {
 	// NATS configuration (optional - for dynamic service discovery)
 	NATS *NATSConfig `json:"nats,omitempty"`
 
+	// Tunnel WebSocket settings (websocket mode)
+	WebSocket WebSocketConfig `json:"websocket"`
+
+	// Edge stream transport settings (grpc mode)
+	GRPC GRPCConfig `json:"grpc"`
+
+	// Client request headers forwarded to hospitals (websocket mode)
+	ForwardHeaders HeaderFilterConfig `json:"forward_headers"`
+
+	// Landing page and branded error pages (websocket mode)
+	Pages PagesConfig `json:"pages"`
+
+	// Compress responses for viewers that accept gzip/brotli (websocket mode; disabled when unset)
+	Compression *CompressionConfig `json:"compression,omitempty"`
+
+	// Outbound connect/disconnect notifications (disabled when unset)
+	Webhooks *WebhooksConfig `json:"webhooks,omitempty"`
+
+	// Download token audit trail (grpc mode; disabled when unset)
+	Audit *AuditConfig `json:"audit,omitempty"`
+
+	// Distributed tracing (disabled when unset)
+	Tracing *TracingConfig `json:"tracing,omitempty"`
+
+	// Fail fast for hospitals whose requests keep failing (disabled when unset)
+	CircuitBreaker *CircuitBreakerConfig `json:"circuit_breaker,omitempty"`
+
 	// Timeouts and limits
-	IdleTimeout       Duration `json:"idle_timeout"`        // Default: 30s
-	MaxConcurrentConn int      `json:"max_concurrent_conn"` // Default: 1000
-	RequestTimeout    Duration `json:"request_timeout"`     // Default: 5m (for large file transfers)
+	IdleTimeout       Duration `json:"idle_timeout"`        // Default: 30s (viewer keep-alive connections)
+	MaxConcurrentConn int      `json:"max_concurrent_conn"` // Default: 1000 (forwarded requests in flight; more get 429)
+	RequestTimeout    Duration `json:"request_timeout"`     // Default: 5m (websocket: total time for a request and its response)
+	IdleChunkTimeout  Duration `json:"idle_chunk_timeout"`  // Default: 60s (websocket: max silence from the agent while streaming a response)
+	FetchTimeout      Duration `json:"fetch_timeout"`       // Default: 60s (gRPC: max silence from the edge during a fetch)
+
+	// Largest request body forwarded to an agent. Bodies are buffered in
+	// memory before sending, so this bounds per-request memory use.
+	MaxRequestBodyBytes int64 `json:"max_request_body_bytes"` // Default: 32MB
+
+	// Largest response body relayed to a viewer; larger responses are cut off
+	// and the viewer's connection is aborted
+	MaxResponseBodyBytes int64 `json:"max_response_body_bytes"` // Default: 0 (unlimited)
+
+	// Buffer used when streaming fetched data to viewers; each filled buffer is flushed
+	CopyBufferSize int `json:"copy_buffer_size"` // Default: 64KB
+
+	// Graceful shutdown
+	ShutdownGracePeriod Duration `json:"shutdown_grace_period"` // Default: 30s (time allowed for in-flight requests on stop)
+
+	// Authentication rate limiting
+	RateLimit RateLimitConfig `json:"rate_limit"`
+
+	// Reverse proxies (IPs/CIDRs) whose X-Forwarded-For header is trusted
+	TrustedProxies []string `json:"trusted_proxies,omitempty"`
+	trustedNets    []*net.IPNet
+
+	// Agent liveness
+	HeartbeatTimeout       Duration `json:"heartbeat_timeout"`        // Default: 90s (evict agents/edges silent for longer)
+	HeartbeatCheckInterval Duration `json:"heartbeat_check_interval"` // Default: 15s
+
+	// Registration behavior
+	RegistrationTimeout         Duration `json:"registration_timeout"`          // Default: 10s (time allowed for the REGISTER message after connecting)
+	RejectDuplicateRegistration bool     `json:"reject_duplicate_registration"` // Reject a hospital that is already connected (default: replace the old connection)
+	MaxHospitals                int      `json:"max_hospitals"`                 // Hospitals connected at the same time; more are rejected (default: 0, unlimited)
+
+	// Readiness after a restart: /ready returns 503 until these hospital codes
+	// and at least StartupWaitMinHospitals distinct hospitals have registered,
+	// or StartupWaitTimeout has passed (default: ready at once)
+	StartupWaitHospitals    []string `json:"startup_wait_hospitals,omitempty"`
+	StartupWaitMinHospitals int      `json:"startup_wait_min_hospitals,omitempty"`
+	StartupWaitTimeout      Duration `json:"startup_wait_timeout,omitempty"` // Default: 2m when either wait is set
+
+	// Accept path limits on listen_addr
+	MaxConnections          int `json:"max_connections"`           // Open connections at the same time; more are closed on accept (default: 0, unlimited)
+	MaxPendingRegistrations int `json:"max_pending_registrations"` // Tunnel connections waiting to register; more are rejected (default: 100)
+
+	// Download tokens
+	DisableTokenReplayCheck bool      `json:"disable_token_replay_check"`     // Allow download tokens to be reused until expiry (default: single-use)
+	TokenScheme             string    `json:"token_scheme,omitempty"`         // Scheme for tokens the relay issues: "aes-gcm" (default, opaque) or "hmac" (signed, readable)
+	TokenSkewTolerance      *Duration `json:"token_skew_tolerance,omitempty"` // Allowed clock drift with token issuers (default: 30s; "0s" for strict)
 
 	// Monitoring
-	MetricsAddr string `json:"metrics_addr,omitempty"` // e.g., ":8080" for metrics endpoint
+	MetricsAddr          string    `json:"metrics_addr,omitempty"`           // e.g., ":8080" for metrics endpoint
+	SlowRequestThreshold *Duration `json:"slow_request_threshold,omitempty"` // Log forwarded requests taking longer (default: 10s; "0s" disables)
+
+	// Serve /debug/pprof/ on metrics_addr (websocket mode only; default: false).
+	// Profiles expose internals, so keep metrics_addr off the public network.
+	EnablePprof bool `json:"enable_pprof"`
+
+	// Hospital codes in maintenance mode at startup; their requests get 503.
+	// Changed at runtime via /admin/maintenance.
+	Maintenance []string `json:"maintenance,omitempty"`
+
+	// Answer forwarding failures with a JSON body naming the cause
+	// (agent_not_connected, stream_open_failed, upstream_timeout,
+	// bad_response_framing) instead of a generic message. For staging.
+	ExposeUpstreamErrors bool `json:"expose_upstream_errors,omitempty"`
+
+	// Admin API (POST /admin/tokens); disabled when empty. Overridden by GORDION_RELAY_ADMIN_TOKEN.
+	AdminToken string `json:"admin_token,omitempty"`
+
+	// Path probed through a hospital's tunnel by /admin/selftest (default: "/health")
+	SelfTestPath string `json:"self_test_path,omitempty"`
+
+	// The relay's own HTTP endpoints that are served
+	Endpoints EndpointsConfig `json:"endpoints"`
+
+	// CORS headers on /status and /status/stream (default: any origin)
+	CORS *CORSConfig `json:"cors,omitempty"`
+}
+
+// EndpointsConfig turns the relay's own HTTP endpoints on or off. Disabled
+// endpoints are not registered at all and answer 404; /health is always served.
+type EndpointsConfig struct {
+	EnableStatus  bool `json:"enable_status"`  // /status and /status/stream (default: true)
+	EnableMetrics bool `json:"enable_metrics"` // /metrics (default: true)
+	EnableAdmin   bool `json:"enable_admin"`   // /admin/*, which also needs admin_token (default: true)
+}
+
+// CORSConfig controls which browser origins may read /status and
+// /status/stream. An empty allowed_origins list sends no CORS headers.
+type CORSConfig struct {
+	AllowedOrigins []string `json:"allowed_origins"`           // e.g. ["https://dashboard.example.com"]; "*" allows any (default: ["*"])
+	AllowedMethods []string `json:"allowed_methods,omitempty"` // Methods allowed in preflight answers (default: GET, OPTIONS)
+	AllowedHeaders []string `json:"allowed_headers,omitempty"` // Request headers allowed in preflight answers (default: Content-Type)
+	MaxAge         Duration `json:"max_age,omitempty"`         // How long browsers may cache a preflight answer (default: not sent)
+}
+
+func (c *CORSConfig) validate() []string {
+	var problems []string
+	for _, origin := range c.AllowedOrigins {
+		if origin == "*" {
+			continue
+		}
+		if u, err := url.Parse(origin); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || u.Path != "" || u.RawQuery != "" {
+			problems = append(problems, fmt.Sprintf("cors.allowed_origins entry %q must be \"*\" or scheme://host[:port]", origin))
+		}
+	}
+	for _, m := range c.AllowedMethods {
+		if m == "" || strings.ContainsAny(m, " \t,") {
+			problems = append(problems, fmt.Sprintf("cors.allowed_methods entry %q is not a method", m))
+		}
+	}
+	for _, h := range c.AllowedHeaders {
+		if h == "" || strings.ContainsAny(h, " \t,:") {
+			problems = append(problems, fmt.Sprintf("cors.allowed_headers entry %q is not a header name", h))
+		}
+	}
+	if c.MaxAge < 0 {
+		problems = append(problems, fmt.Sprintf("cors.max_age must not be negative, got %s", c.MaxAge.ToDuration()))
+	}
+	return problems
+}
+
+// adminEnabled reports whether the admin endpoints are served
+func (c *Config) adminEnabled() bool {
+	return c.AdminToken != "" && c.Endpoints.EnableAdmin
 }
 
 // TLSConfig holds TLS certificate configuration
@@ -61,6 +230,156 @@ type TLSConfig struct {
 	KeyFile   string `json:"key_file"`   // Path to private key file
 	AutoCert  bool   `json:"auto_cert"`  // Use Let's Encrypt auto-cert
 	ACMEEmail string `json:"acme_email"` // Email for Let's Encrypt notifications (required for auto_cert)
+
+	// ACME (auto_cert) storage and CA
+	CacheDir         string `json:"cache_dir,omitempty"`          // Certificate cache directory (default: "certs")
+	ACMEDirectoryURL string `json:"acme_directory_url,omitempty"` // ACME directory (default: Let's Encrypt production)
+
+	// Certificate served while auto_cert issuance is pending or failing (websocket mode)
+	FallbackCertFile   string `json:"fallback_cert_file,omitempty"`   // Fallback certificate file
+	FallbackKeyFile    string `json:"fallback_key_file,omitempty"`    // Fallback private key file
+	SelfSignedFallback bool   `json:"self_signed_fallback,omitempty"` // Generate a self-signed fallback for the apex domains
+
+	// Plain HTTP server for ACME HTTP-01 challenges and HTTPS redirects (websocket mode)
+	HTTPChallengePort   int  `json:"http_challenge_port,omitempty"`   // Default: 80
+	DisableHTTPRedirect bool `json:"disable_http_redirect,omitempty"` // Don't start it; auto_cert then relies on TLS-ALPN-01 on listen_addr
+
+	// Protocol policy for every TLS listener
+	MinVersion   string   `json:"min_version,omitempty"`   // "1.2" (default) or "1.3"
+	CipherSuites []string `json:"cipher_suites,omitempty"` // TLS 1.2 suites by Go name (default: Go's secure defaults)
+
+	// Edge client certificates (gRPC mode)
+	ClientCAFile   string `json:"client_ca_file,omitempty"`   // CA bundle for verifying edge client certificates; enables mTLS
+	ClientAuthMode string `json:"client_auth_mode,omitempty"` // "token" (default), "cert" or "cert+token"
+}
+
+// Edge authentication modes (TLSConfig.ClientAuthMode)
+const (
+	ClientAuthToken        = "token"      // shared token only
+	ClientAuthCert         = "cert"       // verified client certificate only
+	ClientAuthCertAndToken = "cert+token" // both
+)
+
+// requiresClientCert reports whether edges must prove their identity with a certificate
+func (t *TLSConfig) requiresClientCert() bool {
+	return t.ClientAuthMode == ClientAuthCert || t.ClientAuthMode == ClientAuthCertAndToken
+}
+
+// requiresToken reports whether edges must present their shared token
+func (t *TLSConfig) requiresToken() bool {
+	return t.ClientAuthMode != ClientAuthCert
+}
+
+// hasACMEFallback reports whether a certificate is served while auto_cert
+// issuance is pending or failing
+func (t *TLSConfig) hasACMEFallback() bool {
+	return t.FallbackCertFile != "" || t.FallbackKeyFile != "" || t.SelfSignedFallback
+}
+
+// RateLimitConfig controls blocking of clients after failed authentication
+type RateLimitConfig struct {
+	MaxAttempts    int      `json:"max_attempts"`          // Failed attempts before blocking (default: 100)
+	BlockDuration  Duration `json:"block_duration"`        // How long a client stays blocked (default: 5m)
+	WindowDuration Duration `json:"window_duration"`       // Failures older than this reset the count (default: 15m)
+	AllowedIPs     []string `json:"allowed_ips,omitempty"` // IPs/CIDRs exempt from rate limiting (e.g. monitoring, known edges)
+
+	// Where failures are tracked: "memory" (default, per process) or "redis"
+	// (shared by all replicas). Redis URL overridden by GORDION_RELAY_REDIS_URL.
+	Backend  string `json:"backend,omitempty"`
+	RedisURL string `json:"redis_url,omitempty"` // e.g. "redis://:password@redis:6379/0"
+
+	allowedNets []*net.IPNet // parsed AllowedIPs
+}
+
+// parseAllowedIPs parses AllowedIPs into networks
+func (r *RateLimitConfig) parseAllowedIPs() error {
+	nets, err := parseIPNets("rate_limit.allowed_ips", r.AllowedIPs)
+	if err != nil {
+		return err
+	}
+	r.allowedNets = nets
+	return nil
+}
+
+// IsAllowed reports whether ip (with or without port) is exempt from rate limiting
+func (r *RateLimitConfig) IsAllowed(addr string) bool {
+	return ipInNets(parseHostIP(addr), r.allowedNets)
+}
+
+// parseIPNets parses IPs and CIDRs; bare IPs become /32 or /128 networks
+func parseIPNets(field string, entries []string) ([]*net.IPNet, error) {
+	var nets []*net.IPNet
+	for _, entry := range entries {
+		if !strings.Contains(entry, "/") {
+			ip := net.ParseIP(entry)
+			if ip == nil {
+				return nil, fmt.Errorf("%s: invalid IP %q", field, entry)
+			}
+			bits := 128
+			if ip.To4() != nil {
+				ip, bits = ip.To4(), 32
+			}
+			nets = append(nets, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(entry)
+		if err != nil {
+			return nil, fmt.Errorf("%s: invalid CIDR %q: %w", field, entry, err)
+		}
+		nets = append(nets, ipNet)
+	}
+	return nets, nil
+}
+
+// ipInNets reports whether ip is contained in any of nets
+func ipInNets(ip net.IP, nets []*net.IPNet) bool {
+	if ip == nil {
+		return false
+	}
+	for _, ipNet := range nets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// parseHostIP extracts the IP from "host", "host:port", "[v6]:port" or "v6%zone"
+func parseHostIP(addr string) net.IP {
+	if host, _, err := net.SplitHostPort(addr); err == nil {
+		addr = host
+	}
+	addr = strings.Trim(addr, "[]")
+	if i := strings.IndexByte(addr, '%'); i != -1 {
+		addr = addr[:i]
+	}
+	return net.ParseIP(addr)
+}
+
+// validate rejects negative rate-limit settings (zero values are replaced by defaults)
+func (r *RateLimitConfig) validate() []string {
+	var problems []string
+	if r.MaxAttempts <= 0 {
+		problems = append(problems, fmt.Sprintf("rate_limit.max_attempts must be positive, got %d", r.MaxAttempts))
+	}
+	if r.BlockDuration <= 0 {
+		problems = append(problems, fmt.Sprintf("rate_limit.block_duration must be positive, got %s", r.BlockDuration.ToDuration()))
+	}
+	if r.WindowDuration <= 0 {
+		problems = append(problems, fmt.Sprintf("rate_limit.window_duration must be positive, got %s", r.WindowDuration.ToDuration()))
+	}
+	switch r.Backend {
+	case RateLimitBackendMemory:
+	case RateLimitBackendRedis:
+		if r.RedisURL == "" {
+			problems = append(problems, "rate_limit.redis_url is required when rate_limit.backend is \"redis\"")
+		} else if _, err := redis.ParseURL(r.RedisURL); err != nil {
+			problems = append(problems, fmt.Sprintf("rate_limit.redis_url is invalid: %v", err))
+		}
+	default:
+		problems = append(problems, fmt.Sprintf("rate_limit.backend must be \"memory\" or \"redis\", got %q", r.Backend))
+	}
+	return problems
 }
 
 // HospitalConfig defines a static hospital mapping
@@ -68,7 +387,88 @@ type HospitalConfig struct {
 	Code       string `json:"code"`        // e.g., "demo-samsun" (subdomain identifier)
 	HospitalID string `json:"hospital_id"` // e.g., "DEMO_SAMSUN" (database hospital ID)
 	Subdomain  string `json:"subdomain"`   // e.g., "demo-samsun.zenpacs.com.tr"
-	Token      string `json:"token"`       // Pre-shared token for authentication and token validation
+	Token      string `json:"token"`       // Pre-shared token for agent/edge authentication (and download tokens without download_key)
+
+	// Key for download tokens, kept apart from Token so either can be
+	// rotated, or leak, without affecting the other. Defaults to Token.
+	DownloadKey     string `json:"download_key,omitempty"`
+	DownloadKeyFile string `json:"download_key_file,omitempty"` // File holding the download key, e.g. a mounted secret
+
+	// Previous download keys still accepted for download-token validation during rotation
+	PreviousTokens []string `json:"previous_tokens,omitempty"`
+
+	// Additional codes (subdomain labels) routed to this hospital's agent, e.g. ["samsun"]
+	Aliases []string `json:"aliases,omitempty"`
+
+	// Per-hospital overrides of the global limits; zero uses the global value
+	RequestTimeout       Duration `json:"request_timeout,omitempty"`        // websocket mode
+	FetchTimeout         Duration `json:"fetch_timeout,omitempty"`          // grpc mode
+	MaxRequestBodyBytes  int64    `json:"max_request_body_bytes,omitempty"` // websocket mode
+	MaxResponseBodyBytes int64    `json:"max_response_body_bytes,omitempty"`
+
+	// Response bytes per second shared by all of this hospital's requests; 0 is unlimited
+	MaxBytesPerSec int64 `json:"max_bytes_per_sec,omitempty"`
+
+	// Requests forwarded to this hospital; anything else gets 403. Empty allows all.
+	AllowedMethods []string `json:"allowed_methods,omitempty"` // e.g. ["GET"] (GET also allows HEAD)
+	AllowedPaths   []string `json:"allowed_paths,omitempty"`   // Path prefixes, e.g. ["/instances/"]
+	access         *accessRules
+}
+
+// atCapacity reports whether max_hospitals leaves no room for another
+// hospital while connected hospitals are registered
+func (c *Config) atCapacity(connected int) bool {
+	return c.MaxHospitals > 0 && connected >= c.MaxHospitals
+}
+
+// forwardLimits are the limits that apply to requests for one hospital
+type forwardLimits struct {
+	RequestTimeout       time.Duration
+	IdleChunkTimeout     time.Duration
+	FetchTimeout         time.Duration
+	MaxRequestBodyBytes  int64
+	MaxResponseBodyBytes int64 // 0 is unlimited
+}
+
+// limitsFor returns the effective limits for a hospital: its overrides where
+// set, the global values otherwise. h may be nil.
+func (c *Config) limitsFor(h *HospitalConfig) forwardLimits {
+	limits := forwardLimits{
+		RequestTimeout:       c.RequestTimeout.ToDuration(),
+		IdleChunkTimeout:     c.IdleChunkTimeout.ToDuration(),
+		FetchTimeout:         c.FetchTimeout.ToDuration(),
+		MaxRequestBodyBytes:  c.MaxRequestBodyBytes,
+		MaxResponseBodyBytes: c.MaxResponseBodyBytes,
+	}
+	if h == nil {
+		return limits
+	}
+	if h.RequestTimeout > 0 {
+		limits.RequestTimeout = h.RequestTimeout.ToDuration()
+	}
+	if h.FetchTimeout > 0 {
+		limits.FetchTimeout = h.FetchTimeout.ToDuration()
+	}
+	if h.MaxRequestBodyBytes > 0 {
+		limits.MaxRequestBodyBytes = h.MaxRequestBodyBytes
+	}
+	if h.MaxResponseBodyBytes > 0 {
+		limits.MaxResponseBodyBytes = h.MaxResponseBodyBytes
+	}
+	return limits
+}
+
+// downloadKey returns the key download tokens are issued with
+func (h *HospitalConfig) downloadKey() string {
+	if h.DownloadKey != "" {
+		return h.DownloadKey
+	}
+	return h.Token
+}
+
+// TokenKeys returns the keys accepted for download tokens, current key first
+func (h *HospitalConfig) TokenKeys() []string {
+	return append([]string{h.downloadKey()}, h.PreviousTokens...)
 }
 
 // NATSConfig holds NATS configuration for dynamic service discovery
@@ -79,6 +479,59 @@ type NATSConfig struct {
 	Subject         string `json:"subject"` // e.g., "hospitals.registration"
 }
 
+// WebSocketConfig holds tunnel WebSocket options
+type WebSocketConfig struct {
+	// Negotiate permessage-deflate with agents. Saves bandwidth on header and
+	// JSON-heavy traffic at the cost of CPU; already-compressed pixel data gains little.
+	EnableCompression    bool `json:"enable_compression"`
+	CompressionThreshold int  `json:"compression_threshold"` // Smallest message compressed, in bytes (default: 1024)
+	CompressionLevel     int  `json:"compression_level"`     // flate level 1 (fastest) to 9 (smallest); default: 1
+
+	// Response frames buffered per request (per agent for protocol 1) while
+	// the viewer drains them. When the buffer stays full for DeliveryTimeout,
+	// the request is failed (protocol 1: the agent is disconnected) so the
+	// tunnel keeps reading heartbeats and other requests' frames.
+	MessageBufferSize int      `json:"message_buffer_size"` // Default: 64
+	DeliveryTimeout   Duration `json:"delivery_timeout"`    // Default: 30s or half of heartbeat_timeout, if shorter; must be below heartbeat_timeout
+
+	// Protocol 1 agents serve one request at a time; the others wait in
+	// arrival order. Requests finding QueueDepth requests already waiting get
+	// 503, requests waiting longer than QueueTimeout get 504.
+	QueueDepth   int      `json:"queue_depth"`   // Default: 100
+	QueueTimeout Duration `json:"queue_timeout"` // Default: 30s
+
+	// WebSocket ping frames keep idle tunnels alive through NATs and detect
+	// dead ones; agents without a pong within PongTimeout are disconnected
+	PingInterval *Duration `json:"ping_interval,omitempty"` // Default: 30s; 0 disables pings
+	PongTimeout  Duration  `json:"pong_timeout"`            // Default: 10s
+
+	// Origins allowed to open /tunnel, e.g. "https://admin.example.com".
+	// Empty allows all. Agents normally send no Origin header and are always
+	// accepted; this keeps browser pages from opening tunnels.
+	AllowedOrigins []string `json:"allowed_origins,omitempty"`
+}
+
+// GRPCConfig holds edge connection tuning for grpc mode. Defaults match the
+// previously hardcoded keepalive values.
+type GRPCConfig struct {
+	KeepaliveTime        Duration `json:"keepalive_time"`         // Ping an edge after this much silence (default: 10s)
+	KeepaliveTimeout     Duration `json:"keepalive_timeout"`      // Drop the edge if the ping is not acked in time (default: 5s)
+	MinPingInterval      Duration `json:"min_ping_interval"`      // Fastest edge ping rate tolerated before the relay disconnects it (default: 5s)
+	MaxConnectionIdle    Duration `json:"max_connection_idle"`    // Close connections without streams after this long (default: 0, never)
+	MaxConcurrentStreams uint32   `json:"max_concurrent_streams"` // Streams per edge connection (default: 0, gRPC default)
+	MaxRecvMsgSize       int      `json:"max_recv_msg_size"`      // Largest message accepted from an edge, in bytes (default: 16MB)
+	MaxSendMsgSize       int      `json:"max_send_msg_size"`      // Largest message sent to an edge, in bytes (default: 16MB)
+	MaxPendingPerEdge    int      `json:"max_pending_per_edge"`   // Fetches waiting on one edge connection; more get 503 (default: 256)
+}
+
+// TracingConfig holds OpenTelemetry trace export settings
+type TracingConfig struct {
+	OTLPEndpoint string  `json:"otlp_endpoint"`          // OTLP/gRPC collector, e.g. "otel-collector:4317"; empty disables tracing
+	Insecure     bool    `json:"insecure,omitempty"`     // Connect to the collector without TLS
+	ServiceName  string  `json:"service_name,omitempty"` // Default: "gordion-relay"
+	SampleRatio  float64 `json:"sample_ratio,omitempty"` // Fraction of new traces recorded (default: 1)
+}
+
 // LoadConfig loads configuration from a JSON file and environment variables
 func LoadConfig(path string) (*Config, error) {
 	data, err := os.ReadFile(path)
@@ -86,7 +539,10 @@ func LoadConfig(path string) (*Config, error) {
 		return nil, err
 	}
 
-	var config Config
+	// Endpoints are on unless switched off, so they are set before decoding
+	config := Config{
+		Endpoints: EndpointsConfig{EnableStatus: true, EnableMetrics: true, EnableAdmin: true},
+	}
 	if err := json.Unmarshal(data, &config); err != nil {
 		return nil, err
 	}
@@ -95,27 +551,636 @@ func LoadConfig(path string) (*Config, error) {
 	if config.Mode == "" {
 		config.Mode = "websocket" // Default to WebSocket for backward compatibility
 	}
+	if config.RegistrationTimeout == 0 {
+		config.RegistrationTimeout = Duration(10 * time.Second)
+	}
 	if config.IdleTimeout == 0 {
 		config.IdleTimeout = Duration(30 * time.Second)
 	}
 	if config.MaxConcurrentConn == 0 {
 		config.MaxConcurrentConn = 1000
 	}
+	if config.MaxPendingRegistrations == 0 {
+		config.MaxPendingRegistrations = 100
+	}
+	if (len(config.StartupWaitHospitals) > 0 || config.StartupWaitMinHospitals > 0) && config.StartupWaitTimeout == 0 {
+		config.StartupWaitTimeout = Duration(2 * time.Minute)
+	}
 	if config.RequestTimeout == 0 {
 		config.RequestTimeout = Duration(5 * time.Minute)
 	}
+	if config.IdleChunkTimeout == 0 {
+		config.IdleChunkTimeout = Duration(60 * time.Second)
+	}
+	if config.FetchTimeout == 0 {
+		config.FetchTimeout = Duration(60 * time.Second)
+	}
+	if config.MaxRequestBodyBytes == 0 {
+		config.MaxRequestBodyBytes = 32 << 20
+	}
+	if config.CopyBufferSize == 0 {
+		config.CopyBufferSize = 64 << 10
+	}
+	if config.ShutdownGracePeriod == 0 {
+		config.ShutdownGracePeriod = Duration(30 * time.Second)
+	}
+	if config.RateLimit.MaxAttempts == 0 {
+		config.RateLimit.MaxAttempts = 100
+	}
+	if config.RateLimit.BlockDuration == 0 {
+		config.RateLimit.BlockDuration = Duration(5 * time.Minute)
+	}
+	if config.RateLimit.Backend == "" {
+		config.RateLimit.Backend = RateLimitBackendMemory
+	}
+	if config.RateLimit.WindowDuration == 0 {
+		config.RateLimit.WindowDuration = Duration(15 * time.Minute)
+	}
+	if config.HeartbeatTimeout == 0 {
+		config.HeartbeatTimeout = Duration(90 * time.Second)
+	}
+	if config.HeartbeatCheckInterval == 0 {
+		config.HeartbeatCheckInterval = Duration(15 * time.Second)
+	}
+	if config.GRPC.KeepaliveTime == 0 {
+		config.GRPC.KeepaliveTime = Duration(10 * time.Second)
+	}
+	if config.GRPC.KeepaliveTimeout == 0 {
+		config.GRPC.KeepaliveTimeout = Duration(5 * time.Second)
+	}
+	if config.GRPC.MinPingInterval == 0 {
+		config.GRPC.MinPingInterval = Duration(5 * time.Second)
+	}
+	if config.GRPC.MaxRecvMsgSize == 0 {
+		config.GRPC.MaxRecvMsgSize = MaxMessageSize
+	}
+	if config.GRPC.MaxSendMsgSize == 0 {
+		config.GRPC.MaxSendMsgSize = MaxMessageSize
+	}
+	if config.SelfTestPath == "" {
+		config.SelfTestPath = "/health"
+	}
+	if config.GRPC.MaxPendingPerEdge == 0 {
+		config.GRPC.MaxPendingPerEdge = 256
+	}
+	if config.WebSocket.MessageBufferSize == 0 {
+		config.WebSocket.MessageBufferSize = 64
+	}
+	if config.WebSocket.DeliveryTimeout == 0 {
+		// Fail a stalled request well before its agent is declared dead
+		config.WebSocket.DeliveryTimeout = min(Duration(30*time.Second), config.HeartbeatTimeout/2)
+	}
+	if config.WebSocket.QueueDepth == 0 {
+		config.WebSocket.QueueDepth = 100
+	}
+	if config.WebSocket.QueueTimeout == 0 {
+		config.WebSocket.QueueTimeout = Duration(30 * time.Second)
+	}
+	if config.WebSocket.PingInterval == nil {
+		interval := Duration(30 * time.Second)
+		config.WebSocket.PingInterval = &interval
+	}
+	if config.WebSocket.PongTimeout == 0 {
+		config.WebSocket.PongTimeout = Duration(10 * time.Second)
+	}
+	if config.WebSocket.CompressionThreshold == 0 {
+		config.WebSocket.CompressionThreshold = 1024
+	}
+	if config.WebSocket.CompressionLevel == 0 {
+		config.WebSocket.CompressionLevel = 1
+	}
+	if config.TokenSkewTolerance == nil {
+		skew := Duration(timetoken.DefaultSkewTolerance)
+		config.TokenSkewTolerance = &skew
+	}
+	if config.SlowRequestThreshold == nil {
+		threshold := Duration(10 * time.Second)
+		config.SlowRequestThreshold = &threshold
+	}
+	if config.TokenScheme == "" {
+		config.TokenScheme = string(timetoken.SchemeAESGCM)
+	}
+	if config.TLS.CacheDir == "" {
+		config.TLS.CacheDir = "certs"
+	}
+	if config.TLS.HTTPChallengePort == 0 {
+		config.TLS.HTTPChallengePort = 80
+	}
+	if config.TLS.ACMEDirectoryURL == "" {
+		config.TLS.ACMEDirectoryURL = autocert.DefaultACMEDirectory
+	}
+	if config.TLS.ClientAuthMode == "" {
+		config.TLS.ClientAuthMode = ClientAuthToken
+	}
+	if config.Webhooks != nil {
+		if config.Webhooks.Timeout == 0 {
+			config.Webhooks.Timeout = Duration(5 * time.Second)
+		}
+		if config.Webhooks.MaxRetries == 0 {
+			config.Webhooks.MaxRetries = 3
+		}
+	}
+	if config.Compression != nil {
+		if len(config.Compression.ContentTypes) == 0 {
+			config.Compression.ContentTypes = defaultCompressTypes
+		}
+		if config.Compression.MinSize == 0 {
+			config.Compression.MinSize = 1024
+		}
+		if config.Compression.Level == 0 {
+			config.Compression.Level = 5
+		}
+	}
+	if b := config.CircuitBreaker; b != nil {
+		if b.FailureRatio == 0 {
+			b.FailureRatio = 0.5
+		}
+		if b.MinRequests == 0 {
+			b.MinRequests = 10
+		}
+		if b.Window == 0 {
+			b.Window = Duration(30 * time.Second)
+		}
+		if b.OpenDuration == 0 {
+			b.OpenDuration = Duration(30 * time.Second)
+		}
+	}
+	// CORS stays as permissive as before unless configured
+	if config.CORS == nil {
+		config.CORS = &CORSConfig{}
+	}
+	if config.CORS.AllowedOrigins == nil {
+		config.CORS.AllowedOrigins = []string{"*"}
+	}
+	if len(config.CORS.AllowedMethods) == 0 {
+		config.CORS.AllowedMethods = []string{"GET", "OPTIONS"}
+	}
+	if len(config.CORS.AllowedHeaders) == 0 {
+		config.CORS.AllowedHeaders = []string{"Content-Type"}
+	}
+	if config.Tracing != nil {
+		if config.Tracing.ServiceName == "" {
+			config.Tracing.ServiceName = "gordion-relay"
+		}
+		if config.Tracing.SampleRatio == 0 {
+			config.Tracing.SampleRatio = 1
+		}
+	}
 
 	// TLS is disabled by default (HTTPProxy/Ingress handles TLS)
 	// Users must explicitly enable it for standalone deployments
 
+	// Domain names the primary domain (used for default hospital subdomains)
+	if config.Domain == "" && len(config.Domains) > 0 {
+		config.Domain = config.Domains[0]
+	}
+
+	if redisURL := os.Getenv("GORDION_RELAY_REDIS_URL"); redisURL != "" {
+		config.RateLimit.RedisURL = redisURL
+	}
+	if token := os.Getenv("GORDION_RELAY_ADMIN_TOKEN"); token != "" {
+		config.AdminToken = token
+	}
+
 	// Load hospitals from environment variables or separate file
 	if err := loadHospitalsFromEnv(&config); err != nil {
 		return nil, err
 	}
+	if err := loadDownloadKeys(config.Hospitals); err != nil {
+		return nil, err
+	}
+
+	if err := config.Validate(); err != nil {
+		return nil, err
+	}
+
+	// Pre-parse address lists (already checked by Validate)
+	if err := config.RateLimit.parseAllowedIPs(); err != nil {
+		return nil, err
+	}
+	if config.trustedNets, err = parseIPNets("trusted_proxies", config.TrustedProxies); err != nil {
+		return nil, err
+	}
+	if config.SubdomainPattern != "" {
+		if config.subdomainRe, err = regexp.Compile(config.SubdomainPattern); err != nil {
+			return nil, err
+		}
+	}
 
 	return &config, nil
 }
 
+// ApexDomains returns every configured apex domain, lowercased and without
+// duplicates, with Domain first
+func (c *Config) ApexDomains() []string {
+	var domains []string
+	seen := make(map[string]bool)
+	for _, d := range append([]string{c.Domain}, c.Domains...) {
+		d = strings.ToLower(strings.TrimSpace(d))
+		if d == "" || seen[d] {
+			continue
+		}
+		seen[d] = true
+		domains = append(domains, d)
+	}
+	return domains
+}
+
+// Validate checks configuration invariants and returns a single error listing
+// every problem found, so operators can fix them all in one pass
+func (c *Config) Validate() error {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if c.Mode != "websocket" && c.Mode != "grpc" {
+		addf("mode must be \"websocket\" or \"grpc\", got %q", c.Mode)
+	}
+	if len(c.ApexDomains()) == 0 {
+		addf("domain is required (e.g. \"zenpacs.com.tr\")")
+	}
+	for i, d := range c.Domains {
+		if strings.TrimSpace(d) == "" {
+			addf("domains[%d] is empty", i)
+		}
+	}
+	if c.ListenAddr == "" {
+		addf("listen_addr is required (e.g. \":443\")")
+	}
+	if c.MaxConcurrentConn < 0 {
+		addf("max_concurrent_conn must be positive, got %d", c.MaxConcurrentConn)
+	}
+	if c.MaxHospitals < 0 {
+		addf("max_hospitals must not be negative, got %d", c.MaxHospitals)
+	}
+	if c.MaxConnections < 0 {
+		addf("max_connections must not be negative, got %d", c.MaxConnections)
+	}
+	if c.MaxPendingRegistrations < 0 {
+		addf("max_pending_registrations must not be negative, got %d", c.MaxPendingRegistrations)
+	}
+	if c.MaxRequestBodyBytes < 0 {
+		addf("max_request_body_bytes must be positive, got %d", c.MaxRequestBodyBytes)
+	}
+	if c.MaxResponseBodyBytes < 0 {
+		addf("max_response_body_bytes must not be negative, got %d", c.MaxResponseBodyBytes)
+	}
+	if c.RequestTimeout < 0 || c.IdleChunkTimeout < 0 {
+		addf("request_timeout and idle_chunk_timeout must not be negative")
+	}
+	if c.HeartbeatTimeout <= 0 {
+		addf("heartbeat_timeout must be positive, got %s", c.HeartbeatTimeout.ToDuration())
+	}
+	if c.HeartbeatCheckInterval <= 0 {
+		addf("heartbeat_check_interval must be positive, got %s", c.HeartbeatCheckInterval.ToDuration())
+	}
+	if c.CopyBufferSize != 0 && (c.CopyBufferSize < minCopyBufferSize || c.CopyBufferSize > maxCopyBufferSize) {
+		addf("copy_buffer_size must be between %d and %d bytes, got %d", minCopyBufferSize, maxCopyBufferSize, c.CopyBufferSize)
+	}
+	if c.SubdomainPattern != "" {
+		if _, err := regexp.Compile(c.SubdomainPattern); err != nil {
+			addf("subdomain_pattern is not a valid regular expression: %v", err)
+		}
+	}
+
+	if c.TLS.Enabled {
+		if c.TLS.AutoCert && c.TLS.ACMEEmail == "" {
+			addf("tls.acme_email is required when tls.auto_cert is enabled")
+		}
+		if u, err := url.Parse(c.TLS.ACMEDirectoryURL); c.TLS.AutoCert && (err != nil || u.Scheme != "https" || u.Host == "") {
+			addf("tls.acme_directory_url must be an https URL, got %q", c.TLS.ACMEDirectoryURL)
+		}
+		if !c.TLS.AutoCert && (c.TLS.CertFile == "" || c.TLS.KeyFile == "") {
+			addf("tls.cert_file and tls.key_file are required when TLS is enabled without auto_cert")
+		}
+		if c.TLS.hasACMEFallback() {
+			if !c.TLS.AutoCert || c.Mode != "websocket" {
+				addf("tls.fallback_cert_file and tls.self_signed_fallback are only supported with tls.auto_cert in websocket mode")
+			}
+			if (c.TLS.FallbackCertFile == "") != (c.TLS.FallbackKeyFile == "") {
+				addf("tls.fallback_cert_file and tls.fallback_key_file must be set together")
+			}
+			if c.TLS.FallbackCertFile != "" && c.TLS.SelfSignedFallback {
+				addf("tls.self_signed_fallback cannot be combined with tls.fallback_cert_file")
+			}
+		}
+		if c.Mode == "websocket" && !c.TLS.DisableHTTPRedirect {
+			port := c.TLS.HTTPChallengePort
+			if port < 1 || port > 65535 {
+				addf("tls.http_challenge_port must be between 1 and 65535, got %d", port)
+			} else if addrPort(c.ListenAddr) == strconv.Itoa(port) {
+				addf("tls.http_challenge_port %d collides with listen_addr %q", port, c.ListenAddr)
+			} else if addrPort(c.MetricsAddr) == strconv.Itoa(port) {
+				addf("tls.http_challenge_port %d collides with metrics_addr %q", port, c.MetricsAddr)
+			}
+		}
+	}
+
+	// In grpc mode metrics_addr is the viewer listener, so pprof has no private home
+	if c.EnablePprof {
+		if c.Mode != "websocket" {
+			addf("enable_pprof is only supported in websocket mode")
+		} else if c.MetricsAddr == "" {
+			addf("enable_pprof requires metrics_addr")
+		} else if c.MetricsAddr == c.ListenAddr {
+			addf("enable_pprof requires metrics_addr to differ from listen_addr")
+		}
+	}
+
+	if c.GRPC.KeepaliveTime < 0 || c.GRPC.KeepaliveTimeout < 0 || c.GRPC.MinPingInterval < 0 || c.GRPC.MaxConnectionIdle < 0 {
+		addf("grpc keepalive durations must not be negative")
+	}
+	for _, size := range []struct {
+		name  string
+		value int
+	}{
+		{"grpc.max_recv_msg_size", c.GRPC.MaxRecvMsgSize},
+		{"grpc.max_send_msg_size", c.GRPC.MaxSendMsgSize},
+	} {
+		if size.value < minMessageSizeLimit || size.value > maxMessageSizeLimit {
+			addf("%s must be between %d and %d bytes, got %d", size.name, minMessageSizeLimit, maxMessageSizeLimit, size.value)
+		}
+	}
+	if !strings.HasPrefix(c.SelfTestPath, "/") {
+		addf("self_test_path must start with /, got %q", c.SelfTestPath)
+	}
+	if c.GRPC.MaxPendingPerEdge < 0 {
+		addf("grpc.max_pending_per_edge must not be negative, got %d", c.GRPC.MaxPendingPerEdge)
+	}
+	if w := c.Webhooks; w != nil {
+		if u, err := url.Parse(w.ConnectURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("webhooks.connect_url must be an http(s) URL, got %q", w.ConnectURL)
+		}
+		if w.Timeout < 0 || w.MaxRetries < 0 {
+			addf("webhooks.timeout and webhooks.max_retries must not be negative")
+		}
+	}
+	if cc := c.Compression; cc != nil {
+		if cc.Level < 1 || cc.Level > 9 {
+			addf("compression.level must be between 1 and 9, got %d", cc.Level)
+		}
+		if cc.MinSize < 0 {
+			addf("compression.min_size must not be negative, got %d", cc.MinSize)
+		}
+		for _, t := range cc.ContentTypes {
+			if strings.Count(t, "/") != 1 || strings.HasPrefix(t, "*") {
+				addf("compression.content_types: invalid media type %q", t)
+			}
+		}
+	}
+	if c.Pages.LandingRedirect != "" {
+		if c.Pages.Landing != "" {
+			addf("pages.landing and pages.landing_redirect are mutually exclusive")
+		}
+		if u, err := url.Parse(c.Pages.LandingRedirect); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("pages.landing_redirect must be an http(s) URL, got %q", c.Pages.LandingRedirect)
+		}
+	}
+	for _, origin := range c.WebSocket.AllowedOrigins {
+		if u, err := url.Parse(origin); err != nil || u.Scheme == "" || u.Host == "" {
+			addf("websocket.allowed_origins: %q is not an origin like \"https://example.com\"", origin)
+		}
+	}
+	if c.RegistrationTimeout < 0 {
+		addf("registration_timeout must be positive, got %s", c.RegistrationTimeout.ToDuration())
+	}
+	if c.WebSocket.MessageBufferSize < 0 {
+		addf("websocket.message_buffer_size must not be negative, got %d", c.WebSocket.MessageBufferSize)
+	}
+	// Only agent tunnels read heartbeats behind a stalled delivery; the
+	// default is always below heartbeat_timeout
+	if c.WebSocket.DeliveryTimeout < 0 || (c.Mode == "websocket" && c.HeartbeatTimeout > 0 && c.WebSocket.DeliveryTimeout >= c.HeartbeatTimeout) {
+		addf("websocket.delivery_timeout must be positive and below heartbeat_timeout (%s), got %s",
+			c.HeartbeatTimeout.ToDuration(), c.WebSocket.DeliveryTimeout.ToDuration())
+	}
+	if c.WebSocket.QueueDepth < 0 {
+		addf("websocket.queue_depth must be positive, got %d", c.WebSocket.QueueDepth)
+	}
+	if c.WebSocket.QueueTimeout < 0 {
+		addf("websocket.queue_timeout must be positive, got %s", c.WebSocket.QueueTimeout.ToDuration())
+	}
+	if c.WebSocket.PingInterval != nil && *c.WebSocket.PingInterval < 0 {
+		addf("websocket.ping_interval must not be negative, got %s", c.WebSocket.PingInterval.ToDuration())
+	}
+	if c.WebSocket.PongTimeout < 0 {
+		addf("websocket.pong_timeout must be positive, got %s", c.WebSocket.PongTimeout.ToDuration())
+	}
+	if c.WebSocket.CompressionThreshold < 0 {
+		addf("websocket.compression_threshold must not be negative, got %d", c.WebSocket.CompressionThreshold)
+	}
+	if c.WebSocket.CompressionLevel < 0 || c.WebSocket.CompressionLevel > 9 {
+		addf("websocket.compression_level must be between 1 and 9, got %d", c.WebSocket.CompressionLevel)
+	}
+
+	if c.TokenSkewTolerance != nil && *c.TokenSkewTolerance < 0 {
+		addf("token_skew_tolerance must not be negative, got %s", c.TokenSkewTolerance.ToDuration())
+	}
+	if c.SlowRequestThreshold != nil && *c.SlowRequestThreshold < 0 {
+		addf("slow_request_threshold must not be negative, got %s", c.SlowRequestThreshold.ToDuration())
+	}
+
+	switch timetoken.TokenScheme(c.TokenScheme) {
+	case timetoken.SchemeAESGCM, timetoken.SchemeHMAC:
+	default:
+		addf("token_scheme must be %q or %q, got %q", timetoken.SchemeAESGCM, timetoken.SchemeHMAC, c.TokenScheme)
+	}
+
+	switch c.TLS.ClientAuthMode {
+	case ClientAuthToken:
+	case ClientAuthCert, ClientAuthCertAndToken:
+		if c.TLS.ClientCAFile == "" {
+			addf("tls.client_ca_file is required when tls.client_auth_mode is %q", c.TLS.ClientAuthMode)
+		}
+		if c.Mode != "grpc" {
+			addf("tls.client_auth_mode %q is only supported in grpc mode", c.TLS.ClientAuthMode)
+		}
+	default:
+		addf("tls.client_auth_mode must be \"token\", \"cert\" or \"cert+token\", got %q", c.TLS.ClientAuthMode)
+	}
+	if c.TLS.ClientCAFile != "" && !c.TLS.Enabled {
+		addf("tls.client_ca_file requires tls.enabled")
+	}
+
+	if c.Tracing != nil && (c.Tracing.SampleRatio < 0 || c.Tracing.SampleRatio > 1) {
+		addf("tracing.sample_ratio must be between 0 and 1, got %g", c.Tracing.SampleRatio)
+	}
+	if b := c.CircuitBreaker; b != nil {
+		if b.FailureRatio <= 0 || b.FailureRatio > 1 {
+			addf("circuit_breaker.failure_ratio must be above 0 and at most 1, got %g", b.FailureRatio)
+		}
+		if b.MinRequests < 1 {
+			addf("circuit_breaker.min_requests must be at least 1, got %d", b.MinRequests)
+		}
+		if b.Window <= 0 || b.OpenDuration <= 0 {
+			addf("circuit_breaker.window and circuit_breaker.open_duration must be positive")
+		}
+	}
+
+	problems = append(problems, c.RateLimit.validate()...)
+	problems = append(problems, c.TLS.validatePolicy()...)
+	if c.CORS != nil {
+		problems = append(problems, c.CORS.validate()...)
+	}
+	if _, err := parseIPNets("rate_limit.allowed_ips", c.RateLimit.AllowedIPs); err != nil {
+		addf("%v", err)
+	}
+	if _, err := parseIPNets("trusted_proxies", c.TrustedProxies); err != nil {
+		addf("%v", err)
+	}
+
+	apexDomains := c.ApexDomains()
+	codes := make(map[string]int)
+	subdomains := make(map[string]int)
+	for i, h := range c.Hospitals {
+		name := fmt.Sprintf("hospitals[%d]", i)
+		if h.Code != "" {
+			name = fmt.Sprintf("hospital %q", h.Code)
+		}
+
+		problems = append(problems, h.validate(name, c.Mode, apexDomains)...)
+
+		if h.Code != "" && (c.Mode != "websocket" || validHospitalCode(h.Code)) {
+			if j, dup := codes[strings.ToLower(h.Code)]; dup {
+				addf("%s: code duplicates the code or an alias of hospitals[%d]", name, j)
+			} else {
+				codes[strings.ToLower(h.Code)] = i
+			}
+		}
+		for _, alias := range h.Aliases {
+			key := strings.ToLower(alias)
+			if !validHospitalCode(key) {
+				continue
+			}
+			if j, dup := codes[key]; dup {
+				addf("%s: alias %q duplicates the code or an alias of hospitals[%d]", name, alias, j)
+			} else {
+				codes[key] = i
+			}
+		}
+		if subdomain := strings.ToLower(h.Subdomain); subdomain != "" {
+			if j, dup := subdomains[subdomain]; dup {
+				addf("%s: subdomain %q duplicates hospitals[%d]", name, h.Subdomain, j)
+			} else {
+				subdomains[subdomain] = i
+			}
+		}
+	}
+
+	if c.StartupWaitTimeout < 0 {
+		addf("startup_wait_timeout must not be negative")
+	}
+	if c.StartupWaitMinHospitals < 0 {
+		addf("startup_wait_min_hospitals must not be negative, got %d", c.StartupWaitMinHospitals)
+	}
+	// Hospitals discovered through NATS are not known until they register
+	if c.NATS == nil {
+		if c.StartupWaitMinHospitals > len(c.Hospitals) {
+			addf("startup_wait_min_hospitals is %d but only %d hospitals are configured", c.StartupWaitMinHospitals, len(c.Hospitals))
+		}
+		for _, code := range c.StartupWaitHospitals {
+			if !slices.ContainsFunc(c.Hospitals, func(h HospitalConfig) bool { return h.Code == code }) {
+				addf("startup_wait_hospitals entry %q is not a configured hospital code", code)
+			}
+		}
+	}
+
+	if len(problems) == 0 {
+		return nil
+	}
+	return fmt.Errorf("invalid configuration:\n  - %s", strings.Join(problems, "\n  - "))
+}
+
+// validate checks one hospital on its own, for static entries and hospitals
+// registered through NATS alike; duplicates across hospitals are checked by
+// the caller. name prefixes each problem.
+func (h *HospitalConfig) validate(name, mode string, apexDomains []string) []string {
+	var problems []string
+	addf := func(format string, args ...any) {
+		problems = append(problems, fmt.Sprintf(format, args...))
+	}
+
+	if h.Code == "" {
+		addf("%s: code is required", name)
+	} else if mode == "websocket" && !validHospitalCode(h.Code) {
+		addf("%s: code must be lowercase letters, digits and hyphens (at most 63)", name)
+	}
+	for _, alias := range h.Aliases {
+		if !validHospitalCode(strings.ToLower(alias)) {
+			addf("%s: alias %q must be lowercase letters, digits and hyphens (at most 63)", name, alias)
+		}
+	}
+
+	if h.Token == "" {
+		addf("%s: token is required", name)
+	}
+	if h.DownloadKey != "" && h.DownloadKey == h.Token {
+		addf("%s: download_key must differ from token", name)
+	}
+	if h.RequestTimeout < 0 || h.FetchTimeout < 0 {
+		addf("%s: request_timeout and fetch_timeout must not be negative", name)
+	}
+	if h.MaxResponseBodyBytes < 0 {
+		addf("%s: max_response_body_bytes must not be negative, got %d", name, h.MaxResponseBodyBytes)
+	}
+	if h.MaxBytesPerSec < 0 {
+		addf("%s: max_bytes_per_sec must not be negative, got %d", name, h.MaxBytesPerSec)
+	}
+	if h.MaxRequestBodyBytes < 0 {
+		addf("%s: max_request_body_bytes must not be negative, got %d", name, h.MaxRequestBodyBytes)
+	}
+	for _, m := range h.AllowedMethods {
+		if m == "" || strings.ContainsAny(m, " \t/") {
+			addf("%s: invalid allowed_methods entry %q", name, m)
+		}
+	}
+	for _, p := range h.AllowedPaths {
+		if !strings.HasPrefix(p, "/") {
+			addf("%s: allowed_paths entry %q must start with /", name, p)
+		}
+	}
+
+	subdomain := strings.ToLower(h.Subdomain)
+	switch {
+	case subdomain == "":
+		addf("%s: subdomain is required", name)
+	case len(apexDomains) > 0 && matchApexDomain(subdomain, apexDomains) == "":
+		addf("%s: subdomain %q must be under one of %q", name, h.Subdomain, apexDomains)
+	}
+	return problems
+}
+
+// addrPort returns the port of a "host:port" listen address, or "" if it has none
+func addrPort(addr string) string {
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return ""
+	}
+	return port
+}
+
+// loadDownloadKeys reads each hospital's download_key_file into DownloadKey
+func loadDownloadKeys(hospitals []HospitalConfig) error {
+	for i := range hospitals {
+		h := &hospitals[i]
+		if h.DownloadKeyFile == "" {
+			continue
+		}
+		if h.DownloadKey != "" {
+			return fmt.Errorf("hospital %q: download_key and download_key_file are mutually exclusive", h.Code)
+		}
+		data, err := os.ReadFile(h.DownloadKeyFile)
+		if err != nil {
+			return fmt.Errorf("hospital %q: failed to read download_key_file: %w", h.Code, err)
+		}
+		h.DownloadKey = strings.TrimSpace(string(data))
+		if h.DownloadKey == "" {
+			return fmt.Errorf("hospital %q: download_key_file %s is empty", h.Code, h.DownloadKeyFile)
+		}
+	}
+	return nil
+}
+
 // loadHospitalsFromEnv loads hospital configuration from environment variables
 func loadHospitalsFromEnv(config *Config) error {
 	// Try to load from hospitals.json file first (for K8s Secret mount)
@@ -128,6 +1193,9 @@ func loadHospitalsFromEnv(config *Config) error {
 				if token := os.Getenv(envKey); token != "" {
 					hospitals[i].Token = token
 				}
+				if key := os.Getenv(strings.ToUpper(hospital.Code) + "_DOWNLOAD_KEY"); key != "" {
+					hospitals[i].DownloadKey = key
+				}
 			}
 			config.Hospitals = hospitals
 			return nil
@@ -143,13 +1211,14 @@ func loadHospitalsFromEnv(config *Config) error {
 
 		if token != "" {
 			hospital := HospitalConfig{
-				Code:      code,
-				Subdomain: code + "." + config.Domain,
-				Token:     token,
+				Code:        code,
+				Subdomain:   code + "." + config.Domain,
+				Token:       token,
+				DownloadKey: os.Getenv(strings.ToUpper(code) + "_DOWNLOAD_KEY"),
 			}
 			config.Hospitals = append(config.Hospitals, hospital)
 		}
 	}
 
 	return nil
-}
\ No newline at end of file
+}
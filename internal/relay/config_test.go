@@ -0,0 +1,67 @@
+package relay
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLimitsFor(t *testing.T) {
+	cfg := &Config{
+		RequestTimeout:       Duration(5 * time.Minute),
+		IdleChunkTimeout:     Duration(time.Minute),
+		FetchTimeout:         Duration(30 * time.Second),
+		MaxRequestBodyBytes:  10 << 20,
+		MaxResponseBodyBytes: 0,
+	}
+	global := forwardLimits{
+		RequestTimeout:       5 * time.Minute,
+		IdleChunkTimeout:     time.Minute,
+		FetchTimeout:         30 * time.Second,
+		MaxRequestBodyBytes:  10 << 20,
+		MaxResponseBodyBytes: 0,
+	}
+
+	tests := []struct {
+		name     string
+		hospital *HospitalConfig
+		want     forwardLimits
+	}{
+		{"nil hospital", nil, global},
+		{"zero overrides", &HospitalConfig{Code: "ankara"}, global},
+		{
+			name: "overrides",
+			hospital: &HospitalConfig{
+				Code:                 "ankara",
+				RequestTimeout:       Duration(20 * time.Minute),
+				FetchTimeout:         Duration(2 * time.Minute),
+				MaxRequestBodyBytes:  100 << 20,
+				MaxResponseBodyBytes: 1 << 30,
+			},
+			want: forwardLimits{
+				RequestTimeout:       20 * time.Minute,
+				IdleChunkTimeout:     time.Minute, // not overridable
+				FetchTimeout:         2 * time.Minute,
+				MaxRequestBodyBytes:  100 << 20,
+				MaxResponseBodyBytes: 1 << 30,
+			},
+		},
+		{
+			name:     "partial override",
+			hospital: &HospitalConfig{Code: "ankara", FetchTimeout: Duration(time.Minute)},
+			want: forwardLimits{
+				RequestTimeout:       5 * time.Minute,
+				IdleChunkTimeout:     time.Minute,
+				FetchTimeout:         time.Minute,
+				MaxRequestBodyBytes:  10 << 20,
+				MaxResponseBodyBytes: 0,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := cfg.limitsFor(tt.hospital); got != tt.want {
+				t.Errorf("limitsFor() = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+}
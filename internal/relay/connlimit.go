@@ -0,0 +1,95 @@
+package relay
+
+import (
+	"net"
+	"sync"
+	"sync/atomic"
+)
+
+// Reasons for gordion_relay_connections_rejected_total
+const (
+	rejectMaxConnections          = "max_connections"
+	rejectMaxPendingRegistrations = "max_pending_registrations"
+)
+
+// limitListener closes connections accepted while max are already open, so a
+// flood of connections that never finish a handshake cannot exhaust memory
+// or file descriptors. The relay never stops accepting; connections beyond
+// the cap are closed right away instead of waiting in the backlog.
+type limitListener struct {
+	net.Listener
+	max  int64
+	mode string
+
+	open atomic.Int64
+}
+
+// newLimitListener caps l at max open connections; max 0 means unlimited
+func newLimitListener(l net.Listener, max int, mode string) net.Listener {
+	if max <= 0 {
+		return l
+	}
+	return &limitListener{Listener: l, max: int64(max), mode: mode}
+}
+
+func (l *limitListener) Accept() (net.Conn, error) {
+	for {
+		conn, err := l.Listener.Accept()
+		if err != nil {
+			return nil, err
+		}
+		if l.open.Add(1) > l.max {
+			l.open.Add(-1)
+			connectionsRejected.WithLabelValues(l.mode, rejectMaxConnections).Inc()
+			conn.Close()
+			continue
+		}
+		return &limitConn{Conn: conn, release: l.release}, nil
+	}
+}
+
+func (l *limitListener) release() {
+	l.open.Add(-1)
+}
+
+// limitConn gives its slot back on the first Close
+type limitConn struct {
+	net.Conn
+	release func()
+	once    sync.Once
+}
+
+func (c *limitConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(c.release)
+	return err
+}
+
+// registrationSlots bounds connections that have been accepted as tunnels
+// but not registered yet. Each holds a goroutine until its registration
+// arrives or registration_timeout passes.
+type registrationSlots struct {
+	slots chan struct{}
+}
+
+// newRegistrationSlots allows max pending registrations; max 0 means unlimited
+func newRegistrationSlots(max int) *registrationSlots {
+	if max <= 0 {
+		return &registrationSlots{}
+	}
+	return &registrationSlots{slots: make(chan struct{}, max)}
+}
+
+// tryAcquire takes a slot without waiting. ok is false when all are taken;
+// otherwise release gives the slot back and may be called more than once.
+func (r *registrationSlots) tryAcquire() (release func(), ok bool) {
+	if r.slots == nil {
+		return func() {}, true
+	}
+	select {
+	case r.slots <- struct{}{}:
+		return sync.OnceFunc(func() { <-r.slots }), true
+	default:
+		return nil, false
+	}
+}
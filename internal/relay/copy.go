@@ -0,0 +1,116 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"mime"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Bounds for the configurable copy buffer size
+const (
+	minCopyBufferSize = 4 << 10
+	maxCopyBufferSize = 4 << 20
+)
+
+// maxPooledRequestBuffer keeps buffers that held unusually large request
+// bodies from being pinned in the pool
+const maxPooledRequestBuffer = 1 << 20
+
+var (
+	// copyBuffers recycles copy buffers across requests; entries are *[]byte
+	copyBuffers sync.Pool
+
+	// requestBuffers recycles the buffers requests are serialized into
+	requestBuffers = sync.Pool{New: func() any { return new(bytes.Buffer) }}
+)
+
+// getCopyBuffer returns a pooled buffer of size bytes; release it with
+// putCopyBuffer once nothing refers to it anymore
+func getCopyBuffer(size int) *[]byte {
+	if bp, ok := copyBuffers.Get().(*[]byte); ok && cap(*bp) >= size {
+		*bp = (*bp)[:size]
+		return bp
+	}
+	buf := make([]byte, size)
+	return &buf
+}
+
+func putCopyBuffer(bp *[]byte) {
+	copyBuffers.Put(bp)
+}
+
+// getRequestBuffer returns an empty pooled buffer; release it with
+// putRequestBuffer once nothing refers to its bytes anymore
+func getRequestBuffer() *bytes.Buffer {
+	buf := requestBuffers.Get().(*bytes.Buffer)
+	buf.Reset()
+	return buf
+}
+
+func putRequestBuffer(buf *bytes.Buffer) {
+	if buf.Cap() <= maxPooledRequestBuffer {
+		requestBuffers.Put(buf)
+	}
+}
+
+// copyFlushing copies src to w through a pooled buffer of bufSize bytes,
+// flushing after every write so large downloads reach the viewer
+// progressively. progress, if set, is called with the size of each write.
+func copyFlushing(w http.ResponseWriter, src io.Reader, bufSize int, progress func(n int)) (int64, error) {
+	flusher, _ := w.(http.Flusher)
+	bp := getCopyBuffer(bufSize)
+	defer putCopyBuffer(bp)
+	buf := *bp
+
+	var written int64
+	for {
+		n, rerr := src.Read(buf)
+		if n > 0 {
+			wn, werr := w.Write(buf[:n])
+			written += int64(wn)
+			if progress != nil {
+				progress(wn)
+			}
+			if werr != nil {
+				return written, werr
+			}
+			if flusher != nil {
+				flusher.Flush()
+			}
+		}
+		if rerr == io.EOF {
+			return written, nil
+		}
+		if rerr != nil {
+			return written, rerr
+		}
+	}
+}
+
+// abortResponse ends the current handler and drops the viewer's connection.
+// A body cut off mid-stream would otherwise look complete to the viewer when
+// it is sent chunked. Deferred calls still run.
+func abortResponse() {
+	panic(http.ErrAbortHandler)
+}
+
+// isEventStream reports whether resp is a server-sent events stream
+func isEventStream(resp *http.Response) bool {
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	return mediaType == "text/event-stream"
+}
+
+// afterDone returns a channel that yields once ctx is done, for use where a
+// timer channel is expected
+func afterDone(ctx context.Context) <-chan time.Time {
+	ch := make(chan time.Time, 1)
+	go func() {
+		<-ctx.Done()
+		ch <- time.Now()
+	}()
+	return ch
+}
@@ -0,0 +1,45 @@
+package relay
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"testing"
+)
+
+// discardResponseWriter is a flushable ResponseWriter that drops the body
+type discardResponseWriter struct{ header http.Header }
+
+func (w *discardResponseWriter) Header() http.Header         { return w.header }
+func (w *discardResponseWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *discardResponseWriter) WriteHeader(int)             {}
+func (w *discardResponseWriter) Flush()                      {}
+
+// BenchmarkRelayCopy compares relaying a response body through the pooled
+// copy buffer with allocating a buffer for every response
+func BenchmarkRelayCopy(b *testing.B) {
+	const bufSize = 32 << 10
+	body := bytes.Repeat([]byte("x"), 1<<20)
+	w := &discardResponseWriter{header: make(http.Header)}
+
+	b.Run("pooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			if _, err := copyFlushing(w, bytes.NewReader(body), bufSize, nil); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+	b.Run("unpooled", func(b *testing.B) {
+		b.ReportAllocs()
+		b.SetBytes(int64(len(body)))
+		for i := 0; i < b.N; i++ {
+			buf := make([]byte, bufSize)
+			// Hide WriterTo and ReaderFrom so the buffer is used like copyFlushing's
+			if _, err := io.CopyBuffer(struct{ io.Writer }{w}, struct{ io.Reader }{bytes.NewReader(body)}, buf); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
@@ -0,0 +1,46 @@
+package relay
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// corsHandler adds the configured CORS headers to next's responses and
+// answers preflight requests itself with 204 No Content
+func corsHandler(cfg *CORSConfig, next http.HandlerFunc) http.HandlerFunc {
+	methods := strings.Join(cfg.AllowedMethods, ", ")
+	headers := strings.Join(cfg.AllowedHeaders, ", ")
+	anyOrigin := slices.Contains(cfg.AllowedOrigins, "*")
+
+	return func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		allowed := false
+		switch {
+		case anyOrigin:
+			w.Header().Set("Access-Control-Allow-Origin", "*")
+			allowed = true
+		case origin != "":
+			// The answer depends on the origin, so caches must keep them apart
+			w.Header().Add("Vary", "Origin")
+			if slices.ContainsFunc(cfg.AllowedOrigins, func(o string) bool { return strings.EqualFold(o, origin) }) {
+				w.Header().Set("Access-Control-Allow-Origin", origin)
+				allowed = true
+			}
+		}
+
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if allowed {
+				w.Header().Set("Access-Control-Allow-Methods", methods)
+				w.Header().Set("Access-Control-Allow-Headers", headers)
+				if cfg.MaxAge > 0 {
+					w.Header().Set("Access-Control-Max-Age", strconv.Itoa(int(cfg.MaxAge.ToDuration().Seconds())))
+				}
+			}
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next(w, r)
+	}
+}
@@ -0,0 +1,56 @@
+package relay
+
+import (
+	"net/http"
+	"time"
+)
+
+// requestDeadlineGrace is how long past a request's timeout the response may
+// still be written, so the relay's own timeout error can reach the viewer
+const requestDeadlineGrace = 10 * time.Second
+
+// setRequestDeadline bounds reading the rest of a viewer's request to timeout
+// and writing its response to timeout plus requestDeadlineGrace. A viewer
+// that stalls mid-body or stops reading the response then gets its
+// connection closed instead of holding a goroutine. Once the response body
+// starts, streamDeadline takes over.
+//
+// The returned func clears the write deadline so it does not carry over to
+// the next request on a keep-alive connection; net/http sets the read
+// deadline itself for every request. Errors are ignored, as after a hijack.
+func setRequestDeadline(w http.ResponseWriter, timeout time.Duration) (clear func()) {
+	rc := http.NewResponseController(w)
+	now := time.Now()
+	_ = rc.SetReadDeadline(now.Add(timeout))
+	_ = rc.SetWriteDeadline(now.Add(timeout + requestDeadlineGrace))
+	return func() {
+		_ = rc.SetWriteDeadline(time.Time{})
+	}
+}
+
+// streamDeadline replaces the request deadline while a response body is
+// relayed. The request has been read by then, so the read deadline is
+// lifted; it would otherwise cancel the request's context. With idle zero
+// (server-sent events) the write deadline is lifted as well; otherwise each
+// call to extend gives the next write idle plus requestDeadlineGrace, so a
+// download keeps going as long as the viewer keeps reading.
+type streamDeadline struct {
+	rc   *http.ResponseController
+	idle time.Duration
+}
+
+func newStreamDeadline(w http.ResponseWriter, idle time.Duration) *streamDeadline {
+	d := &streamDeadline{rc: http.NewResponseController(w), idle: idle}
+	_ = d.rc.SetReadDeadline(time.Time{})
+	if idle == 0 {
+		_ = d.rc.SetWriteDeadline(time.Time{})
+	}
+	return d
+}
+
+// extend re-arms the write deadline before a write
+func (d *streamDeadline) extend() {
+	if d.idle > 0 {
+		_ = d.rc.SetWriteDeadline(time.Now().Add(d.idle + requestDeadlineGrace))
+	}
+}
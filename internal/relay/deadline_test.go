@@ -0,0 +1,69 @@
+package relay
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRequestDeadlineClosesStalledRequest(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer setRequestDeadline(w, 200*time.Millisecond)()
+		if _, err := io.ReadAll(r.Body); err == nil {
+			t.Error("reading a stalled body succeeded")
+		}
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// Announce 100 bytes and send half of them
+	_, err = io.WriteString(conn, "POST / HTTP/1.1\r\nHost: test\r\nContent-Length: 100\r\n\r\n"+strings.Repeat("x", 50))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, err = io.Copy(io.Discard, conn)
+	var ne net.Error
+	if errors.As(err, &ne) && ne.Timeout() {
+		t.Fatal("server did not close the connection of a stalled request")
+	}
+}
+
+func TestStreamDeadlineLiftsRequestDeadline(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		defer setRequestDeadline(w, 100*time.Millisecond)()
+		// Stand in for the grace period, which is too long for a test
+		_ = http.NewResponseController(w).SetWriteDeadline(time.Now().Add(100 * time.Millisecond))
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		newStreamDeadline(w, 0)
+		// Stay idle past both deadlines, as an event stream may
+		time.Sleep(300 * time.Millisecond)
+		if r.Context().Err() != nil {
+			t.Error("request context canceled by the read deadline")
+		}
+		_, _ = io.WriteString(w, "data: late\n\n")
+	}))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "data: late\n" {
+		t.Fatalf("got %q, %v; want the event written after the request timeout", line, err)
+	}
+}
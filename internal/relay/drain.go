@@ -0,0 +1,22 @@
+package relay
+
+import (
+	"sync"
+	"time"
+)
+
+// waitTimeout waits for wg up to timeout and reports whether it finished in time
+func waitTimeout(wg *sync.WaitGroup, timeout time.Duration) bool {
+	done := make(chan struct{})
+	go func() {
+		wg.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return true
+	case <-time.After(timeout):
+		return false
+	}
+}
@@ -0,0 +1,146 @@
+package relay
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Connection event types
+const (
+	EventConnected    = "connected"
+	EventDisconnected = "disconnected"
+)
+
+// Event reports a hospital connecting to or disconnecting from the relay.
+// A replaced connection produces only a new "connected" event; "disconnected"
+// means the hospital has no connection left. In gRPC mode "connected" is sent
+// when a hospital's first edge registers, not for further edges or replaced
+// ones.
+type Event struct {
+	Hospital   string    `json:"hospital"`
+	Event      string    `json:"event"`
+	Timestamp  time.Time `json:"timestamp"`
+	RemoteAddr string    `json:"remote_addr,omitempty"`
+}
+
+// eventBufferSize is the per-subscriber queue; a subscriber that falls
+// further behind misses events rather than delaying registrations
+const eventBufferSize = 256
+
+// eventHub fans connection events out to in-process subscribers
+type eventHub struct {
+	logger *slog.Logger
+
+	mu          sync.Mutex
+	subscribers map[chan Event]struct{}
+}
+
+func newEventHub(logger *slog.Logger) *eventHub {
+	return &eventHub{
+		logger:      logger,
+		subscribers: make(map[chan Event]struct{}),
+	}
+}
+
+// subscribe returns a channel receiving every later event and a function
+// that unsubscribes and closes it
+func (h *eventHub) subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, eventBufferSize)
+	h.mu.Lock()
+	h.subscribers[ch] = struct{}{}
+	h.mu.Unlock()
+
+	var once sync.Once
+	return ch, func() {
+		once.Do(func() {
+			h.mu.Lock()
+			delete(h.subscribers, ch)
+			h.mu.Unlock()
+			close(ch)
+		})
+	}
+}
+
+// publish delivers an event to every subscriber without blocking
+func (h *eventHub) publish(hospital, event, remoteAddr string) {
+	e := Event{Hospital: hospital, Event: event, Timestamp: time.Now().UTC(), RemoteAddr: remoteAddr}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers {
+		select {
+		case ch <- e:
+		default:
+			h.logger.Warn("Dropping connection event for slow subscriber", "hospital_code", hospital, "event", event)
+		}
+	}
+}
+
+// WebhooksConfig holds outbound notification settings
+type WebhooksConfig struct {
+	ConnectURL string   `json:"connect_url"`           // Receives a POST with an Event on every connect/disconnect
+	Timeout    Duration `json:"timeout,omitempty"`     // Per attempt (default: 5s)
+	MaxRetries int      `json:"max_retries,omitempty"` // Attempts after the first failure (default: 3)
+}
+
+// runConnectWebhook POSTs every event to cfg.ConnectURL until ctx is done.
+// Events are sent one at a time with bounded retries; the hub drops events
+// while the endpoint is slow, so registrations are never delayed.
+func runConnectWebhook(ctx context.Context, cfg *WebhooksConfig, hub *eventHub, logger *slog.Logger) {
+	events, unsubscribe := hub.subscribe()
+	defer unsubscribe()
+
+	client := &http.Client{Timeout: cfg.Timeout.ToDuration()}
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case e := <-events:
+			if err := postEvent(ctx, client, cfg, e); err != nil {
+				logger.Warn("Connection webhook failed", "hospital_code", e.Hospital, "event", e.Event, "error", err)
+			}
+		}
+	}
+}
+
+// postEvent sends one event, retrying with a growing delay
+func postEvent(ctx context.Context, client *http.Client, cfg *WebhooksConfig, e Event) error {
+	body, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	var lastErr error
+	for attempt := 0; attempt <= cfg.MaxRetries; attempt++ {
+		if attempt > 0 {
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(time.Duration(attempt) * time.Second):
+			}
+		}
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodPost, cfg.ConnectURL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		resp, err := client.Do(req)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+		resp.Body.Close()
+		if resp.StatusCode < 300 {
+			return nil
+		}
+		lastErr = fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return fmt.Errorf("giving up after %d attempts: %w", cfg.MaxRetries+1, lastErr)
+}
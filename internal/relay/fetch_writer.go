@@ -0,0 +1,211 @@
+package relay
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"strings"
+)
+
+// Fetch levels understood by the edge (FetchCommand.Type)
+const (
+	fetchLevelInstance = "instance"
+	fetchLevelSeries   = "series"
+	fetchLevelStudy    = "study"
+)
+
+// fetchTarget is what a viewer asked to download
+type fetchTarget struct {
+	Level       string
+	StudyUID    string
+	SeriesUID   string
+	InstanceUID string
+
+	// Passed through to the edge: the path after the last UID and the
+	// query string without the download token
+	SubPath string
+	Query   string
+
+	// Byte range of a single instance; nil for the whole file
+	Range *byteRange
+}
+
+// UID returns the UID of the requested level
+func (t fetchTarget) UID() string {
+	switch t.Level {
+	case fetchLevelStudy:
+		return t.StudyUID
+	case fetchLevelSeries:
+		return t.SeriesUID
+	default:
+		return t.InstanceUID
+	}
+}
+
+// parseFetchPath extracts the fetch level and UIDs from a request path.
+// Supported formats (each optionally prefixed with /api):
+// - /instances/{uid}[/download]
+// - /series/{uid}[/...]
+// - /studies/{uid}[/...]
+// - /studies/{uid}/series/{uid}[/instances/{uid}][/...]
+// The deepest level named in the path wins; trailing segments such as
+// /download or /frames/3 are kept as SubPath. ok is false if no UID was found.
+func parseFetchPath(path string) (target fetchTarget, ok bool) {
+	parts := strings.Split(strings.Trim(path, "/"), "/")
+	if len(parts) > 0 && parts[0] == "api" {
+		parts = parts[1:]
+	}
+
+	for i := 0; i+1 < len(parts); i += 2 {
+		uid := parts[i+1]
+		if uid == "" {
+			return fetchTarget{}, false
+		}
+		switch parts[i] {
+		case "studies":
+			target.Level, target.StudyUID = fetchLevelStudy, uid
+		case "series":
+			target.Level, target.SeriesUID = fetchLevelSeries, uid
+		case "instances":
+			target.Level, target.InstanceUID = fetchLevelInstance, uid
+		default:
+			// Trailing segments such as /frames/3 end the path
+			target.SubPath = "/" + strings.Join(parts[i:], "/")
+			return target, target.Level != ""
+		}
+	}
+
+	// A single trailing segment such as /download
+	if len(parts)%2 == 1 && parts[len(parts)-1] != "" {
+		target.SubPath = "/" + parts[len(parts)-1]
+	}
+	return target, target.Level != ""
+}
+
+// stripQueryParam removes every name parameter from a raw query string,
+// keeping the others as sent and in their order
+func stripQueryParam(rawQuery, name string) string {
+	if rawQuery == "" {
+		return ""
+	}
+	kept := make([]string, 0, strings.Count(rawQuery, "&")+1)
+	for _, param := range strings.Split(rawQuery, "&") {
+		key, _, _ := strings.Cut(param, "=")
+		if k, err := url.QueryUnescape(key); err == nil && k == name {
+			continue
+		}
+		if param != "" {
+			kept = append(kept, param)
+		}
+	}
+	return strings.Join(kept, "&")
+}
+
+// instanceWriter receives the instances of a fetch in order. BeginInstance
+// is called before the data of each instance and Close after the last one.
+type instanceWriter interface {
+	io.Writer
+	BeginInstance(instanceUID string) error
+	Close() error
+}
+
+// rawInstanceWriter passes a single instance through unchanged
+type rawInstanceWriter struct {
+	io.Writer
+}
+
+func (rawInstanceWriter) BeginInstance(string) error { return nil }
+func (rawInstanceWriter) Close() error               { return nil }
+
+// multipartInstanceWriter writes each instance as an application/dicom part
+// of a multipart/related body
+type multipartInstanceWriter struct {
+	mw   *multipart.Writer
+	part io.Writer
+}
+
+func newMultipartInstanceWriter(w io.Writer, boundary string) (*multipartInstanceWriter, error) {
+	mw := multipart.NewWriter(w)
+	if err := mw.SetBoundary(boundary); err != nil {
+		return nil, err
+	}
+	return &multipartInstanceWriter{mw: mw}, nil
+}
+
+func (m *multipartInstanceWriter) BeginInstance(instanceUID string) error {
+	header := textproto.MIMEHeader{}
+	header.Set("Content-Type", "application/dicom")
+	if instanceUID != "" {
+		header.Set("Content-Location", instanceUID)
+	}
+	part, err := m.mw.CreatePart(header)
+	if err != nil {
+		return err
+	}
+	m.part = part
+	return nil
+}
+
+func (m *multipartInstanceWriter) Write(p []byte) (int, error) {
+	if m.part == nil {
+		return 0, fmt.Errorf("multipart: data before first instance")
+	}
+	return m.part.Write(p)
+}
+
+func (m *multipartInstanceWriter) Close() error {
+	return m.mw.Close()
+}
+
+// zipInstanceWriter stores each instance as {uid}.dcm in a zip archive.
+// DICOM pixel data is usually compressed already, so entries are stored as-is.
+type zipInstanceWriter struct {
+	zw    *zip.Writer
+	entry io.Writer
+	count int
+}
+
+func newZipInstanceWriter(w io.Writer) *zipInstanceWriter {
+	return &zipInstanceWriter{zw: zip.NewWriter(w)}
+}
+
+func (z *zipInstanceWriter) BeginInstance(instanceUID string) error {
+	z.count++
+	name := instanceUID
+	if name == "" {
+		name = fmt.Sprintf("instance-%d", z.count)
+	}
+	entry, err := z.zw.CreateHeader(&zip.FileHeader{Name: name + ".dcm", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	z.entry = entry
+	return nil
+}
+
+func (z *zipInstanceWriter) Write(p []byte) (int, error) {
+	if z.entry == nil {
+		return 0, fmt.Errorf("zip: data before first instance")
+	}
+	return z.entry.Write(p)
+}
+
+func (z *zipInstanceWriter) Close() error {
+	return z.zw.Close()
+}
+
+// wantsZip reports whether a multi-instance response should be a zip archive
+// rather than multipart/related. ?format= takes precedence over Accept.
+func wantsZip(r *http.Request) bool {
+	switch strings.ToLower(r.URL.Query().Get("format")) {
+	case "zip":
+		return true
+	case "multipart":
+		return false
+	}
+	return strings.Contains(r.Header.Get("Accept"), "application/zip")
+}
@@ -30,6 +30,7 @@ type EdgeMessage struct {
 	//	*EdgeMessage_Data
 	//	*EdgeMessage_Keepalive
 	//	*EdgeMessage_Status
+	//	*EdgeMessage_Goodbye
 	Message       isEdgeMessage_Message `protobuf_oneof:"message"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -108,6 +109,15 @@ func (x *EdgeMessage) GetStatus() *StatusUpdate {
 	return nil
 }
 
+func (x *EdgeMessage) GetGoodbye() *Goodbye {
+	if x != nil {
+		if x, ok := x.Message.(*EdgeMessage_Goodbye); ok {
+			return x.Goodbye
+		}
+	}
+	return nil
+}
+
 type isEdgeMessage_Message interface {
 	isEdgeMessage_Message()
 }
@@ -128,6 +138,10 @@ type EdgeMessage_Status struct {
 	Status *StatusUpdate `protobuf:"bytes,4,opt,name=status,proto3,oneof"`
 }
 
+type EdgeMessage_Goodbye struct {
+	Goodbye *Goodbye `protobuf:"bytes,5,opt,name=goodbye,proto3,oneof"`
+}
+
 func (*EdgeMessage_Register) isEdgeMessage_Message() {}
 
 func (*EdgeMessage_Data) isEdgeMessage_Message() {}
@@ -136,6 +150,8 @@ func (*EdgeMessage_Keepalive) isEdgeMessage_Message() {}
 
 func (*EdgeMessage_Status) isEdgeMessage_Message() {}
 
+func (*EdgeMessage_Goodbye) isEdgeMessage_Message() {}
+
 // RelayMessage - messages sent FROM relay TO edge
 type RelayMessage struct {
 	state protoimpl.MessageState `protogen:"open.v1"`
@@ -144,6 +160,7 @@ type RelayMessage struct {
 	//	*RelayMessage_RegisterAck
 	//	*RelayMessage_Command
 	//	*RelayMessage_Keepalive
+	//	*RelayMessage_Cancel
 	Message       isRelayMessage_Message `protobuf_oneof:"message"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
@@ -213,6 +230,15 @@ func (x *RelayMessage) GetKeepalive() *KeepAlive {
 	return nil
 }
 
+func (x *RelayMessage) GetCancel() *CancelCommand {
+	if x != nil {
+		if x, ok := x.Message.(*RelayMessage_Cancel); ok {
+			return x.Cancel
+		}
+	}
+	return nil
+}
+
 type isRelayMessage_Message interface {
 	isRelayMessage_Message()
 }
@@ -229,12 +255,18 @@ type RelayMessage_Keepalive struct {
 	Keepalive *KeepAlive `protobuf:"bytes,3,opt,name=keepalive,proto3,oneof"`
 }
 
+type RelayMessage_Cancel struct {
+	Cancel *CancelCommand `protobuf:"bytes,4,opt,name=cancel,proto3,oneof"`
+}
+
 func (*RelayMessage_RegisterAck) isRelayMessage_Message() {}
 
 func (*RelayMessage_Command) isRelayMessage_Message() {}
 
 func (*RelayMessage_Keepalive) isRelayMessage_Message() {}
 
+func (*RelayMessage_Cancel) isRelayMessage_Message() {}
+
 // RegisterRequest - edge registers with relay on connection
 type RegisterRequest struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -310,6 +342,7 @@ type RegisterResponse struct {
 	Success       bool                   `protobuf:"varint,1,opt,name=success,proto3" json:"success,omitempty"`
 	Message       string                 `protobuf:"bytes,2,opt,name=message,proto3" json:"message,omitempty"`                          // Error message if !success
 	ServerTime    int64                  `protobuf:"varint,3,opt,name=server_time,json=serverTime,proto3" json:"server_time,omitempty"` // Unix timestamp for clock sync
+	Code          string                 `protobuf:"bytes,4,opt,name=code,proto3" json:"code,omitempty"`                                // Machine-readable error code if !success, e.g. "AUTH_INVALID_TOKEN"
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -365,6 +398,13 @@ func (x *RegisterResponse) GetServerTime() int64 {
 	return 0
 }
 
+func (x *RegisterResponse) GetCode() string {
+	if x != nil {
+		return x.Code
+	}
+	return ""
+}
+
 // FetchCommand - relay requests DICOM instance(s)
 type FetchCommand struct {
 	state     protoimpl.MessageState `protogen:"open.v1"`
@@ -375,7 +415,24 @@ type FetchCommand struct {
 	SeriesUid   string `protobuf:"bytes,4,opt,name=series_uid,json=seriesUid,proto3" json:"series_uid,omitempty"`
 	StudyUid    string `protobuf:"bytes,5,opt,name=study_uid,json=studyUid,proto3" json:"study_uid,omitempty"`
 	// Resume support
-	ResumeFrom    string `protobuf:"bytes,6,opt,name=resume_from,json=resumeFrom,proto3" json:"resume_from,omitempty"` // Instance UID to resume from (optional)
+	ResumeFrom string `protobuf:"bytes,6,opt,name=resume_from,json=resumeFrom,proto3" json:"resume_from,omitempty"` // Instance UID to resume from (optional)
+	// Optional request metadata: trace context (W3C traceparent/tracestate)
+	// and the viewer address (x-forwarded-for, x-real-ip)
+	Metadata map[string]string `protobuf:"bytes,7,rep,name=metadata,proto3" json:"metadata,omitempty" protobuf_key:"bytes,1,opt,name=key" protobuf_val:"bytes,2,opt,name=value"`
+	// Answer a HEAD request: send a DataStart (with file_size) per instance,
+	// then DataComplete, but no DataChunk. Edges that ignore this flag send
+	// the data as usual and the relay discards it.
+	MetadataOnly bool `protobuf:"varint,8,opt,name=metadata_only,json=metadataOnly,proto3" json:"metadata_only,omitempty"`
+	// What the viewer asked for beyond the UIDs, for edges that honor it:
+	// the path after the last UID (e.g. "/frames/3" or "/download") and the
+	// raw query string without the relay's download token (e.g.
+	// "transferSyntax=1.2.840.10008.1.2.1"). URL fragments never reach the relay.
+	SubPath string `protobuf:"bytes,9,opt,name=sub_path,json=subPath,proto3" json:"sub_path,omitempty"`
+	Query   string `protobuf:"bytes,10,opt,name=query,proto3" json:"query,omitempty"`
+	// Part of a single instance requested with an HTTP Range header. Edges
+	// that honor it send only those bytes and set DataStart.offset; edges that
+	// ignore it send the whole file and the relay cuts out the range.
+	ByteRange     *ByteRange `protobuf:"bytes,11,opt,name=byte_range,json=byteRange,proto3" json:"byte_range,omitempty"`
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
@@ -452,6 +509,161 @@ func (x *FetchCommand) GetResumeFrom() string {
 	return ""
 }
 
+func (x *FetchCommand) GetMetadata() map[string]string {
+	if x != nil {
+		return x.Metadata
+	}
+	return nil
+}
+
+func (x *FetchCommand) GetMetadataOnly() bool {
+	if x != nil {
+		return x.MetadataOnly
+	}
+	return false
+}
+
+func (x *FetchCommand) GetSubPath() string {
+	if x != nil {
+		return x.SubPath
+	}
+	return ""
+}
+
+func (x *FetchCommand) GetQuery() string {
+	if x != nil {
+		return x.Query
+	}
+	return ""
+}
+
+func (x *FetchCommand) GetByteRange() *ByteRange {
+	if x != nil {
+		return x.ByteRange
+	}
+	return nil
+}
+
+// ByteRange - a byte range of one file (HTTP "bytes=start-end" or "bytes=-suffix_length")
+type ByteRange struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Start         int64                  `protobuf:"varint,1,opt,name=start,proto3" json:"start,omitempty"`                                   // First byte, 0-based
+	End           int64                  `protobuf:"varint,2,opt,name=end,proto3" json:"end,omitempty"`                                       // Last byte, inclusive; -1 for the end of the file
+	SuffixLength  int64                  `protobuf:"varint,3,opt,name=suffix_length,json=suffixLength,proto3" json:"suffix_length,omitempty"` // If > 0, the last suffix_length bytes instead of start/end
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *ByteRange) Reset() {
+	*x = ByteRange{}
+	mi := &file_tunnel_proto_msgTypes[5]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *ByteRange) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*ByteRange) ProtoMessage() {}
+
+func (x *ByteRange) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[5]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use ByteRange.ProtoReflect.Descriptor instead.
+func (*ByteRange) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{5}
+}
+
+func (x *ByteRange) GetStart() int64 {
+	if x != nil {
+		return x.Start
+	}
+	return 0
+}
+
+func (x *ByteRange) GetEnd() int64 {
+	if x != nil {
+		return x.End
+	}
+	return 0
+}
+
+func (x *ByteRange) GetSuffixLength() int64 {
+	if x != nil {
+		return x.SuffixLength
+	}
+	return 0
+}
+
+// CancelCommand - relay aborts an in-flight FetchCommand
+//
+// Sent when the viewer goes away before the transfer completes. The edge
+// should stop streaming DataResponse messages for request_id as soon as
+// possible; any messages already in flight are discarded by the relay. The
+// edge must not send DataComplete or DataError for a cancelled request (they
+// are ignored if it does).
+type CancelCommand struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	RequestId     string                 `protobuf:"bytes,1,opt,name=request_id,json=requestId,proto3" json:"request_id,omitempty"` // Matches FetchCommand.request_id
+	Reason        string                 `protobuf:"bytes,2,opt,name=reason,proto3" json:"reason,omitempty"`                        // e.g. "client disconnected"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *CancelCommand) Reset() {
+	*x = CancelCommand{}
+	mi := &file_tunnel_proto_msgTypes[6]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *CancelCommand) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*CancelCommand) ProtoMessage() {}
+
+func (x *CancelCommand) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[6]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use CancelCommand.ProtoReflect.Descriptor instead.
+func (*CancelCommand) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{6}
+}
+
+func (x *CancelCommand) GetRequestId() string {
+	if x != nil {
+		return x.RequestId
+	}
+	return ""
+}
+
+func (x *CancelCommand) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
 // DataResponse - edge sends DICOM data
 type DataResponse struct {
 	state     protoimpl.MessageState `protogen:"open.v1"`
@@ -469,7 +681,7 @@ type DataResponse struct {
 
 func (x *DataResponse) Reset() {
 	*x = DataResponse{}
-	mi := &file_tunnel_proto_msgTypes[5]
+	mi := &file_tunnel_proto_msgTypes[7]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -481,7 +693,7 @@ func (x *DataResponse) String() string {
 func (*DataResponse) ProtoMessage() {}
 
 func (x *DataResponse) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[5]
+	mi := &file_tunnel_proto_msgTypes[7]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -494,7 +706,7 @@ func (x *DataResponse) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DataResponse.ProtoReflect.Descriptor instead.
 func (*DataResponse) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{5}
+	return file_tunnel_proto_rawDescGZIP(), []int{7}
 }
 
 func (x *DataResponse) GetRequestId() string {
@@ -583,13 +795,14 @@ type DataStart struct {
 	Chunked       bool                   `protobuf:"varint,3,opt,name=chunked,proto3" json:"chunked,omitempty"`                         // true if file will be sent in chunks
 	ChunkCount    int32                  `protobuf:"varint,4,opt,name=chunk_count,json=chunkCount,proto3" json:"chunk_count,omitempty"` // Number of chunks (if chunked)
 	Sequence      int32                  `protobuf:"varint,5,opt,name=sequence,proto3" json:"sequence,omitempty"`                       // Sequence in multi-instance response (0-based)
+	Offset        int64                  `protobuf:"varint,6,opt,name=offset,proto3" json:"offset,omitempty"`                           // File offset of the first byte sent (FetchCommand.byte_range); file_size stays the whole file
 	unknownFields protoimpl.UnknownFields
 	sizeCache     protoimpl.SizeCache
 }
 
 func (x *DataStart) Reset() {
 	*x = DataStart{}
-	mi := &file_tunnel_proto_msgTypes[6]
+	mi := &file_tunnel_proto_msgTypes[8]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -601,7 +814,7 @@ func (x *DataStart) String() string {
 func (*DataStart) ProtoMessage() {}
 
 func (x *DataStart) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[6]
+	mi := &file_tunnel_proto_msgTypes[8]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -614,7 +827,7 @@ func (x *DataStart) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DataStart.ProtoReflect.Descriptor instead.
 func (*DataStart) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{6}
+	return file_tunnel_proto_rawDescGZIP(), []int{8}
 }
 
 func (x *DataStart) GetInstanceUid() string {
@@ -652,6 +865,13 @@ func (x *DataStart) GetSequence() int32 {
 	return 0
 }
 
+func (x *DataStart) GetOffset() int64 {
+	if x != nil {
+		return x.Offset
+	}
+	return 0
+}
+
 // DataChunk - file data (whole or partial)
 type DataChunk struct {
 	state         protoimpl.MessageState `protogen:"open.v1"`
@@ -666,7 +886,7 @@ type DataChunk struct {
 
 func (x *DataChunk) Reset() {
 	*x = DataChunk{}
-	mi := &file_tunnel_proto_msgTypes[7]
+	mi := &file_tunnel_proto_msgTypes[9]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -678,7 +898,7 @@ func (x *DataChunk) String() string {
 func (*DataChunk) ProtoMessage() {}
 
 func (x *DataChunk) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[7]
+	mi := &file_tunnel_proto_msgTypes[9]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -691,7 +911,7 @@ func (x *DataChunk) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DataChunk.ProtoReflect.Descriptor instead.
 func (*DataChunk) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{7}
+	return file_tunnel_proto_rawDescGZIP(), []int{9}
 }
 
 func (x *DataChunk) GetInstanceUid() string {
@@ -740,7 +960,7 @@ type DataComplete struct {
 
 func (x *DataComplete) Reset() {
 	*x = DataComplete{}
-	mi := &file_tunnel_proto_msgTypes[8]
+	mi := &file_tunnel_proto_msgTypes[10]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -752,7 +972,7 @@ func (x *DataComplete) String() string {
 func (*DataComplete) ProtoMessage() {}
 
 func (x *DataComplete) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[8]
+	mi := &file_tunnel_proto_msgTypes[10]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -765,7 +985,7 @@ func (x *DataComplete) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DataComplete.ProtoReflect.Descriptor instead.
 func (*DataComplete) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{8}
+	return file_tunnel_proto_rawDescGZIP(), []int{10}
 }
 
 func (x *DataComplete) GetInstanceCount() int32 {
@@ -794,7 +1014,7 @@ type DataError struct {
 
 func (x *DataError) Reset() {
 	*x = DataError{}
-	mi := &file_tunnel_proto_msgTypes[9]
+	mi := &file_tunnel_proto_msgTypes[11]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -806,7 +1026,7 @@ func (x *DataError) String() string {
 func (*DataError) ProtoMessage() {}
 
 func (x *DataError) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[9]
+	mi := &file_tunnel_proto_msgTypes[11]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -819,7 +1039,7 @@ func (x *DataError) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use DataError.ProtoReflect.Descriptor instead.
 func (*DataError) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{9}
+	return file_tunnel_proto_rawDescGZIP(), []int{11}
 }
 
 func (x *DataError) GetErrorCode() string {
@@ -854,7 +1074,7 @@ type KeepAlive struct {
 
 func (x *KeepAlive) Reset() {
 	*x = KeepAlive{}
-	mi := &file_tunnel_proto_msgTypes[10]
+	mi := &file_tunnel_proto_msgTypes[12]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -866,7 +1086,7 @@ func (x *KeepAlive) String() string {
 func (*KeepAlive) ProtoMessage() {}
 
 func (x *KeepAlive) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[10]
+	mi := &file_tunnel_proto_msgTypes[12]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -879,7 +1099,7 @@ func (x *KeepAlive) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use KeepAlive.ProtoReflect.Descriptor instead.
 func (*KeepAlive) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{10}
+	return file_tunnel_proto_rawDescGZIP(), []int{12}
 }
 
 func (x *KeepAlive) GetTimestamp() int64 {
@@ -909,7 +1129,7 @@ type StatusUpdate struct {
 
 func (x *StatusUpdate) Reset() {
 	*x = StatusUpdate{}
-	mi := &file_tunnel_proto_msgTypes[11]
+	mi := &file_tunnel_proto_msgTypes[13]
 	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 	ms.StoreMessageInfo(mi)
 }
@@ -921,7 +1141,7 @@ func (x *StatusUpdate) String() string {
 func (*StatusUpdate) ProtoMessage() {}
 
 func (x *StatusUpdate) ProtoReflect() protoreflect.Message {
-	mi := &file_tunnel_proto_msgTypes[11]
+	mi := &file_tunnel_proto_msgTypes[13]
 	if x != nil {
 		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
 		if ms.LoadMessageInfo() == nil {
@@ -934,7 +1154,7 @@ func (x *StatusUpdate) ProtoReflect() protoreflect.Message {
 
 // Deprecated: Use StatusUpdate.ProtoReflect.Descriptor instead.
 func (*StatusUpdate) Descriptor() ([]byte, []int) {
-	return file_tunnel_proto_rawDescGZIP(), []int{11}
+	return file_tunnel_proto_rawDescGZIP(), []int{13}
 }
 
 func (x *StatusUpdate) GetTimestamp() int64 {
@@ -965,33 +1185,81 @@ func (x *StatusUpdate) GetHealthy() bool {
 	return false
 }
 
+// Goodbye - edge announces a clean shutdown before closing the stream
+type Goodbye struct {
+	state         protoimpl.MessageState `protogen:"open.v1"`
+	Reason        string                 `protobuf:"bytes,1,opt,name=reason,proto3" json:"reason,omitempty"` // e.g. "shutdown", "restart"
+	unknownFields protoimpl.UnknownFields
+	sizeCache     protoimpl.SizeCache
+}
+
+func (x *Goodbye) Reset() {
+	*x = Goodbye{}
+	mi := &file_tunnel_proto_msgTypes[14]
+	ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+	ms.StoreMessageInfo(mi)
+}
+
+func (x *Goodbye) String() string {
+	return protoimpl.X.MessageStringOf(x)
+}
+
+func (*Goodbye) ProtoMessage() {}
+
+func (x *Goodbye) ProtoReflect() protoreflect.Message {
+	mi := &file_tunnel_proto_msgTypes[14]
+	if x != nil {
+		ms := protoimpl.X.MessageStateOf(protoimpl.Pointer(x))
+		if ms.LoadMessageInfo() == nil {
+			ms.StoreMessageInfo(mi)
+		}
+		return ms
+	}
+	return mi.MessageOf(x)
+}
+
+// Deprecated: Use Goodbye.ProtoReflect.Descriptor instead.
+func (*Goodbye) Descriptor() ([]byte, []int) {
+	return file_tunnel_proto_rawDescGZIP(), []int{14}
+}
+
+func (x *Goodbye) GetReason() string {
+	if x != nil {
+		return x.Reason
+	}
+	return ""
+}
+
 var File_tunnel_proto protoreflect.FileDescriptor
 
 const file_tunnel_proto_rawDesc = "" +
 	"\n" +
-	"\ftunnel.proto\x12\x06tunnel\"\xde\x01\n" +
+	"\ftunnel.proto\x12\x06tunnel\"\x8b\x02\n" +
 	"\vEdgeMessage\x125\n" +
 	"\bregister\x18\x01 \x01(\v2\x17.tunnel.RegisterRequestH\x00R\bregister\x12*\n" +
 	"\x04data\x18\x02 \x01(\v2\x14.tunnel.DataResponseH\x00R\x04data\x121\n" +
 	"\tkeepalive\x18\x03 \x01(\v2\x11.tunnel.KeepAliveH\x00R\tkeepalive\x12.\n" +
-	"\x06status\x18\x04 \x01(\v2\x14.tunnel.StatusUpdateH\x00R\x06statusB\t\n" +
-	"\amessage\"\xbd\x01\n" +
+	"\x06status\x18\x04 \x01(\v2\x14.tunnel.StatusUpdateH\x00R\x06status\x12+\n" +
+	"\agoodbye\x18\x05 \x01(\v2\x0f.tunnel.GoodbyeH\x00R\agoodbyeB\t\n" +
+	"\amessage\"\xee\x01\n" +
 	"\fRelayMessage\x12=\n" +
 	"\fregister_ack\x18\x01 \x01(\v2\x18.tunnel.RegisterResponseH\x00R\vregisterAck\x120\n" +
 	"\acommand\x18\x02 \x01(\v2\x14.tunnel.FetchCommandH\x00R\acommand\x121\n" +
-	"\tkeepalive\x18\x03 \x01(\v2\x11.tunnel.KeepAliveH\x00R\tkeepaliveB\t\n" +
+	"\tkeepalive\x18\x03 \x01(\v2\x11.tunnel.KeepAliveH\x00R\tkeepalive\x12/\n" +
+	"\x06cancel\x18\x04 \x01(\v2\x15.tunnel.CancelCommandH\x00R\x06cancelB\t\n" +
 	"\amessage\"\x88\x01\n" +
 	"\x0fRegisterRequest\x12\x1f\n" +
 	"\vhospital_id\x18\x01 \x01(\tR\n" +
 	"hospitalId\x12$\n" +
 	"\x0eedge_server_id\x18\x02 \x01(\tR\fedgeServerId\x12\x18\n" +
 	"\aversion\x18\x03 \x01(\tR\aversion\x12\x14\n" +
-	"\x05token\x18\x04 \x01(\tR\x05token\"g\n" +
+	"\x05token\x18\x04 \x01(\tR\x05token\"{\n" +
 	"\x10RegisterResponse\x12\x18\n" +
 	"\asuccess\x18\x01 \x01(\bR\asuccess\x12\x18\n" +
 	"\amessage\x18\x02 \x01(\tR\amessage\x12\x1f\n" +
 	"\vserver_time\x18\x03 \x01(\x03R\n" +
-	"serverTime\"\xc1\x01\n" +
+	"serverTime\x12\x12\n" +
+	"\x04code\x18\x04 \x01(\tR\x04code\"\xc6\x03\n" +
 	"\fFetchCommand\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x01 \x01(\tR\trequestId\x12\x12\n" +
@@ -1001,7 +1269,25 @@ const file_tunnel_proto_rawDesc = "" +
 	"series_uid\x18\x04 \x01(\tR\tseriesUid\x12\x1b\n" +
 	"\tstudy_uid\x18\x05 \x01(\tR\bstudyUid\x12\x1f\n" +
 	"\vresume_from\x18\x06 \x01(\tR\n" +
-	"resumeFrom\"\xed\x01\n" +
+	"resumeFrom\x12>\n" +
+	"\bmetadata\x18\a \x03(\v2\".tunnel.FetchCommand.MetadataEntryR\bmetadata\x12#\n" +
+	"\rmetadata_only\x18\b \x01(\bR\fmetadataOnly\x12\x19\n" +
+	"\bsub_path\x18\t \x01(\tR\asubPath\x12\x14\n" +
+	"\x05query\x18\n" +
+	" \x01(\tR\x05query\x120\n" +
+	"\n" +
+	"byte_range\x18\v \x01(\v2\x11.tunnel.ByteRangeR\tbyteRange\x1a;\n" +
+	"\rMetadataEntry\x12\x10\n" +
+	"\x03key\x18\x01 \x01(\tR\x03key\x12\x14\n" +
+	"\x05value\x18\x02 \x01(\tR\x05value:\x028\x01\"X\n" +
+	"\tByteRange\x12\x14\n" +
+	"\x05start\x18\x01 \x01(\x03R\x05start\x12\x10\n" +
+	"\x03end\x18\x02 \x01(\x03R\x03end\x12#\n" +
+	"\rsuffix_length\x18\x03 \x01(\x03R\fsuffixLength\"F\n" +
+	"\rCancelCommand\x12\x1d\n" +
+	"\n" +
+	"request_id\x18\x01 \x01(\tR\trequestId\x12\x16\n" +
+	"\x06reason\x18\x02 \x01(\tR\x06reason\"\xed\x01\n" +
 	"\fDataResponse\x12\x1d\n" +
 	"\n" +
 	"request_id\x18\x01 \x01(\tR\trequestId\x12)\n" +
@@ -1009,14 +1295,15 @@ const file_tunnel_proto_rawDesc = "" +
 	"\x05chunk\x18\x03 \x01(\v2\x11.tunnel.DataChunkH\x00R\x05chunk\x122\n" +
 	"\bcomplete\x18\x04 \x01(\v2\x14.tunnel.DataCompleteH\x00R\bcomplete\x12)\n" +
 	"\x05error\x18\x05 \x01(\v2\x11.tunnel.DataErrorH\x00R\x05errorB\t\n" +
-	"\apayload\"\xa2\x01\n" +
+	"\apayload\"\xba\x01\n" +
 	"\tDataStart\x12!\n" +
 	"\finstance_uid\x18\x01 \x01(\tR\vinstanceUid\x12\x1b\n" +
 	"\tfile_size\x18\x02 \x01(\x03R\bfileSize\x12\x18\n" +
 	"\achunked\x18\x03 \x01(\bR\achunked\x12\x1f\n" +
 	"\vchunk_count\x18\x04 \x01(\x05R\n" +
 	"chunkCount\x12\x1a\n" +
-	"\bsequence\x18\x05 \x01(\x05R\bsequence\"\xa3\x01\n" +
+	"\bsequence\x18\x05 \x01(\x05R\bsequence\x12\x16\n" +
+	"\x06offset\x18\x06 \x01(\x03R\x06offset\"\xa3\x01\n" +
 	"\tDataChunk\x12!\n" +
 	"\finstance_uid\x18\x01 \x01(\tR\vinstanceUid\x12\x12\n" +
 	"\x04data\x18\x02 \x01(\fR\x04data\x12\x1f\n" +
@@ -1040,7 +1327,9 @@ const file_tunnel_proto_rawDesc = "" +
 	"\ttimestamp\x18\x01 \x01(\x03R\ttimestamp\x12/\n" +
 	"\x13instances_available\x18\x02 \x01(\x03R\x12instancesAvailable\x12(\n" +
 	"\x10disk_usage_bytes\x18\x03 \x01(\x03R\x0ediskUsageBytes\x12\x18\n" +
-	"\ahealthy\x18\x04 \x01(\bR\ahealthy2H\n" +
+	"\ahealthy\x18\x04 \x01(\bR\ahealthy\"!\n" +
+	"\aGoodbye\x12\x16\n" +
+	"\x06reason\x18\x01 \x01(\tR\x06reason2H\n" +
 	"\rTunnelService\x127\n" +
 	"\x06Stream\x12\x13.tunnel.EdgeMessage\x1a\x14.tunnel.RelayMessage(\x010\x01BAZ?github.com/minasoft-technology/gordionedge/internal/tunnel/grpcb\x06proto3"
 
@@ -1056,40 +1345,48 @@ func file_tunnel_proto_rawDescGZIP() []byte {
 	return file_tunnel_proto_rawDescData
 }
 
-var file_tunnel_proto_msgTypes = make([]protoimpl.MessageInfo, 12)
+var file_tunnel_proto_msgTypes = make([]protoimpl.MessageInfo, 16)
 var file_tunnel_proto_goTypes = []any{
 	(*EdgeMessage)(nil),      // 0: tunnel.EdgeMessage
 	(*RelayMessage)(nil),     // 1: tunnel.RelayMessage
 	(*RegisterRequest)(nil),  // 2: tunnel.RegisterRequest
 	(*RegisterResponse)(nil), // 3: tunnel.RegisterResponse
 	(*FetchCommand)(nil),     // 4: tunnel.FetchCommand
-	(*DataResponse)(nil),     // 5: tunnel.DataResponse
-	(*DataStart)(nil),        // 6: tunnel.DataStart
-	(*DataChunk)(nil),        // 7: tunnel.DataChunk
-	(*DataComplete)(nil),     // 8: tunnel.DataComplete
-	(*DataError)(nil),        // 9: tunnel.DataError
-	(*KeepAlive)(nil),        // 10: tunnel.KeepAlive
-	(*StatusUpdate)(nil),     // 11: tunnel.StatusUpdate
+	(*ByteRange)(nil),        // 5: tunnel.ByteRange
+	(*CancelCommand)(nil),    // 6: tunnel.CancelCommand
+	(*DataResponse)(nil),     // 7: tunnel.DataResponse
+	(*DataStart)(nil),        // 8: tunnel.DataStart
+	(*DataChunk)(nil),        // 9: tunnel.DataChunk
+	(*DataComplete)(nil),     // 10: tunnel.DataComplete
+	(*DataError)(nil),        // 11: tunnel.DataError
+	(*KeepAlive)(nil),        // 12: tunnel.KeepAlive
+	(*StatusUpdate)(nil),     // 13: tunnel.StatusUpdate
+	(*Goodbye)(nil),          // 14: tunnel.Goodbye
+	nil,                      // 15: tunnel.FetchCommand.MetadataEntry
 }
 var file_tunnel_proto_depIdxs = []int32{
 	2,  // 0: tunnel.EdgeMessage.register:type_name -> tunnel.RegisterRequest
-	5,  // 1: tunnel.EdgeMessage.data:type_name -> tunnel.DataResponse
-	10, // 2: tunnel.EdgeMessage.keepalive:type_name -> tunnel.KeepAlive
-	11, // 3: tunnel.EdgeMessage.status:type_name -> tunnel.StatusUpdate
-	3,  // 4: tunnel.RelayMessage.register_ack:type_name -> tunnel.RegisterResponse
-	4,  // 5: tunnel.RelayMessage.command:type_name -> tunnel.FetchCommand
-	10, // 6: tunnel.RelayMessage.keepalive:type_name -> tunnel.KeepAlive
-	6,  // 7: tunnel.DataResponse.start:type_name -> tunnel.DataStart
-	7,  // 8: tunnel.DataResponse.chunk:type_name -> tunnel.DataChunk
-	8,  // 9: tunnel.DataResponse.complete:type_name -> tunnel.DataComplete
-	9,  // 10: tunnel.DataResponse.error:type_name -> tunnel.DataError
-	0,  // 11: tunnel.TunnelService.Stream:input_type -> tunnel.EdgeMessage
-	1,  // 12: tunnel.TunnelService.Stream:output_type -> tunnel.RelayMessage
-	12, // [12:13] is the sub-list for method output_type
-	11, // [11:12] is the sub-list for method input_type
-	11, // [11:11] is the sub-list for extension type_name
-	11, // [11:11] is the sub-list for extension extendee
-	0,  // [0:11] is the sub-list for field type_name
+	7,  // 1: tunnel.EdgeMessage.data:type_name -> tunnel.DataResponse
+	12, // 2: tunnel.EdgeMessage.keepalive:type_name -> tunnel.KeepAlive
+	13, // 3: tunnel.EdgeMessage.status:type_name -> tunnel.StatusUpdate
+	14, // 4: tunnel.EdgeMessage.goodbye:type_name -> tunnel.Goodbye
+	3,  // 5: tunnel.RelayMessage.register_ack:type_name -> tunnel.RegisterResponse
+	4,  // 6: tunnel.RelayMessage.command:type_name -> tunnel.FetchCommand
+	12, // 7: tunnel.RelayMessage.keepalive:type_name -> tunnel.KeepAlive
+	6,  // 8: tunnel.RelayMessage.cancel:type_name -> tunnel.CancelCommand
+	15, // 9: tunnel.FetchCommand.metadata:type_name -> tunnel.FetchCommand.MetadataEntry
+	5,  // 10: tunnel.FetchCommand.byte_range:type_name -> tunnel.ByteRange
+	8,  // 11: tunnel.DataResponse.start:type_name -> tunnel.DataStart
+	9,  // 12: tunnel.DataResponse.chunk:type_name -> tunnel.DataChunk
+	10, // 13: tunnel.DataResponse.complete:type_name -> tunnel.DataComplete
+	11, // 14: tunnel.DataResponse.error:type_name -> tunnel.DataError
+	0,  // 15: tunnel.TunnelService.Stream:input_type -> tunnel.EdgeMessage
+	1,  // 16: tunnel.TunnelService.Stream:output_type -> tunnel.RelayMessage
+	16, // [16:17] is the sub-list for method output_type
+	15, // [15:16] is the sub-list for method input_type
+	15, // [15:15] is the sub-list for extension type_name
+	15, // [15:15] is the sub-list for extension extendee
+	0,  // [0:15] is the sub-list for field type_name
 }
 
 func init() { file_tunnel_proto_init() }
@@ -1102,13 +1399,15 @@ func file_tunnel_proto_init() {
 		(*EdgeMessage_Data)(nil),
 		(*EdgeMessage_Keepalive)(nil),
 		(*EdgeMessage_Status)(nil),
+		(*EdgeMessage_Goodbye)(nil),
 	}
 	file_tunnel_proto_msgTypes[1].OneofWrappers = []any{
 		(*RelayMessage_RegisterAck)(nil),
 		(*RelayMessage_Command)(nil),
 		(*RelayMessage_Keepalive)(nil),
+		(*RelayMessage_Cancel)(nil),
 	}
-	file_tunnel_proto_msgTypes[5].OneofWrappers = []any{
+	file_tunnel_proto_msgTypes[7].OneofWrappers = []any{
 		(*DataResponse_Start)(nil),
 		(*DataResponse_Chunk)(nil),
 		(*DataResponse_Complete)(nil),
@@ -1120,7 +1419,7 @@ func file_tunnel_proto_init() {
 			GoPackagePath: reflect.TypeOf(x{}).PkgPath(),
 			RawDescriptor: unsafe.Slice(unsafe.StringData(file_tunnel_proto_rawDesc), len(file_tunnel_proto_rawDesc)),
 			NumEnums:      0,
-			NumMessages:   12,
+			NumMessages:   16,
 			NumExtensions: 0,
 			NumServices:   1,
 		},
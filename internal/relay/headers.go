@@ -0,0 +1,61 @@
+package relay
+
+import (
+	"net/http"
+	"strings"
+)
+
+// hopHeaders are meaningful only for a single connection and must not be
+// forwarded by a proxy (RFC 9110 section 7.6.1)
+var hopHeaders = []string{
+	"Connection",
+	"Proxy-Connection", // non-standard, sent by some clients
+	"Keep-Alive",
+	"Proxy-Authenticate",
+	"Proxy-Authorization",
+	"Te",
+	"Trailer",
+	"Transfer-Encoding",
+	"Upgrade",
+}
+
+// removeHopByHopHeaders deletes hop-by-hop headers from h, including any
+// named in its Connection header
+func removeHopByHopHeaders(h http.Header) {
+	for _, value := range h.Values("Connection") {
+		for _, name := range strings.Split(value, ",") {
+			if name = strings.TrimSpace(name); name != "" {
+				h.Del(name)
+			}
+		}
+	}
+	for _, name := range hopHeaders {
+		h.Del(name)
+	}
+}
+
+// HeaderFilterConfig limits which client request headers reach hospitals.
+// Names are case-insensitive. Relay-generated headers (Host, Content-Length,
+// trace context, X-Forwarded-For, X-Real-IP) are always sent.
+type HeaderFilterConfig struct {
+	Allow []string `json:"allow,omitempty"` // Only forward these headers (default: all)
+	Deny  []string `json:"deny,omitempty"`  // Never forward these headers; applied after allow
+}
+
+// apply removes the headers the filter rejects from h
+func (f *HeaderFilterConfig) apply(h http.Header) {
+	if len(f.Allow) > 0 {
+		allowed := make(map[string]bool, len(f.Allow))
+		for _, name := range f.Allow {
+			allowed[http.CanonicalHeaderKey(name)] = true
+		}
+		for name := range h {
+			if !allowed[http.CanonicalHeaderKey(name)] {
+				delete(h, name)
+			}
+		}
+	}
+	for _, name := range f.Deny {
+		h.Del(name)
+	}
+}
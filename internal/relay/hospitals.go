@@ -0,0 +1,187 @@
+package relay
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// hospitalRegistry holds the hospitals known to the relay: the static list
+// from the config file plus any registered dynamically (e.g. via NATS).
+// Dynamic entries take precedence over static ones with the same code.
+type hospitalRegistry struct {
+	mu      sync.RWMutex
+	static  []HospitalConfig
+	dynamic map[string]HospitalConfig // code -> config
+}
+
+func newHospitalRegistry(static []HospitalConfig) *hospitalRegistry {
+	compileHospitals(static)
+	return &hospitalRegistry{
+		static:  static,
+		dynamic: make(map[string]HospitalConfig),
+	}
+}
+
+// find returns a copy of the first hospital matching match
+func (r *hospitalRegistry) find(match func(h *HospitalConfig) bool) (HospitalConfig, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.findLocked(match)
+}
+
+// findLocked is find for callers holding mu
+func (r *hospitalRegistry) findLocked(match func(h *HospitalConfig) bool) (HospitalConfig, bool) {
+	for _, h := range r.dynamic {
+		if match(&h) {
+			return h, true
+		}
+	}
+	for i := range r.static {
+		if _, overridden := r.dynamic[r.static[i].Code]; overridden {
+			continue
+		}
+		if match(&r.static[i]) {
+			return r.static[i], true
+		}
+	}
+	return HospitalConfig{}, false
+}
+
+// all returns a copy of every known hospital
+func (r *hospitalRegistry) all() []HospitalConfig {
+	var hospitals []HospitalConfig
+	r.find(func(h *HospitalConfig) bool {
+		hospitals = append(hospitals, *h)
+		return false
+	})
+	return hospitals
+}
+
+// byCodeAndSubdomain finds a hospital by exact code or alias and
+// case-insensitive subdomain
+func (r *hospitalRegistry) byCodeAndSubdomain(code, subdomain string) (HospitalConfig, bool) {
+	subdomain = strings.ToLower(subdomain)
+	return r.find(func(h *HospitalConfig) bool {
+		return (h.Code == code || h.hasAlias(code)) && strings.ToLower(h.Subdomain) == subdomain
+	})
+}
+
+// byCodeOrAlias finds a hospital by code or one of its aliases
+// (case-insensitive). Codes win over aliases.
+func (r *hospitalRegistry) byCodeOrAlias(code string) (HospitalConfig, bool) {
+	if h, ok := r.byCode(code); ok {
+		return h, true
+	}
+	return r.find(func(h *HospitalConfig) bool {
+		return h.hasAlias(code)
+	})
+}
+
+// byID finds a hospital by hospital ID (case-insensitive)
+func (r *hospitalRegistry) byID(hospitalID string) (HospitalConfig, bool) {
+	hospitalID = strings.ToUpper(hospitalID)
+	return r.find(func(h *HospitalConfig) bool {
+		return strings.ToUpper(h.HospitalID) == hospitalID
+	})
+}
+
+// byCode finds a hospital by code (case-insensitive)
+func (r *hospitalRegistry) byCode(code string) (HospitalConfig, bool) {
+	code = strings.ToLower(code)
+	return r.find(func(h *HospitalConfig) bool {
+		return strings.ToLower(h.Code) == code
+	})
+}
+
+// replaceStatic swaps in a new static hospital list and returns the codes
+// that were added and removed relative to the previous list
+func (r *hospitalRegistry) replaceStatic(hospitals []HospitalConfig) (added, removed []string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	oldCodes := make(map[string]bool, len(r.static))
+	for _, h := range r.static {
+		oldCodes[h.Code] = true
+	}
+	newCodes := make(map[string]bool, len(hospitals))
+	for _, h := range hospitals {
+		newCodes[h.Code] = true
+		if !oldCodes[h.Code] {
+			added = append(added, h.Code)
+		}
+	}
+	for code := range oldCodes {
+		if !newCodes[code] {
+			removed = append(removed, code)
+		}
+	}
+	sort.Strings(removed)
+
+	compileHospitals(hospitals)
+	r.static = hospitals
+	return added, removed
+}
+
+// upsert adds or replaces a dynamic hospital and reports whether it was new.
+// A hospital whose subdomain, code or aliases belong to another hospital is
+// refused: dynamic entries are searched first, so it would take over the
+// other hospital's traffic.
+func (r *hospitalRegistry) upsert(h HospitalConfig) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if other, taken := r.findLocked(func(o *HospitalConfig) bool {
+		return o.Code != h.Code && h.overlaps(o)
+	}); taken {
+		return false, fmt.Errorf("subdomain, code or alias already used by hospital %q", other.Code)
+	}
+
+	_, exists := r.dynamic[h.Code]
+	h.access = compileAccessRules(h.AllowedMethods, h.AllowedPaths)
+	r.dynamic[h.Code] = h
+	return !exists, nil
+}
+
+// remove deletes a dynamic hospital and reports whether it existed
+func (r *hospitalRegistry) remove(code string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	_, exists := r.dynamic[code]
+	delete(r.dynamic, code)
+	return exists
+}
+
+// compileHospitals prepares each hospital's access rules once, so requests
+// don't re-normalize them
+func compileHospitals(hospitals []HospitalConfig) {
+	for i := range hospitals {
+		hospitals[i].access = compileAccessRules(hospitals[i].AllowedMethods, hospitals[i].AllowedPaths)
+	}
+}
+
+// overlaps reports whether h and o share a subdomain, or a name used as a
+// code or alias by either (case-insensitive)
+func (h *HospitalConfig) overlaps(o *HospitalConfig) bool {
+	if h.Subdomain != "" && strings.EqualFold(h.Subdomain, o.Subdomain) {
+		return true
+	}
+	for _, name := range append([]string{h.Code}, h.Aliases...) {
+		if strings.EqualFold(name, o.Code) || o.hasAlias(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// hasAlias reports whether code is one of the hospital's aliases (case-insensitive)
+func (h *HospitalConfig) hasAlias(code string) bool {
+	for _, alias := range h.Aliases {
+		if strings.EqualFold(alias, code) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package relay
+
+import (
+	"context"
+	"math"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"golang.org/x/time/rate"
+)
+
+// retryAfterSeconds is the Retry-After hint sent with 429 responses
+const retryAfterSeconds = "1"
+
+// concurrencyLimiter caps the number of requests forwarded at the same time
+type concurrencyLimiter struct {
+	limit    int64
+	inflight atomic.Int64
+}
+
+func newConcurrencyLimiter(limit int) *concurrencyLimiter {
+	return &concurrencyLimiter{limit: int64(limit)}
+}
+
+// acquire reserves a slot and reports whether one was free.
+// Callers must release a successfully acquired slot.
+func (l *concurrencyLimiter) acquire() bool {
+	if l.inflight.Add(1) > l.limit {
+		l.inflight.Add(-1)
+		return false
+	}
+	return true
+}
+
+func (l *concurrencyLimiter) release() {
+	l.inflight.Add(-1)
+}
+
+// current returns the number of requests in flight
+func (l *concurrencyLimiter) current() int64 {
+	return l.inflight.Load()
+}
+
+// bandwidthLimiters hold one token bucket per hospital, so the response
+// bodies of all concurrent requests for a hospital share its max_bytes_per_sec
+type bandwidthLimiters struct {
+	mu       sync.Mutex
+	limiters map[string]*rate.Limiter // hospital code -> limiter
+}
+
+func newBandwidthLimiters() *bandwidthLimiters {
+	return &bandwidthLimiters{limiters: make(map[string]*rate.Limiter)}
+}
+
+// get returns the hospital's limiter, or nil when bytesPerSec is 0. The
+// bucket holds one second of traffic; a changed rate applies to the existing
+// bucket so requests in flight slow down or speed up with it.
+func (b *bandwidthLimiters) get(hospitalCode string, bytesPerSec int64) *rate.Limiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	burst := int(min(bytesPerSec, math.MaxInt32))
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	l, ok := b.limiters[hospitalCode]
+	if !ok {
+		l = rate.NewLimiter(rate.Limit(bytesPerSec), burst)
+		b.limiters[hospitalCode] = l
+	} else if l.Limit() != rate.Limit(bytesPerSec) {
+		l.SetLimit(rate.Limit(bytesPerSec))
+		l.SetBurst(burst)
+	}
+	return l
+}
+
+// throttle wraps w so writes wait for the limiter; a nil limiter returns w
+func throttle(ctx context.Context, w http.ResponseWriter, limiter *rate.Limiter) http.ResponseWriter {
+	if limiter == nil {
+		return w
+	}
+	return &throttledWriter{ResponseWriter: w, ctx: ctx, limiter: limiter}
+}
+
+// throttledWriter paces writes to the rate of a shared limiter
+type throttledWriter struct {
+	http.ResponseWriter
+	ctx     context.Context
+	limiter *rate.Limiter
+}
+
+// Write sends p in pieces no larger than the bucket, waiting for tokens
+// before each. It fails when the request's context ends while waiting.
+func (t *throttledWriter) Write(p []byte) (int, error) {
+	var written int
+	for len(p) > 0 {
+		n := min(len(p), t.limiter.Burst())
+		if err := t.limiter.WaitN(t.ctx, n); err != nil {
+			return written, err
+		}
+		wn, err := t.ResponseWriter.Write(p[:n])
+		written += wn
+		if err != nil {
+			return written, err
+		}
+		p = p[n:]
+	}
+	return written, nil
+}
+
+// Flush implements http.Flusher so progressive downloads keep working
+func (t *throttledWriter) Flush() {
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer
+func (t *throttledWriter) Unwrap() http.ResponseWriter {
+	return t.ResponseWriter
+}
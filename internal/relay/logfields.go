@@ -0,0 +1,32 @@
+package relay
+
+import "log/slog"
+
+// Log messages name the same things the same way in every server mode, so
+// log aggregation can filter on one key:
+//
+//   - hospital_code: the hospital's code (subdomain identifier)
+//   - hospital_id: the hospital's database ID (gRPC mode)
+//   - edge_server_id: one edge server of a hospital (gRPC mode)
+//   - remote_addr: the peer's address, resolved through trusted proxies
+//   - request_id: the relay's ID for a viewer request
+//   - subdomain: the requested hospital subdomain
+//
+// The helpers below attach these fields to loggers used for a whole
+// connection or request.
+
+// agentLogger tags messages about a WebSocket agent connection
+func agentLogger(logger *slog.Logger, hospitalCode, remoteAddr string) *slog.Logger {
+	return logger.With("hospital_code", hospitalCode, "remote_addr", remoteAddr)
+}
+
+// edgeLogger tags messages about a gRPC edge connection. The hospital code
+// is added once the registration has been matched to a hospital.
+func edgeLogger(logger *slog.Logger, hospitalID, edgeServerID, remoteAddr string) *slog.Logger {
+	return logger.With("hospital_id", hospitalID, "edge_server_id", edgeServerID, "remote_addr", remoteAddr)
+}
+
+// requestLogger tags messages about one viewer request
+func requestLogger(logger *slog.Logger, requestID string) *slog.Logger {
+	return logger.With("request_id", requestID)
+}
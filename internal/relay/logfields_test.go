@@ -0,0 +1,54 @@
+package relay
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"testing"
+)
+
+func TestLogFieldKeys(t *testing.T) {
+	tests := []struct {
+		name   string
+		logger func(*slog.Logger) *slog.Logger
+		want   map[string]string
+	}{
+		{
+			name:   "agent",
+			logger: func(l *slog.Logger) *slog.Logger { return agentLogger(l, "ankara", "203.0.113.7") },
+			want:   map[string]string{"hospital_code": "ankara", "remote_addr": "203.0.113.7"},
+		},
+		{
+			name:   "edge",
+			logger: func(l *slog.Logger) *slog.Logger { return edgeLogger(l, "H1", "edge-1", "203.0.113.8") },
+			want:   map[string]string{"hospital_id": "H1", "edge_server_id": "edge-1", "remote_addr": "203.0.113.8"},
+		},
+		{
+			name:   "request",
+			logger: func(l *slog.Logger) *slog.Logger { return requestLogger(l, "req-1") },
+			want:   map[string]string{"request_id": "req-1"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			tt.logger(slog.New(slog.NewJSONHandler(&buf, nil))).Info("test")
+
+			var record map[string]any
+			if err := json.Unmarshal(buf.Bytes(), &record); err != nil {
+				t.Fatalf("invalid log line %q: %v", buf.String(), err)
+			}
+			for _, key := range []string{slog.TimeKey, slog.LevelKey, slog.MessageKey} {
+				delete(record, key)
+			}
+			if len(record) != len(tt.want) {
+				t.Errorf("got fields %v, want %v", record, tt.want)
+			}
+			for key, want := range tt.want {
+				if got := record[key]; got != want {
+					t.Errorf("%s = %v, want %q", key, got, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,137 @@
+package relay
+
+import (
+	"log/slog"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// defaultMaintenanceRetryAfter is sent as Retry-After when none was given
+const defaultMaintenanceRetryAfter = 5 * time.Minute
+
+// maintenanceSet holds the hospitals currently in maintenance mode. Requests
+// for them are answered with 503 without touching the tunnel.
+type maintenanceSet struct {
+	mu        sync.RWMutex
+	hospitals map[string]time.Duration // hospital code -> Retry-After
+}
+
+func newMaintenanceSet(codes []string) *maintenanceSet {
+	m := &maintenanceSet{hospitals: make(map[string]time.Duration)}
+	for _, code := range codes {
+		m.hospitals[code] = defaultMaintenanceRetryAfter
+	}
+	return m
+}
+
+// retryAfter reports whether the hospital is in maintenance and for how long
+// clients should wait before retrying
+func (m *maintenanceSet) retryAfter(hospitalCode string) (time.Duration, bool) {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	d, ok := m.hospitals[hospitalCode]
+	return d, ok
+}
+
+func (m *maintenanceSet) set(hospitalCode string, retryAfter time.Duration) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.hospitals[hospitalCode] = retryAfter
+}
+
+// clear ends maintenance and reports whether the hospital was in it
+func (m *maintenanceSet) clear(hospitalCode string) bool {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	_, ok := m.hospitals[hospitalCode]
+	delete(m.hospitals, hospitalCode)
+	return ok
+}
+
+func (m *maintenanceSet) codes() []string {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+	codes := make([]string, 0, len(m.hospitals))
+	for code := range m.hospitals {
+		codes = append(codes, code)
+	}
+	sort.Strings(codes)
+	return codes
+}
+
+// writeMaintenance answers a request for a hospital in maintenance
+func writeMaintenance(w http.ResponseWriter, retryAfter time.Duration) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	http.Error(w, "This hospital is temporarily unavailable for maintenance. Please try again later.", http.StatusServiceUnavailable)
+}
+
+// adminMaintenanceHandler serves /admin/maintenance:
+//   - GET lists hospitals in maintenance
+//   - POST ?hospital=CODE[&retry_after=10m] starts maintenance
+//   - DELETE ?hospital=CODE ends it
+func adminMaintenanceHandler(cfg *Config, hospitals *hospitalRegistry, maintenance *maintenanceSet, logger *slog.Logger) http.HandlerFunc {
+	adminToken := cfg.AdminToken
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !adminAuthorized(r, adminToken) {
+			logger.Warn("Rejected admin maintenance request", "remote_addr", cfg.ClientIP(r))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		hospitalCode := r.URL.Query().Get("hospital")
+		switch r.Method {
+		case http.MethodGet:
+			if err := writeJSON(w, http.StatusOK, map[string][]string{"hospitals": maintenance.codes()}); err != nil {
+				logger.Debug("Failed to write maintenance response", "error", err)
+			}
+			return
+
+		case http.MethodPost:
+			if hospitalCode == "" {
+				http.Error(w, "hospital is required", http.StatusBadRequest)
+				return
+			}
+			if _, ok := hospitals.byCode(hospitalCode); !ok {
+				http.Error(w, "Unknown hospital", http.StatusNotFound)
+				return
+			}
+			retryAfter := defaultMaintenanceRetryAfter
+			if v := r.URL.Query().Get("retry_after"); v != "" {
+				d, err := time.ParseDuration(v)
+				if err != nil || d < time.Second {
+					http.Error(w, "retry_after must be a duration of at least 1s", http.StatusBadRequest)
+					return
+				}
+				retryAfter = d
+			}
+			maintenance.set(hospitalCode, retryAfter)
+			logger.Warn("Hospital entered maintenance mode",
+				"hospital_code", hospitalCode,
+				"retry_after", retryAfter.String(),
+				"remote_addr", cfg.ClientIP(r))
+
+		case http.MethodDelete:
+			if hospitalCode == "" {
+				http.Error(w, "hospital is required", http.StatusBadRequest)
+				return
+			}
+			if !maintenance.clear(hospitalCode) {
+				http.Error(w, "Hospital not in maintenance", http.StatusNotFound)
+				return
+			}
+			logger.Info("Hospital left maintenance mode", "hospital_code", hospitalCode, "remote_addr", cfg.ClientIP(r))
+
+		default:
+			w.Header().Set("Allow", "GET, POST, DELETE")
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if err := writeJSON(w, http.StatusOK, map[string][]string{"hospitals": maintenance.codes()}); err != nil {
+			logger.Debug("Failed to write maintenance response", "error", err)
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package relay
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Relay modes used as the "mode" metric label
+const (
+	modeWebSocket = "websocket"
+	modeGRPC      = "grpc"
+)
+
+// Prometheus metrics shared by all server modes
+var (
+	requestsForwarded = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gordion_relay_requests_total",
+		Help: "Total number of requests forwarded to hospitals.",
+	}, []string{"mode", "hospital_code"})
+
+	requestFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gordion_relay_request_failures_total",
+		Help: "Total number of failed requests by reason.",
+	}, []string{"mode", "hospital_code", "reason"})
+
+	bytesTransferred = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gordion_relay_bytes_transferred_total",
+		Help: "Total response bytes sent to clients per hospital.",
+	}, []string{"mode", "hospital_code"})
+
+	connectedHospitals = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gordion_relay_connected_hospitals",
+		Help: "Number of currently connected hospitals.",
+	}, []string{"mode"})
+
+	forwardDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "gordion_relay_forward_duration_seconds",
+		Help:    "Time taken to forward a request and stream its response.",
+		Buckets: []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60, 120, 300},
+	}, []string{"mode", "hospital_code"})
+
+	slowRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gordion_relay_slow_requests_total",
+		Help: "Total number of forwarded requests slower than slow_request_threshold.",
+	}, []string{"mode", "hospital_code"})
+
+	registrations = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gordion_relay_registrations_total",
+		Help: "Total number of hospital registration attempts by result.",
+	}, []string{"mode", "result"})
+
+	connectionsRejected = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gordion_relay_connections_rejected_total",
+		Help: "Connections on listen_addr closed because max_connections or max_pending_registrations was reached.",
+	}, []string{"mode", "reason"})
+
+	agentQueueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gordion_relay_agent_queue_depth",
+		Help: "Requests waiting for a protocol 1 agent to finish its current request.",
+	}, []string{"hospital_code"})
+
+	edgePendingRequests = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "gordion_relay_edge_pending_requests",
+		Help: "Fetches waiting on an edge connection for their response.",
+	}, []string{"hospital_id", "edge_server_id"})
+
+	edgeDisconnects = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "gordion_relay_edge_disconnects_total",
+		Help: "Total number of edge disconnects: clean (announced), unclean (stream dropped) or evicted (closed by the relay).",
+	}, []string{"mode", "type"})
+)
+
+// responseRecorder captures the status code and body size written to a client
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	bytes       int64
+	wroteHeader bool // the status line went out; failures can no longer change it
+}
+
+func newResponseRecorder(w http.ResponseWriter) *responseRecorder {
+	return &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	if status >= http.StatusOK {
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.wroteHeader = true
+	n, err := r.ResponseWriter.Write(b)
+	r.bytes += int64(n)
+	return n, err
+}
+
+// Flush implements http.Flusher so progressive downloads keep working
+func (r *responseRecorder) Flush() {
+	if flusher, ok := r.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer, e.g. to hijack it
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
@@ -0,0 +1,85 @@
+package relay
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log/slog"
+	"os"
+	"strings"
+
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/peer"
+)
+
+// serverTLSConfig builds the gRPC listener's TLS configuration. With a
+// client CA configured, edges must present a certificate signed by it.
+// Session tickets stay enabled (the crypto/tls default), so reconnecting
+// edges resume their TLS session instead of a full handshake. The
+// certificate is served by the returned reloader; run it to pick up renewals.
+func serverTLSConfig(cfg *TLSConfig, logger *slog.Logger) (*tls.Config, *certReloader, error) {
+	certs, err := newCertReloader(cfg.CertFile, cfg.KeyFile, logger)
+	if err != nil {
+		return nil, nil, err
+	}
+	tlsConfig := &tls.Config{
+		GetCertificate: certs.getCertificate,
+		MinVersion:     cfg.minVersion(),
+		CipherSuites:   cfg.cipherSuites(),
+	}
+
+	if cfg.ClientCAFile != "" {
+		pem, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to read client CA file: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, nil, fmt.Errorf("no certificates found in client CA file %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return tlsConfig, certs, nil
+}
+
+// CheckFiles reports whether the configured certificate, key and client CA
+// files can be read and parsed. Autocert certificates are obtained at runtime
+// and are not checked.
+func (t *TLSConfig) CheckFiles() error {
+	if !t.Enabled || t.AutoCert {
+		return nil
+	}
+	_, _, err := serverTLSConfig(t, slog.Default())
+	return err
+}
+
+// peerCertificate returns the verified client certificate of a gRPC peer, if any
+func peerCertificate(ctx context.Context) *x509.Certificate {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return nil
+	}
+	info, ok := p.AuthInfo.(credentials.TLSInfo)
+	if !ok || len(info.State.VerifiedChains) == 0 || len(info.State.VerifiedChains[0]) == 0 {
+		return nil
+	}
+	return info.State.VerifiedChains[0][0]
+}
+
+// certMatchesHospital reports whether the certificate's CN or one of its DNS
+// SANs names the hospital, by code or hospital ID
+func certMatchesHospital(cert *x509.Certificate, hospital *HospitalConfig) bool {
+	names := append([]string{cert.Subject.CommonName}, cert.DNSNames...)
+	for _, name := range names {
+		if name == "" {
+			continue
+		}
+		if strings.EqualFold(name, hospital.Code) || (hospital.HospitalID != "" && strings.EqualFold(name, hospital.HospitalID)) {
+			return true
+		}
+	}
+	return false
+}
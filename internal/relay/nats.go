@@ -0,0 +1,133 @@
+package relay
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"time"
+
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nkeys"
+)
+
+// hospitalEvent is a hospital registration/deregistration published on NATS.
+// The payload is a HospitalConfig with an optional "action" field.
+type hospitalEvent struct {
+	Action string `json:"action"` // "register" (default) or "deregister"
+	HospitalConfig
+}
+
+// startNATSDiscovery subscribes to hospital events and applies them to registry.
+// The connection retries in the background and is closed when ctx is cancelled.
+func startNATSDiscovery(ctx context.Context, config *Config, registry *hospitalRegistry, logger *slog.Logger) error {
+	logger = logger.With("component", "nats")
+	cfg := config.NATS
+
+	opts := []nats.Option{
+		nats.Name("gordion-relay"),
+		nats.RetryOnFailedConnect(true),
+		nats.MaxReconnects(-1),
+		nats.ReconnectWait(2 * time.Second),
+		nats.DisconnectErrHandler(func(_ *nats.Conn, err error) {
+			if err != nil {
+				logger.Warn("NATS disconnected", "error", err)
+			}
+		}),
+		nats.ReconnectHandler(func(nc *nats.Conn) {
+			logger.Info("NATS reconnected", "url", nc.ConnectedUrl())
+		}),
+	}
+
+	switch {
+	case cfg.CredentialsFile != "":
+		opts = append(opts, nats.UserCredentials(cfg.CredentialsFile))
+	case cfg.Credentials != "":
+		credsOpt, err := natsInlineCredentials(cfg.Credentials)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, credsOpt)
+	}
+
+	nc, err := nats.Connect(cfg.URL, opts...)
+	if err != nil {
+		return fmt.Errorf("failed to connect to NATS: %w", err)
+	}
+
+	_, err = nc.Subscribe(cfg.Subject, func(msg *nats.Msg) {
+		handleHospitalEvent(msg.Data, config, registry, logger)
+	})
+	if err != nil {
+		nc.Close()
+		return fmt.Errorf("failed to subscribe to %s: %w", cfg.Subject, err)
+	}
+
+	logger.Info("Subscribed to hospital registrations", "url", cfg.URL, "subject", cfg.Subject)
+
+	go func() {
+		<-ctx.Done()
+		nc.Drain()
+	}()
+
+	return nil
+}
+
+// handleHospitalEvent applies one hospital event to the registry. Registrations
+// get the same checks as hospitals in the config file.
+func handleHospitalEvent(data []byte, config *Config, registry *hospitalRegistry, logger *slog.Logger) {
+	var event hospitalEvent
+	if err := json.Unmarshal(data, &event); err != nil {
+		logger.Warn("Invalid hospital event", "error", err)
+		return
+	}
+	if event.Code == "" {
+		logger.Warn("Hospital event without code ignored")
+		return
+	}
+
+	switch event.Action {
+	case "", "register":
+		if event.Subdomain == "" {
+			event.Subdomain = event.Code + "." + config.Domain
+		}
+		name := fmt.Sprintf("hospital %q", event.Code)
+		if problems := event.validate(name, config.Mode, config.ApexDomains()); len(problems) > 0 {
+			logger.Warn("Invalid hospital registration ignored", "hospital_code", event.Code, "problems", problems)
+			return
+		}
+		added, err := registry.upsert(event.HospitalConfig)
+		if err != nil {
+			logger.Warn("Hospital registration ignored", "hospital_code", event.Code, "error", err)
+			return
+		}
+		if added {
+			logger.Info("Hospital added", "hospital_code", event.Code, "subdomain", event.Subdomain)
+		} else {
+			logger.Info("Hospital updated", "hospital_code", event.Code, "subdomain", event.Subdomain)
+		}
+	case "deregister":
+		if registry.remove(event.Code) {
+			logger.Info("Hospital removed", "hospital_code", event.Code)
+		}
+	default:
+		logger.Warn("Unknown hospital event action", "hospital_code", event.Code, "action", event.Action)
+	}
+}
+
+// natsInlineCredentials builds a credentials option from .creds file contents
+func natsInlineCredentials(contents string) (nats.Option, error) {
+	jwt, err := nkeys.ParseDecoratedJWT([]byte(contents))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS credentials: %w", err)
+	}
+	kp, err := nkeys.ParseDecoratedNKey([]byte(contents))
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS credentials: %w", err)
+	}
+	seed, err := kp.Seed()
+	if err != nil {
+		return nil, fmt.Errorf("invalid NATS credentials: %w", err)
+	}
+	return nats.UserJWTAndSeed(jwt, string(seed)), nil
+}
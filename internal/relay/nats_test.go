@@ -0,0 +1,50 @@
+package relay
+
+import (
+	"io"
+	"log/slog"
+	"testing"
+)
+
+func TestHandleHospitalEvent(t *testing.T) {
+	cfg := &Config{Mode: "websocket", Domain: "zenpacs.com.tr"}
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name  string
+		event string
+		code  string // looked up afterwards
+		want  bool
+	}{
+		{"valid", `{"code":"izmir","token":"izmir-token"}`, "izmir", true},
+		{"missing token", `{"code":"izmir"}`, "izmir", false},
+		{"invalid code", `{"code":"Izmir_1","token":"izmir-token"}`, "Izmir_1", false},
+		{"foreign subdomain", `{"code":"izmir","token":"izmir-token","subdomain":"izmir.example.com"}`, "izmir", false},
+		{"negative limit", `{"code":"izmir","token":"izmir-token","max_bytes_per_sec":-1}`, "izmir", false},
+		{"download key equals token", `{"code":"izmir","token":"izmir-token","download_key":"izmir-token"}`, "izmir", false},
+		{"subdomain of another hospital", `{"code":"izmir","token":"izmir-token","subdomain":"ankara.zenpacs.com.tr"}`, "izmir", false},
+		{"alias of another hospital", `{"code":"izmir","token":"izmir-token","aliases":["ank"]}`, "izmir", false},
+		{"code is another hospital's alias", `{"code":"ank","token":"ank-token"}`, "ank", false},
+		{"update of a static hospital", `{"code":"ankara","token":"new-token"}`, "ankara", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			registry := newHospitalRegistry([]HospitalConfig{{
+				Code:      "ankara",
+				Aliases:   []string{"ank"},
+				Subdomain: "ankara.zenpacs.com.tr",
+				Token:     "ankara-token",
+			}})
+			handleHospitalEvent([]byte(tt.event), cfg, registry, logger)
+
+			_, got := registry.dynamic[tt.code]
+			if got != tt.want {
+				t.Errorf("registered = %v, want %v", got, tt.want)
+			}
+			h, ok := registry.find(func(h *HospitalConfig) bool { return h.Subdomain == "ankara.zenpacs.com.tr" })
+			if !ok || h.Code != "ankara" {
+				t.Errorf("ankara.zenpacs.com.tr resolves to %q, want ankara", h.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,128 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"log/slog"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// PagesConfig holds the HTML pages shown to viewers instead of plain-text
+// errors (websocket mode). Each template is optional; unset ones keep the
+// plain-text response.
+type PagesConfig struct {
+	Landing         string `json:"landing,omitempty"`          // Template served for the apex domain
+	LandingRedirect string `json:"landing_redirect,omitempty"` // Redirect apex requests here instead, e.g. "https://www.zenpacs.com.tr"
+	NotFound        string `json:"not_found,omitempty"`        // Template for unknown hospitals (404)
+	Unavailable     string `json:"unavailable,omitempty"`      // Template for disconnected hospitals and maintenance (503)
+}
+
+// pageData is the context templates are rendered with
+type pageData struct {
+	Host        string // Requested host
+	Hospital    string // Hospital code, empty on the landing page
+	Status      int    // HTTP status of the response
+	Maintenance bool   // The hospital is in maintenance mode
+	RetryAfter  string // Suggested wait, e.g. "5m0s"; empty if none
+}
+
+// pageTemplates holds the parsed PagesConfig templates; nil entries fall
+// back to plain text
+type pageTemplates struct {
+	landingRedirect string
+	landing         *template.Template
+	notFound        *template.Template
+	unavailable     *template.Template
+}
+
+// loadPages parses the configured templates
+func loadPages(cfg PagesConfig) (*pageTemplates, error) {
+	p := &pageTemplates{landingRedirect: cfg.LandingRedirect}
+	for _, t := range []struct {
+		name string
+		path string
+		dst  **template.Template
+	}{
+		{"pages.landing", cfg.Landing, &p.landing},
+		{"pages.not_found", cfg.NotFound, &p.notFound},
+		{"pages.unavailable", cfg.Unavailable, &p.unavailable},
+	} {
+		if t.path == "" {
+			continue
+		}
+		tmpl, err := template.ParseFiles(t.path)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", t.name, err)
+		}
+		*t.dst = tmpl
+	}
+	return p, nil
+}
+
+// CheckFiles reports whether the configured templates can be read and parsed
+func (c *PagesConfig) CheckFiles() error {
+	_, err := loadPages(*c)
+	return err
+}
+
+// serveLanding answers a request for the apex domain and reports whether a
+// landing page or redirect is configured
+func (p *pageTemplates) serveLanding(w http.ResponseWriter, r *http.Request, logger *slog.Logger) bool {
+	if p.landingRedirect != "" {
+		http.Redirect(w, r, p.landingRedirect, http.StatusFound)
+		return true
+	}
+	return p.render(w, p.landing, pageData{Host: r.Host, Status: http.StatusOK}, logger)
+}
+
+// writeNotFound answers a request for a hospital the relay does not know
+func (p *pageTemplates) writeNotFound(w http.ResponseWriter, r *http.Request, hospitalCode string, logger *slog.Logger) {
+	data := pageData{Host: r.Host, Hospital: hospitalCode, Status: http.StatusNotFound}
+	if !p.render(w, p.notFound, data, logger) {
+		http.Error(w, "Unknown hospital", http.StatusNotFound)
+	}
+}
+
+// writeUnavailable answers a request for a known hospital that cannot take
+// it. With a template configured it replaces the plain-text fallback.
+func (p *pageTemplates) writeUnavailable(w http.ResponseWriter, r *http.Request, hospitalCode string, logger *slog.Logger, fallback func()) {
+	data := pageData{Host: r.Host, Hospital: hospitalCode, Status: http.StatusServiceUnavailable}
+	if !p.render(w, p.unavailable, data, logger) {
+		fallback()
+	}
+}
+
+// writeMaintenance answers a request for a hospital in maintenance mode
+func (p *pageTemplates) writeMaintenance(w http.ResponseWriter, r *http.Request, hospitalCode string, retryAfter time.Duration, logger *slog.Logger) {
+	data := pageData{
+		Host:        r.Host,
+		Hospital:    hospitalCode,
+		Status:      http.StatusServiceUnavailable,
+		Maintenance: true,
+		RetryAfter:  retryAfter.String(),
+	}
+	w.Header().Set("Retry-After", strconv.Itoa(int(retryAfter.Seconds())))
+	if !p.render(w, p.unavailable, data, logger) {
+		writeMaintenance(w, retryAfter)
+	}
+}
+
+// render executes t into w with data.Status, or reports false if t is nil or
+// fails so the caller can fall back to plain text
+func (p *pageTemplates) render(w http.ResponseWriter, t *template.Template, data pageData, logger *slog.Logger) bool {
+	if t == nil {
+		return false
+	}
+	var buf bytes.Buffer
+	if err := t.Execute(&buf, data); err != nil {
+		logger.Error("Failed to render page", "template", t.Name(), "error", err)
+		return false
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(data.Status)
+	_, _ = w.Write(buf.Bytes())
+	return true
+}
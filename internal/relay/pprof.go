@@ -0,0 +1,19 @@
+package relay
+
+import (
+	"net/http"
+	"net/http/pprof"
+)
+
+// registerPprof adds the runtime profiling handlers under /debug/pprof/.
+// They reveal stacks, heap contents and timings, so they only ever go on
+// the metrics mux, never on a listener viewers or agents can reach. (The
+// package also registers itself on http.DefaultServeMux, which no relay
+// server uses.)
+func registerPprof(mux *http.ServeMux) {
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+}
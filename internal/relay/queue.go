@@ -0,0 +1,103 @@
+package relay
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+// Errors for requests that never got their turn on a protocol 1 agent
+var (
+	// errQueueFull is returned when an agent already has QueueDepth requests waiting
+	errQueueFull = errors.New("agent request queue full")
+
+	// errQueueTimeout is returned when a request waited longer than QueueTimeout
+	errQueueTimeout = errors.New("timed out waiting for agent")
+)
+
+// requestQueue lets one request at a time use a protocol 1 agent. The
+// others wait in arrival order, at most maxDepth of them and each for a
+// bounded time, so a burst of viewers gets predictable answers instead of
+// piling up on a lock.
+type requestQueue struct {
+	maxDepth int
+	onDepth  func(depth int) // called with the number of waiting requests when it changes
+
+	mu      sync.Mutex
+	busy    bool
+	waiters []chan struct{} // closed to hand the turn to the waiter
+}
+
+func newRequestQueue(maxDepth int, onDepth func(depth int)) *requestQueue {
+	return &requestQueue{maxDepth: maxDepth, onDepth: onDepth}
+}
+
+// acquire waits for the request's turn. It fails right away with
+// errQueueFull when the queue is full, with errQueueTimeout after maxWait,
+// with errAgentDisconnected when done is closed, and with the context's
+// error when the viewer leaves. Callers must release a successful turn.
+func (q *requestQueue) acquire(ctx context.Context, maxWait time.Duration, done <-chan struct{}) error {
+	q.mu.Lock()
+	if !q.busy {
+		q.busy = true
+		q.mu.Unlock()
+		return nil
+	}
+	if len(q.waiters) >= q.maxDepth {
+		q.mu.Unlock()
+		return errQueueFull
+	}
+	turn := make(chan struct{})
+	q.waiters = append(q.waiters, turn)
+	q.depthChanged()
+	q.mu.Unlock()
+
+	timer := time.NewTimer(maxWait)
+	defer timer.Stop()
+
+	var err error
+	select {
+	case <-turn:
+		return nil
+	case <-timer.C:
+		err = errQueueTimeout
+	case <-done:
+		err = errAgentDisconnected
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	q.mu.Lock()
+	if i := slices.Index(q.waiters, turn); i >= 0 {
+		q.waiters = slices.Delete(q.waiters, i, i+1)
+		q.depthChanged()
+		q.mu.Unlock()
+		return err
+	}
+	q.mu.Unlock()
+	// The turn was handed over while giving up; pass it on
+	q.release()
+	return err
+}
+
+// release ends the current request's turn and hands it to the longest waiter
+func (q *requestQueue) release() {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if len(q.waiters) == 0 {
+		q.busy = false
+		return
+	}
+	close(q.waiters[0])
+	q.waiters = slices.Delete(q.waiters, 0, 1)
+	q.depthChanged()
+}
+
+// depthChanged reports the queue depth; callers hold mu
+func (q *requestQueue) depthChanged() {
+	if q.onDepth != nil {
+		q.onDepth(len(q.waiters))
+	}
+}
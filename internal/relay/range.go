@@ -0,0 +1,172 @@
+package relay
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/minasoft-technology/gordion-relay/internal/relay/grpc"
+)
+
+// errRangeComplete stops a ranged fetch once the last byte of the range is written
+var errRangeComplete = errors.New("range complete")
+
+// rangeNotSatisfiableError fails a ranged fetch whose range starts past the
+// end of the file; the viewer gets 416
+type rangeNotSatisfiableError struct {
+	size int64
+}
+
+func (e *rangeNotSatisfiableError) Error() string {
+	return fmt.Sprintf("range not satisfiable for a %d byte file", e.size)
+}
+
+// byteRange is a single range from a Range header. With suffix > 0 it is
+// the last suffix bytes; otherwise start to end inclusive, end -1 meaning
+// the end of the file.
+type byteRange struct {
+	start, end, suffix int64
+}
+
+// parseRange parses a Range header holding one byte range. ok is false for
+// anything else, including multiple ranges, which are then ignored and the
+// whole file is served.
+func parseRange(header string) (rng byteRange, ok bool) {
+	spec, found := strings.CutPrefix(header, "bytes=")
+	if !found || strings.Contains(spec, ",") {
+		return byteRange{}, false
+	}
+	first, last, found := strings.Cut(strings.TrimSpace(spec), "-")
+	if !found {
+		return byteRange{}, false
+	}
+
+	if first == "" {
+		n, err := strconv.ParseInt(last, 10, 64)
+		if err != nil || n <= 0 {
+			return byteRange{}, false
+		}
+		return byteRange{suffix: n}, true
+	}
+
+	start, err := strconv.ParseInt(first, 10, 64)
+	if err != nil || start < 0 {
+		return byteRange{}, false
+	}
+	end := int64(-1)
+	if last != "" {
+		end, err = strconv.ParseInt(last, 10, 64)
+		if err != nil || end < start {
+			return byteRange{}, false
+		}
+	}
+	return byteRange{start: start, end: end}, true
+}
+
+// resolve returns the first and last byte of the range in a file of size
+// bytes, or ok false if no byte of it exists
+func (r byteRange) resolve(size int64) (first, last int64, ok bool) {
+	if r.suffix > 0 {
+		if size == 0 {
+			return 0, 0, false
+		}
+		return max(0, size-r.suffix), size - 1, true
+	}
+	if r.start >= size {
+		return 0, 0, false
+	}
+	last = size - 1
+	if r.end >= 0 && r.end < last {
+		last = r.end
+	}
+	return r.start, last, true
+}
+
+// proto returns the range as sent to the edge
+func (r byteRange) proto() *grpc.ByteRange {
+	return &grpc.ByteRange{Start: r.start, End: r.end, SuffixLength: r.suffix}
+}
+
+// rangeInstanceWriter passes the requested range of a single instance
+// through. The edge may send only the range (DataStart.offset says where it
+// starts) or the whole file; bytes outside the range are dropped, and
+// errRangeComplete ends the fetch once the range is written.
+type rangeInstanceWriter struct {
+	w   io.Writer
+	rng byteRange
+
+	// Set by start, before the first Write
+	started     bool
+	size        int64
+	first, last int64
+	pos         int64 // file offset of the next byte from the edge
+}
+
+func newRangeInstanceWriter(w io.Writer, rng byteRange) *rangeInstanceWriter {
+	return &rangeInstanceWriter{w: w, rng: rng}
+}
+
+// start resolves the range against the file described by the edge's DataStart
+func (rw *rangeInstanceWriter) start(size, offset int64) error {
+	first, last, ok := rw.rng.resolve(size)
+	if !ok {
+		return &rangeNotSatisfiableError{size: size}
+	}
+	if offset < 0 || offset > first {
+		return fmt.Errorf("edge sent offset %d for a range starting at %d", offset, first)
+	}
+	rw.started = true
+	rw.size, rw.first, rw.last, rw.pos = size, first, last, offset
+	return nil
+}
+
+func (rw *rangeInstanceWriter) BeginInstance(string) error { return nil }
+func (rw *rangeInstanceWriter) Close() error               { return nil }
+
+func (rw *rangeInstanceWriter) Write(p []byte) (int, error) {
+	if !rw.started {
+		return 0, errors.New("edge sent data without a file size for a range request")
+	}
+	n := len(p)
+	if skip := min(int64(len(p)), rw.first-rw.pos); skip > 0 {
+		p = p[skip:]
+		rw.pos += skip
+	}
+	if keep := rw.last - rw.pos + 1; int64(len(p)) > keep {
+		p = p[:keep]
+	}
+	if len(p) > 0 {
+		if _, err := rw.w.Write(p); err != nil {
+			return 0, err
+		}
+		rw.pos += int64(len(p))
+	}
+	if rw.pos > rw.last {
+		return n, errRangeComplete
+	}
+	return n, nil
+}
+
+// setRangeHeaders answers a satisfiable range with 206 Partial Content
+func (rw *rangeInstanceWriter) setRangeHeaders(w http.ResponseWriter) {
+	w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", rw.first, rw.last, rw.size))
+	w.Header().Set("Content-Length", strconv.FormatInt(rw.last-rw.first+1, 10))
+	w.WriteHeader(http.StatusPartialContent)
+}
+
+// peekRange waits for the first byte of a ranged fetch. By then the edge has
+// announced the file size and the range is resolved, or the fetch failed.
+func peekRange(r io.Reader) (io.Reader, error) {
+	br := bufio.NewReader(r)
+	if _, err := br.Peek(1); err != nil {
+		if err == io.EOF {
+			err = fmt.Errorf("edge sent no data for the range: %w", io.ErrUnexpectedEOF)
+		}
+		return nil, err
+	}
+	return br, nil
+}
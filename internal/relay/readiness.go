@@ -0,0 +1,135 @@
+package relay
+
+import (
+	"log/slog"
+	"net/http"
+	"slices"
+	"sync"
+	"time"
+)
+
+// readyResponse is the JSON document served by /ready
+type readyResponse struct {
+	Status     string   `json:"status"`               // ready, starting or shutting_down
+	Waiting    []string `json:"waiting,omitempty"`    // startup_wait_hospitals not registered yet
+	Registered *int     `json:"registered,omitempty"` // distinct hospitals registered, while startup_wait_min_hospitals is pending
+}
+
+// startupGate holds /ready at 503 after a restart until every hospital in
+// startup_wait_hospitals and at least startup_wait_min_hospitals distinct
+// hospitals have registered, or startup_wait_timeout has passed. Once open it
+// stays open; later disconnects do not affect readiness.
+type startupGate struct {
+	minHospitals int
+	timeout      time.Duration
+	logger       *slog.Logger
+
+	mu      sync.Mutex
+	waiting map[string]bool // hospital codes not registered yet
+	seen    map[string]bool // hospital codes registered so far
+	open    bool
+	timer   *time.Timer
+}
+
+func newStartupGate(codes []string, minHospitals int, timeout time.Duration, logger *slog.Logger) *startupGate {
+	g := &startupGate{
+		minHospitals: minHospitals,
+		timeout:      timeout,
+		logger:       logger,
+		waiting:      make(map[string]bool, len(codes)),
+		seen:         make(map[string]bool),
+	}
+	for _, code := range codes {
+		g.waiting[code] = true
+	}
+	g.open = g.satisfied()
+	return g
+}
+
+// satisfied reports whether every startup condition is met; g.mu must be held
+// once the gate is shared
+func (g *startupGate) satisfied() bool {
+	return len(g.waiting) == 0 && len(g.seen) >= g.minHospitals
+}
+
+// begin starts the timeout; call it when the server starts accepting registrations
+func (g *startupGate) begin() {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.open {
+		return
+	}
+	g.logger.Info("Waiting for hospitals before reporting ready",
+		"hospitals", g.pending(),
+		"min_hospitals", g.minHospitals,
+		"timeout", g.timeout)
+	g.timer = time.AfterFunc(g.timeout, func() {
+		g.mu.Lock()
+		defer g.mu.Unlock()
+		if !g.open {
+			g.open = true
+			g.logger.Warn("Startup wait timed out, reporting ready",
+				"missing", g.pending(),
+				"registered", len(g.seen))
+		}
+	})
+}
+
+// registered records a hospital registration
+func (g *startupGate) registered(code string) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.open || g.seen[code] {
+		return
+	}
+	g.seen[code] = true
+	delete(g.waiting, code)
+	if g.satisfied() {
+		g.open = true
+		if g.timer != nil {
+			g.timer.Stop()
+		}
+		g.logger.Info("Startup hospitals registered, reporting ready", "registered", len(g.seen))
+	}
+}
+
+// state reports whether the gate is open and, if not, which hospitals it
+// waits for and how many distinct hospitals have registered
+func (g *startupGate) state() (open bool, waiting []string, registered int) {
+	g.mu.Lock()
+	defer g.mu.Unlock()
+	if g.open {
+		return true, nil, len(g.seen)
+	}
+	return false, g.pending(), len(g.seen)
+}
+
+// pending lists the hospitals still waited for, sorted; g.mu must be held
+func (g *startupGate) pending() []string {
+	codes := make([]string, 0, len(g.waiting))
+	for code := range g.waiting {
+		codes = append(codes, code)
+	}
+	slices.Sort(codes)
+	return codes
+}
+
+// readyHandler serves /ready: 200 once the startup gate is open, 503 before
+// that and while the server shuts down
+func readyHandler(gate *startupGate, running func() bool) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if !running() {
+			_ = writeJSON(w, http.StatusServiceUnavailable, readyResponse{Status: "shutting_down"})
+			return
+		}
+		if open, waiting, registered := gate.state(); !open {
+			resp := readyResponse{Status: "starting", Waiting: waiting}
+			if gate.minHospitals > 0 {
+				resp.Registered = &registered
+			}
+			_ = writeJSON(w, http.StatusServiceUnavailable, resp)
+			return
+		}
+		_ = writeJSON(w, http.StatusOK, readyResponse{Status: "ready"})
+	}
+}
@@ -0,0 +1,65 @@
+package relay
+
+import (
+	"io"
+	"log/slog"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestStartupGate(t *testing.T) {
+	logger := slog.New(slog.NewTextHandler(io.Discard, nil))
+
+	tests := []struct {
+		name         string
+		codes        []string
+		minHospitals int
+		register     []string
+		wantOpen     []bool // after each registration
+	}{
+		{"no wait", nil, 0, nil, nil},
+		{"codes", []string{"ankara", "izmir"}, 0, []string{"samsun", "ankara", "izmir"}, []bool{false, false, true}},
+		{"count", nil, 2, []string{"ankara", "ankara", "izmir"}, []bool{false, false, true}},
+		{"codes and count", []string{"ankara"}, 2, []string{"ankara", "izmir"}, []bool{false, true}},
+		{"count before codes", []string{"ankara"}, 2, []string{"izmir", "samsun", "ankara"}, []bool{false, false, true}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			g := newStartupGate(tt.codes, tt.minHospitals, time.Hour, logger)
+			g.begin()
+			defer func() {
+				if g.timer != nil {
+					g.timer.Stop()
+				}
+			}()
+
+			if open, _, _ := g.state(); open != (len(tt.codes) == 0 && tt.minHospitals == 0) {
+				t.Fatalf("initially open = %v", open)
+			}
+			for i, code := range tt.register {
+				g.registered(code)
+				open, waiting, _ := g.state()
+				if open != tt.wantOpen[i] {
+					t.Errorf("after %s: open = %v, want %v", code, open, tt.wantOpen[i])
+				}
+				if !open && slices.Contains(waiting, code) {
+					t.Errorf("after %s: still waiting for it", code)
+				}
+			}
+		})
+	}
+}
+
+func TestStartupGateTimeout(t *testing.T) {
+	g := newStartupGate(nil, 3, 10*time.Millisecond, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	g.begin()
+	g.registered("ankara")
+	if open, _, registered := g.state(); open || registered != 1 {
+		t.Fatalf("state() = %v, %d; want closed with 1 registered", open, registered)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if open, _, _ := g.state(); !open {
+		t.Error("gate still closed after startup_wait_timeout")
+	}
+}
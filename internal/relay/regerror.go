@@ -0,0 +1,41 @@
+package relay
+
+import "github.com/minasoft-technology/gordion-relay/internal/relay/grpc"
+
+// Registration error codes. A WebSocket agent gets "ERROR <code> <message>",
+// a gRPC edge gets the code in RegisterResponse.code next to the message.
+// Agents decide on the code whether to retry; the message is for people.
+const (
+	// Retryable: the same registration may succeed later
+	regShuttingDown     = "SHUTTING_DOWN"     // relay is stopping; reconnect, possibly to another instance
+	regRateLimited      = "RATE_LIMITED"      // too many failed attempts from this address; wait for the block to expire
+	regAlreadyConnected = "ALREADY_CONNECTED" // another agent holds the hospital (reject_duplicate_registration)
+	regAtCapacity       = "AT_CAPACITY"       // max_hospitals reached
+
+	// Permanent: retrying without a configuration change fails again
+	regInvalidFormat       = "INVALID_FORMAT"        // malformed REGISTER message
+	regInvalidHospitalCode = "INVALID_HOSPITAL_CODE" // hospital code not matching ^[a-z0-9-]{1,63}$
+	regInvalidSubdomain    = "INVALID_SUBDOMAIN"     // subdomain is not a DNS name
+	regUnsupportedProtocol = "UNSUPPORTED_PROTOCOL"  // tunnel protocol version not supported
+	regUnknownHospital     = "UNKNOWN_HOSPITAL"      // hospital ID not configured (gRPC)
+	regInvalidToken        = "AUTH_INVALID_TOKEN"    // wrong token, or unknown hospital/subdomain (WebSocket)
+	regInvalidCert         = "AUTH_INVALID_CERT"     // client certificate not valid for the hospital (gRPC)
+)
+
+// registrationError is the WebSocket reply rejecting a registration
+func registrationError(code, message string) []byte {
+	return []byte("ERROR " + code + " " + message)
+}
+
+// registrationRejected is the gRPC ack rejecting a registration
+func registrationRejected(code, message string) *grpc.RelayMessage {
+	return &grpc.RelayMessage{
+		Message: &grpc.RelayMessage_RegisterAck{
+			RegisterAck: &grpc.RegisterResponse{
+				Success: false,
+				Message: message,
+				Code:    code,
+			},
+		},
+	}
+}
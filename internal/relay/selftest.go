@@ -0,0 +1,140 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"time"
+
+	"github.com/google/uuid"
+)
+
+// selfTestTimeout bounds one self-test round trip
+const selfTestTimeout = 10 * time.Second
+
+// selfTestResult is the body of GET /admin/selftest
+type selfTestResult struct {
+	HospitalCode string  `json:"hospital_code"`
+	OK           bool    `json:"ok"`
+	Status       int     `json:"status,omitempty"` // HTTP status from the hospital (websocket mode)
+	LatencyMS    float64 `json:"latency_ms"`
+	Error        string  `json:"error,omitempty"`
+}
+
+// adminSelfTestHandler serves GET /admin/selftest?hospital=CODE. probe sends
+// a benign request through the hospital's tunnel; the answer is 200 when it
+// succeeded and 503 otherwise, so monitors can alert on the status alone.
+func adminSelfTestHandler(cfg *Config, hospitals *hospitalRegistry, probe func(ctx context.Context, hospital HospitalConfig) selfTestResult, logger *slog.Logger) http.HandlerFunc {
+	adminToken := cfg.AdminToken
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			w.Header().Set("Allow", http.MethodGet)
+			http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		if !adminAuthorized(r, adminToken) {
+			logger.Warn("Rejected admin self-test request", "remote_addr", cfg.ClientIP(r))
+			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		hospitalCode := r.URL.Query().Get("hospital")
+		if hospitalCode == "" {
+			http.Error(w, "hospital is required", http.StatusBadRequest)
+			return
+		}
+		hospital, ok := hospitals.byCode(hospitalCode)
+		if !ok {
+			http.Error(w, "Unknown hospital", http.StatusNotFound)
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(r.Context(), selfTestTimeout)
+		defer cancel()
+		result := probe(ctx, hospital)
+		result.HospitalCode = hospital.Code
+
+		logger.Info("Self-test completed",
+			"hospital_code", hospital.Code,
+			"ok", result.OK,
+			"status", result.Status,
+			"latency_ms", result.LatencyMS,
+			"error", result.Error)
+
+		status := http.StatusOK
+		if !result.OK {
+			status = http.StatusServiceUnavailable
+		}
+		if err := writeJSON(w, status, result); err != nil {
+			logger.Debug("Failed to write self-test response", "error", err)
+		}
+	}
+}
+
+// selfTestWriter discards a self-test response, keeping only its headers
+type selfTestWriter struct {
+	header http.Header
+}
+
+func (w *selfTestWriter) Header() http.Header         { return w.header }
+func (w *selfTestWriter) Write(p []byte) (int, error) { return len(p), nil }
+func (w *selfTestWriter) WriteHeader(int)             {}
+
+// selfTest sends GET self_test_path through the hospital's agent with the
+// regular forwarding code. Any 2xx or 3xx answer counts as success.
+func (s *WebSocketServer) selfTest(ctx context.Context, hospital HospitalConfig) selfTestResult {
+	s.agentsMutex.RLock()
+	agent, exists := s.agents[hospital.Code]
+	s.agentsMutex.RUnlock()
+	if !exists {
+		return selfTestResult{Error: "hospital not connected"}
+	}
+
+	path := s.config.SelfTestPath
+	r, err := http.NewRequestWithContext(ctx, http.MethodGet, "http://"+hospital.Subdomain+path, nil)
+	if err != nil {
+		return selfTestResult{Error: err.Error()}
+	}
+	r.RequestURI = path
+	r.RemoteAddr = "127.0.0.1:0" // the relay itself is the client
+
+	rec := newResponseRecorder(&selfTestWriter{header: make(http.Header)})
+	logger := s.logger.With("hospital_code", hospital.Code, "self_test", true)
+	start := time.Now()
+	err = s.forwardRequest(rec, r, agent, s.config.limitsFor(&hospital), logger)
+	result := selfTestResult{
+		Status:    rec.status,
+		LatencyMS: float64(time.Since(start).Microseconds()) / 1000,
+	}
+	switch {
+	case err != nil:
+		result.Error = err.Error()
+	case rec.status >= http.StatusBadRequest:
+		result.Error = fmt.Sprintf("%s answered %d", path, rec.status)
+	default:
+		result.OK = true
+	}
+	return result
+}
+
+// selfTest sends a metadata-only fetch without a type or UIDs, carrying
+// self_test_path as its sub_path, to one of the hospital's edges. Edges
+// should answer it with DataComplete when healthy.
+func (s *GRPCServer) selfTest(ctx context.Context, hospital HospitalConfig) selfTestResult {
+	logger := s.logger.With("hospital_code", hospital.Code, "self_test", true)
+	target := fetchTarget{SubPath: s.config.SelfTestPath}
+	start := time.Now()
+	_, _, edge, err := s.headFromEdge(ctx, uuid.NewString(), logger, hospital.HospitalID, target, nil, selfTestTimeout)
+	result := selfTestResult{LatencyMS: float64(time.Since(start).Microseconds()) / 1000}
+	switch {
+	case edge == nil:
+		result.Error = "hospital not connected"
+	case err != nil:
+		result.Error = err.Error()
+	default:
+		result.OK = true
+	}
+	return result
+}
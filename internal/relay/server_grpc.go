@@ -1,27 +1,64 @@
 package relay
 
 import (
+	"cmp"
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
+	"maps"
+	"mime/multipart"
 	"net"
 	"net/http"
-	"strings"
+	"slices"
+	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/minasoft-technology/gordion-relay/internal/relay/grpc"
 	"github.com/minasoft-technology/gordion-relay/internal/security/timetoken"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
 	grpclib "google.golang.org/grpc"
 	"google.golang.org/grpc/credentials"
+	_ "google.golang.org/grpc/encoding/gzip" // Lets edges opt in to gzip-compressed streams
 	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/protobuf/proto"
 )
 
 const (
-	MaxMessageSize = 16 * 1024 * 1024 // 16MB for large DICOMs
+	MaxMessageSize = 16 * 1024 * 1024 // Default message size limit: 16MB for large DICOMs
+
+	// Bounds for the configurable message size limits
+	minMessageSizeLimit = 64 * 1024
+	maxMessageSizeLimit = 256 * 1024 * 1024
 )
 
+// errFetchTimeout marks fetches abandoned because the edge stopped responding
+var errFetchTimeout = errors.New("fetch timeout")
+
+// errEdgeReconnected fails fetches that were mid-transfer when their edge
+// reconnected; the new stream cannot resume them
+var errEdgeReconnected = fmt.Errorf("%w: edge reconnected during the transfer", errAgentDisconnected)
+
+// errEdgeBusy rejects fetches for an edge that already has max_pending_per_edge
+var errEdgeBusy = errors.New("edge has too many pending requests")
+
+// pendingSweepInterval is how often edges are checked for stale pending requests
+const pendingSweepInterval = 30 * time.Second
+
+// errEdgeShutdown fails the fetches of an edge that announced a clean shutdown
+var errEdgeShutdown = fmt.Errorf("%w: edge shut down", errAgentDisconnected)
+
+// maxFetchResends caps how often a fetch is re-sent to a reconnected edge
+const maxFetchResends = 2
+
 // GRPCServer manages gRPC tunnel connections from multiple edge servers
 type GRPCServer struct {
 	grpc.UnimplementedTunnelServiceServer
@@ -29,13 +66,51 @@ type GRPCServer struct {
 	config *Config
 	logger *slog.Logger
 
-	// Edge connections by hospital ID
-	edges   map[string]*EdgeConnection // hospitalID -> connection
+	// Edge connections by hospital ID; redundant edges of a hospital share a pool
+	edges   map[string][]*EdgeConnection // hospitalID -> connections
 	edgesMu sync.RWMutex
 
+	// Static and dynamically registered hospitals
+	hospitals *hospitalRegistry
+
+	// Single-use download token tracking
+	replayStore *timetoken.MemoryReplayStore
+
 	// HTTP server for viewer requests
 	httpServer *http.Server
 	grpcServer *grpclib.Server
+
+	// Graceful shutdown
+	running  bool
+	runMutex sync.RWMutex
+	inflight sync.WaitGroup // instance downloads in progress
+
+	// Caps concurrently forwarded requests (MaxConcurrentConn)
+	limiter *concurrencyLimiter
+
+	// Per-hospital response bandwidth
+	bandwidth *bandwidthLimiters
+
+	// Hospitals answered with 503 while under maintenance
+	maintenance *maintenanceSet
+
+	// Hospitals answered with 503 while their requests keep failing (nil when disabled)
+	breakers *circuitBreakers
+
+	// Connect/disconnect notifications
+	events *eventHub
+
+	// Registrations per edge, kept across connections for /status
+	reconnects *reconnectCounter
+
+	// Holds /ready until the startup hospitals have registered
+	startup *startupGate
+
+	// Edge streams that have not registered yet (MaxPendingRegistrations)
+	registering *registrationSlots
+
+	// Download token audit trail (nil when disabled)
+	audit *auditLogger
 }
 
 // EdgeConnection represents one connected edge server
@@ -50,6 +125,18 @@ type EdgeConnection struct {
 	// Pending fetch requests
 	pendingRequests map[string]*PendingRequest
 	pendingMu       sync.RWMutex
+	maxPending      int
+	pendingGauge    prometheus.Gauge // this edge's gordion_relay_edge_pending_requests
+
+	// gRPC streams do not support concurrent Send calls
+	sendMu sync.Mutex
+
+	// per-hospital counters for /status
+	stats forwardStats
+
+	// closed to make the Stream handler return and drop the edge
+	evicted   chan struct{}
+	evictOnce sync.Once
 }
 
 // PendingRequest tracks in-flight fetch requests
@@ -58,22 +145,75 @@ type PendingRequest struct {
 	StartTime    time.Time
 	ResponseChan chan *grpc.DataResponse
 	ErrorChan    chan error
+
+	// closed when the request is removed from pendingRequests
+	done chan struct{}
+
+	// Kept so the request can be re-sent to a reconnected edge
+	cmd     *grpc.FetchCommand
+	resends int // guarded by the owning edge's pendingMu
+
+	started    atomic.Bool                    // the edge has sent data for it
+	edge       atomic.Pointer[EdgeConnection] // connection currently serving it
+	lastActive atomic.Int64                   // unix nanos of the start or the latest data
 }
 
 // NewGRPCServer creates a new gRPC relay server
 func NewGRPCServer(cfg *Config, logger *slog.Logger) *GRPCServer {
 	return &GRPCServer{
-		config: cfg,
-		logger: logger,
-		edges:  make(map[string]*EdgeConnection),
+		config:      cfg,
+		logger:      logger,
+		edges:       make(map[string][]*EdgeConnection),
+		hospitals:   newHospitalRegistry(cfg.Hospitals),
+		replayStore: timetoken.NewMemoryReplayStore(),
+		limiter:     newConcurrencyLimiter(cfg.MaxConcurrentConn),
+		bandwidth:   newBandwidthLimiters(),
+		maintenance: newMaintenanceSet(cfg.Maintenance),
+		breakers:    newCircuitBreakers(cfg.CircuitBreaker, logger),
+		events:      newEventHub(logger),
+		reconnects:  newReconnectCounter(),
+		startup:     newStartupGate(cfg.StartupWaitHospitals, cfg.StartupWaitMinHospitals, cfg.StartupWaitTimeout.ToDuration(), logger),
+		registering: newRegistrationSlots(cfg.MaxPendingRegistrations),
 	}
 }
 
 // Start initializes and starts both gRPC and HTTP servers
 func (s *GRPCServer) Start(ctx context.Context) error {
+	s.runMutex.Lock()
+	s.running = true
+	s.runMutex.Unlock()
+
+	audit, err := newAuditLogger(s.config.Audit)
+	if err != nil {
+		return err
+	}
+	s.audit = audit
+
+	// Expire used download tokens from the replay cache
+	go s.replayStore.Run(ctx, time.Minute)
+
+	if s.config.Webhooks != nil && s.config.Webhooks.ConnectURL != "" {
+		go runConnectWebhook(ctx, s.config.Webhooks, s.events, s.logger)
+	}
+
+	// Subscribe to dynamic hospital registrations
+	if s.config.NATS != nil {
+		if err := startNATSDiscovery(ctx, s.config, s.hospitals, s.logger); err != nil {
+			return err
+		}
+	}
+
+	// Drop edges whose stream went silent
+	go s.monitorEdges(ctx)
+
+	// Drop pending requests nobody finished
+	go s.sweepPending(ctx)
+
+	s.startup.begin()
+
 	// Start gRPC server for edge connections
 	go func() {
-		if err := s.startGRPCServer(); err != nil {
+		if err := s.startGRPCServer(ctx); err != nil {
 			s.logger.Error("gRPC server failed", "error", err)
 		}
 	}()
@@ -83,7 +223,7 @@ func (s *GRPCServer) Start(ctx context.Context) error {
 }
 
 // startGRPCServer starts the gRPC server for edge connections
-func (s *GRPCServer) startGRPCServer() error {
+func (s *GRPCServer) startGRPCServer(ctx context.Context) error {
 	listenAddr := s.config.ListenAddr
 	if listenAddr == "" {
 		listenAddr = ":443"
@@ -93,6 +233,7 @@ func (s *GRPCServer) startGRPCServer() error {
 	if err != nil {
 		return fmt.Errorf("failed to listen on %s: %w", listenAddr, err)
 	}
+	lis = newLimitListener(lis, s.config.MaxConnections, modeGRPC)
 
 	// gRPC server options
 	var opts []grpclib.ServerOption
@@ -102,31 +243,40 @@ func (s *GRPCServer) startGRPCServer() error {
 		if s.config.TLS.CertFile == "" || s.config.TLS.KeyFile == "" {
 			return fmt.Errorf("TLS enabled but cert/key files not specified")
 		}
-		creds, err := credentials.NewServerTLSFromFile(s.config.TLS.CertFile, s.config.TLS.KeyFile)
+		tlsConfig, certs, err := serverTLSConfig(&s.config.TLS, s.logger)
 		if err != nil {
 			return fmt.Errorf("failed to load TLS credentials: %w", err)
 		}
-		opts = append(opts, grpclib.Creds(creds))
-		s.logger.Info("gRPC server using TLS", "cert", s.config.TLS.CertFile)
+		go certs.run(ctx, certReloadInterval)
+		opts = append(opts, grpclib.Creds(credentials.NewTLS(tlsConfig)))
+		s.logger.Info("gRPC server using TLS",
+			"cert", s.config.TLS.CertFile,
+			"client_ca", s.config.TLS.ClientCAFile,
+			"client_auth_mode", s.config.TLS.ClientAuthMode)
 	} else {
 		s.logger.Warn("gRPC server running without TLS (not recommended for production)")
 	}
 
 	// Keep-alive enforcement (aggressive for firewall traversal)
+	tuning := s.config.GRPC
 	kaep := keepalive.EnforcementPolicy{
-		MinTime:             5 * time.Second, // Allow pings every 5s
-		PermitWithoutStream: true,            // Allow pings without streams
+		MinTime:             tuning.MinPingInterval.ToDuration(), // Fastest edge ping rate allowed
+		PermitWithoutStream: true,                                // Allow pings without streams
 	}
 	kasp := keepalive.ServerParameters{
-		Time:    10 * time.Second, // Send pings every 10s if no activity
-		Timeout: 5 * time.Second,  // Wait 5s for ping ack
+		Time:              tuning.KeepaliveTime.ToDuration(),     // Send pings after this much inactivity
+		Timeout:           tuning.KeepaliveTimeout.ToDuration(),  // Wait this long for ping ack
+		MaxConnectionIdle: tuning.MaxConnectionIdle.ToDuration(), // 0 means infinity
 	}
 	opts = append(opts, grpclib.KeepaliveEnforcementPolicy(kaep), grpclib.KeepaliveParams(kasp))
+	if tuning.MaxConcurrentStreams > 0 {
+		opts = append(opts, grpclib.MaxConcurrentStreams(tuning.MaxConcurrentStreams))
+	}
 
 	// Message size limits
 	opts = append(opts,
-		grpclib.MaxRecvMsgSize(MaxMessageSize),
-		grpclib.MaxSendMsgSize(MaxMessageSize),
+		grpclib.MaxRecvMsgSize(tuning.MaxRecvMsgSize),
+		grpclib.MaxSendMsgSize(tuning.MaxSendMsgSize),
 	)
 
 	// Create and register server
@@ -139,10 +289,40 @@ func (s *GRPCServer) startGRPCServer() error {
 
 // Stream implements the bidirectional streaming RPC
 func (s *GRPCServer) Stream(stream grpc.TunnelService_StreamServer) error {
-	// First message must be registration
-	msg, err := stream.Recv()
-	if err != nil {
-		return fmt.Errorf("failed to receive registration: %w", err)
+	// Bound streams still waiting for their registration; the slot is given
+	// back once registered
+	release, ok := s.registering.tryAcquire()
+	if !ok {
+		s.logger.Warn("Rejecting edge stream, too many pending registrations",
+			"max_pending_registrations", s.config.MaxPendingRegistrations)
+		connectionsRejected.WithLabelValues(modeGRPC, rejectMaxPendingRegistrations).Inc()
+		return fmt.Errorf("too many pending registrations")
+	}
+	defer release()
+
+	// First message must be registration. Recv has no deadline, so wait in
+	// a goroutine; returning ends the stream and unblocks it.
+	type recvResult struct {
+		msg *grpc.EdgeMessage
+		err error
+	}
+	first := make(chan recvResult, 1)
+	go func() {
+		msg, err := stream.Recv()
+		first <- recvResult{msg, err}
+	}()
+	registrationTimeout := s.config.RegistrationTimeout.ToDuration()
+	var msg *grpc.EdgeMessage
+	select {
+	case res := <-first:
+		if res.err != nil {
+			return fmt.Errorf("failed to receive registration: %w", res.err)
+		}
+		msg = res.msg
+	case <-time.After(registrationTimeout):
+		s.logger.Warn("Registration not received in time", "timeout", registrationTimeout.String())
+		registrations.WithLabelValues(modeGRPC, "timeout").Inc()
+		return fmt.Errorf("registration not received within %s", registrationTimeout)
 	}
 
 	reg := msg.GetRegister()
@@ -150,37 +330,48 @@ func (s *GRPCServer) Stream(stream grpc.TunnelService_StreamServer) error {
 		return fmt.Errorf("first message must be registration")
 	}
 
-	s.logger.Info("Registration request received",
-		"hospital_id", reg.HospitalId,
-		"edge_server_id", reg.EdgeServerId,
-		"version", reg.Version)
+	if !s.isRunning() {
+		stream.Send(registrationRejected(regShuttingDown, "server shutting down"))
+		return fmt.Errorf("server shutting down")
+	}
+
+	remoteAddr := ""
+	if p, ok := peer.FromContext(stream.Context()); ok {
+		remoteAddr = p.Addr.String()
+	}
+	logger := edgeLogger(s.logger, reg.HospitalId, reg.EdgeServerId, remoteAddr)
+	logger.Info("Registration request received", "version", reg.Version)
 
 	// Find hospital configuration
 	hospital := s.findHospitalByID(reg.HospitalId)
 	if hospital == nil {
-		s.logger.Warn("Unknown hospital ID", "hospital_id", reg.HospitalId)
-		stream.Send(&grpc.RelayMessage{
-			Message: &grpc.RelayMessage_RegisterAck{
-				RegisterAck: &grpc.RegisterResponse{
-					Success: false,
-					Message: fmt.Sprintf("unknown hospital: %s", reg.HospitalId),
-				},
-			},
-		})
+		logger.Warn("Unknown hospital ID")
+		registrations.WithLabelValues(modeGRPC, "unknown_hospital").Inc()
+		stream.Send(registrationRejected(regUnknownHospital, fmt.Sprintf("unknown hospital: %s", reg.HospitalId)))
 		return fmt.Errorf("unknown hospital: %s", reg.HospitalId)
 	}
+	logger = logger.With("hospital_code", hospital.Code)
+
+	// Validate the client certificate identity
+	if s.config.TLS.requiresClientCert() {
+		cert := peerCertificate(stream.Context())
+		if cert == nil || !certMatchesHospital(cert, hospital) {
+			subject := ""
+			if cert != nil {
+				subject = cert.Subject.String()
+			}
+			logger.Warn("Client certificate does not match hospital", "subject", subject)
+			registrations.WithLabelValues(modeGRPC, "invalid_certificate").Inc()
+			stream.Send(registrationRejected(regInvalidCert, "client certificate not valid for hospital"))
+			return fmt.Errorf("client certificate not valid for hospital: %s", reg.HospitalId)
+		}
+	}
 
 	// Validate token
-	if reg.Token != hospital.Token {
-		s.logger.Warn("Invalid token", "hospital_id", reg.HospitalId)
-		stream.Send(&grpc.RelayMessage{
-			Message: &grpc.RelayMessage_RegisterAck{
-				RegisterAck: &grpc.RegisterResponse{
-					Success: false,
-					Message: "invalid authentication token",
-				},
-			},
-		})
+	if s.config.TLS.requiresToken() && !secureTokenEqual(reg.Token, hospital.Token) {
+		logger.Warn("Invalid token")
+		registrations.WithLabelValues(modeGRPC, "invalid_token").Inc()
+		stream.Send(registrationRejected(regInvalidToken, "invalid authentication token"))
 		return fmt.Errorf("invalid token for hospital: %s", reg.HospitalId)
 	}
 
@@ -192,19 +383,62 @@ func (s *GRPCServer) Stream(stream grpc.TunnelService_StreamServer) error {
 		Connected:       time.Now(),
 		LastSeen:        time.Now(),
 		pendingRequests: make(map[string]*PendingRequest),
+		maxPending:      s.config.GRPC.MaxPendingPerEdge,
+		pendingGauge:    edgePendingRequests.WithLabelValues(reg.HospitalId, reg.EdgeServerId),
+		evicted:         make(chan struct{}),
 	}
 
+	// An edge server reconnecting replaces its old connection; a different
+	// edge server of the same hospital joins the pool
 	s.edgesMu.Lock()
-	s.edges[reg.HospitalId] = edgeConn
+	var resend []*PendingRequest
+	pool := s.edges[reg.HospitalId]
+	firstEdge := len(pool) == 0
+	// More edges of a connected hospital do not count against max_hospitals
+	if firstEdge && s.config.atCapacity(len(s.edges)) {
+		s.edgesMu.Unlock()
+		logger.Warn("Rejecting registration, server at capacity", "max_hospitals", s.config.MaxHospitals)
+		registrations.WithLabelValues(modeGRPC, "at_capacity").Inc()
+		stream.Send(registrationRejected(regAtCapacity, "server at capacity"))
+		return fmt.Errorf("server at capacity: %d hospitals connected", s.config.MaxHospitals)
+	}
+	replaced := false
+	for i, existing := range pool {
+		if existing.EdgeServerID != reg.EdgeServerId {
+			continue
+		}
+		var failed int
+		resend, failed = existing.handOver(edgeConn)
+		logger.Info("Replacing existing edge connection",
+			"resent_requests", len(resend),
+			"failed_requests", failed)
+		existing.evict()
+		pool[i] = edgeConn
+		replaced = true
+		break
+	}
+	if !replaced {
+		pool = append(pool, edgeConn)
+	}
+	s.edges[reg.HospitalId] = pool
+	connectedHospitals.WithLabelValues(modeGRPC).Set(float64(len(s.edges)))
 	s.edgesMu.Unlock()
+	registrations.WithLabelValues(modeGRPC, "success").Inc()
+
+	s.reconnects.registered(edgeConn.reconnectKey())
+	s.startup.registered(hospital.Code)
+	// Events are per hospital, so further edges join silently like
+	// removeEdge leaves silently until the last one is gone
+	if firstEdge {
+		s.events.publish(hospital.Code, EventConnected, remoteAddr)
+	}
 
-	s.logger.Info("✅ Edge registered",
-		"hospital_id", reg.HospitalId,
-		"edge_server_id", reg.EdgeServerId,
-		"version", reg.Version)
+	logger.Info("✅ Edge registered",
+		"version", reg.Version,
+		"edges", len(pool))
 
 	// Send acknowledgment
-	err = stream.Send(&grpc.RelayMessage{
+	err := stream.Send(&grpc.RelayMessage{
 		Message: &grpc.RelayMessage_RegisterAck{
 			RegisterAck: &grpc.RegisterResponse{
 				Success:    true,
@@ -214,51 +448,285 @@ func (s *GRPCServer) Stream(stream grpc.TunnelService_StreamServer) error {
 		},
 	})
 	if err != nil {
-		s.edgesMu.Lock()
-		delete(s.edges, reg.HospitalId)
-		s.edgesMu.Unlock()
+		s.removeEdge(hospital.Code, edgeConn, remoteAddr)
+		edgeConn.failPending(errAgentDisconnected)
 		return err
 	}
+	release()
+	edgeConn.resend(resend)
+
+	// Handle incoming messages from edge. Recv cannot be interrupted, so it
+	// runs in its own goroutine; returning from the handler ends the stream.
+	// goodbye is only read after recvDone is closed.
+	var goodbye *grpc.Goodbye
+	recvDone := make(chan struct{})
+	go func() {
+		defer close(recvDone)
+		for {
+			msg, err := stream.Recv()
+			if err != nil {
+				if err == io.EOF {
+					logger.Warn("Edge dropped the stream without saying goodbye")
+				} else {
+					logger.Error("Receive error", "error", err)
+				}
+				return
+			}
 
-	// Handle incoming messages from edge
-	for {
-		msg, err := stream.Recv()
-		if err != nil {
-			if err == io.EOF {
-				s.logger.Info("Edge disconnected", "hospital_id", reg.HospitalId)
-			} else {
-				s.logger.Error("Receive error", "hospital_id", reg.HospitalId, "error", err)
+			edgeConn.mu.Lock()
+			edgeConn.LastSeen = time.Now()
+			edgeConn.mu.Unlock()
+
+			switch m := msg.Message.(type) {
+			case *grpc.EdgeMessage_Data:
+				// Dispatch in order: chunks must not overtake each other or the
+				// completion message, and only this loop sends on ResponseChan
+				edgeConn.handleDataResponse(m.Data)
+			case *grpc.EdgeMessage_Keepalive:
+				logger.Debug("Received keep-alive", "seq", m.Keepalive.Sequence)
+			case *grpc.EdgeMessage_Status:
+				logger.Debug("Status update", "healthy", m.Status.Healthy)
+			case *grpc.EdgeMessage_Goodbye:
+				// Orderly shutdown; nothing useful can follow
+				goodbye = m.Goodbye
+				logger.Info("Edge deregistered", "reason", m.Goodbye.Reason)
+				return
 			}
-			break
 		}
+	}()
+
+	disconnect, pendingErr := "unclean", errAgentDisconnected
+	select {
+	case <-recvDone:
+		if goodbye != nil {
+			disconnect, pendingErr = "clean", errEdgeShutdown
+		}
+	case <-edgeConn.evicted:
+		disconnect = "evicted"
+	}
+	edgeDisconnects.WithLabelValues(modeGRPC, disconnect).Inc()
+
+	// Unregister on disconnect (unless a newer connection already replaced us).
+	// Requests not handed over to a newer connection cannot complete anymore.
+	s.removeEdge(hospital.Code, edgeConn, remoteAddr)
+	edgeConn.failPending(pendingErr)
+
+	logger.Info("Edge connection closed", "disconnect", disconnect)
+	return nil
+}
+
+// removeEdge unregisters edgeConn unless a newer connection replaced it.
+// The hospital counts as disconnected once its last edge is gone.
+func (s *GRPCServer) removeEdge(hospitalCode string, edgeConn *EdgeConnection, remoteAddr string) {
+	s.edgesMu.Lock()
+	pool := s.edges[edgeConn.HospitalID]
+	i := slices.Index(pool, edgeConn)
+	if i >= 0 {
+		pool = slices.Delete(pool, i, i+1)
+		if len(pool) == 0 {
+			delete(s.edges, edgeConn.HospitalID)
+		} else {
+			s.edges[edgeConn.HospitalID] = pool
+		}
+	}
+	connectedHospitals.WithLabelValues(modeGRPC).Set(float64(len(s.edges)))
+	s.edgesMu.Unlock()
+	if i >= 0 {
+		edgePendingRequests.DeleteLabelValues(edgeConn.HospitalID, edgeConn.EdgeServerID)
+	}
+	if i >= 0 && len(pool) == 0 {
+		s.events.publish(hospitalCode, EventDisconnected, remoteAddr)
+	}
+}
+
+// evict makes the edge's Stream handler return, closing the stream
+func (ec *EdgeConnection) evict() {
+	ec.evictOnce.Do(func() { close(ec.evicted) })
+}
+
+// reconnectKey identifies the edge across connections: each edge server of a
+// hospital reconnects on its own
+func (ec *EdgeConnection) reconnectKey() string {
+	return ec.HospitalID + "/" + ec.EdgeServerID
+}
+
+// isEvicted reports whether the edge is on its way out
+func (ec *EdgeConnection) isEvicted() bool {
+	select {
+	case <-ec.evicted:
+		return true
+	default:
+		return false
+	}
+}
+
+// pendingCount returns the number of requests waiting on the edge
+func (ec *EdgeConnection) pendingCount() int {
+	ec.pendingMu.RLock()
+	defer ec.pendingMu.RUnlock()
+	return len(ec.pendingRequests)
+}
 
-		edgeConn.mu.Lock()
-		edgeConn.LastSeen = time.Now()
-		edgeConn.mu.Unlock()
+// pickEdge returns the hospital's live edge with the fewest pending
+// requests, skipping those in tried, or nil if none is left
+func (s *GRPCServer) pickEdge(hospitalID string, tried []*EdgeConnection) *EdgeConnection {
+	s.edgesMu.RLock()
+	defer s.edgesMu.RUnlock()
 
-		switch m := msg.Message.(type) {
-		case *grpc.EdgeMessage_Data:
-			go edgeConn.handleDataResponse(m.Data)
-		case *grpc.EdgeMessage_Keepalive:
-			s.logger.Debug("Received keep-alive", "hospital_id", reg.HospitalId, "seq", m.Keepalive.Sequence)
-		case *grpc.EdgeMessage_Status:
-			s.logger.Debug("Status update", "hospital_id", reg.HospitalId, "healthy", m.Status.Healthy)
+	var best *EdgeConnection
+	bestPending := 0
+	for _, edge := range s.edges[hospitalID] {
+		if edge.isEvicted() || slices.Contains(tried, edge) {
+			continue
 		}
+		if pending := edge.pendingCount(); best == nil || pending < bestPending {
+			best, bestPending = edge, pending
+		}
+	}
+	return best
+}
+
+// disconnectHospital ends all of a hospital's streams on operator request.
+// Edges are keyed by hospital ID, so the code is resolved first.
+func (s *GRPCServer) disconnectHospital(hospitalCode string) bool {
+	hospital, ok := s.hospitals.byCode(hospitalCode)
+	if !ok {
+		return false
 	}
 
-	// Unregister on disconnect
 	s.edgesMu.Lock()
-	delete(s.edges, reg.HospitalId)
+	pool, ok := s.edges[hospital.HospitalID]
+	if ok {
+		delete(s.edges, hospital.HospitalID)
+	}
+	connectedHospitals.WithLabelValues(modeGRPC).Set(float64(len(s.edges)))
 	s.edgesMu.Unlock()
 
-	s.logger.Info("Edge connection closed", "hospital_id", reg.HospitalId)
-	return nil
+	if !ok {
+		return false
+	}
+	// removeEdge no longer finds these edges, so their gauges go here
+	for _, edge := range pool {
+		edgePendingRequests.DeleteLabelValues(edge.HospitalID, edge.EdgeServerID)
+		edge.evict()
+	}
+	s.events.publish(hospital.Code, EventDisconnected, "")
+	return true
+}
+
+// Events subscribes to hospital connect/disconnect events. Call the returned
+// function to unsubscribe; a subscriber that falls behind misses events.
+func (s *GRPCServer) Events() (<-chan Event, func()) {
+	return s.events.subscribe()
+}
+
+// monitorEdges periodically drops edges that stopped sending keep-alives.
+// The Stream handler removes the evicted edge from s.edges on its way out.
+func (s *GRPCServer) monitorEdges(ctx context.Context) {
+	ticker := time.NewTicker(s.config.HeartbeatCheckInterval.ToDuration())
+	defer ticker.Stop()
+
+	timeout := s.config.HeartbeatTimeout.ToDuration()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.edgesMu.RLock()
+			for hospitalID, pool := range s.edges {
+				for _, edge := range pool {
+					edge.mu.RLock()
+					idle := time.Since(edge.LastSeen)
+					edge.mu.RUnlock()
+
+					if idle > timeout {
+						s.logger.Warn("Evicting stale edge",
+							"hospital_id", hospitalID,
+							"edge_server_id", edge.EdgeServerID,
+							"idle", idle.Round(time.Second).String())
+						edge.evict()
+					}
+				}
+			}
+			s.edgesMu.RUnlock()
+		}
+	}
+}
+
+// sweepPending periodically expires pending requests that saw no data for
+// twice their hospital's fetch timeout. The waiting fetch gives up after one
+// fetch timeout and removes its request, so this only catches entries that
+// were left behind, e.g. by an edge that never completed a request.
+func (s *GRPCServer) sweepPending(ctx context.Context) {
+	ticker := time.NewTicker(pendingSweepInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.edgesMu.RLock()
+			var edges []*EdgeConnection
+			for _, pool := range s.edges {
+				edges = append(edges, pool...)
+			}
+			s.edgesMu.RUnlock()
+
+			for _, edge := range edges {
+				timeout := s.config.limitsFor(s.findHospitalByID(edge.HospitalID)).FetchTimeout
+				if expired := edge.expirePending(2 * timeout); expired > 0 {
+					s.logger.Warn("Expired stale pending requests",
+						"hospital_id", edge.HospitalID,
+						"edge_server_id", edge.EdgeServerID,
+						"expired", expired)
+				}
+			}
+		}
+	}
+}
+
+// expirePending fails the requests idle for longer than maxIdle, tells the
+// edge to stop them and returns how many there were
+func (ec *EdgeConnection) expirePending(maxIdle time.Duration) int {
+	cutoff := time.Now().Add(-maxIdle).UnixNano()
+	ec.pendingMu.RLock()
+	var stale []*PendingRequest
+	for _, req := range ec.pendingRequests {
+		if req.lastActive.Load() < cutoff {
+			stale = append(stale, req)
+		}
+	}
+	ec.pendingMu.RUnlock()
+
+	expired := 0
+	for _, req := range stale {
+		if !ec.removePending(req.RequestID) {
+			continue // finished meanwhile
+		}
+		expired++
+		req.ErrorChan <- fmt.Errorf("%w: no data for %s", errFetchTimeout, maxIdle)
+		_ = ec.send(&grpc.RelayMessage{
+			Message: &grpc.RelayMessage_Cancel{
+				Cancel: &grpc.CancelCommand{
+					RequestId: req.RequestID,
+					Reason:    "fetch timeout",
+				},
+			},
+		})
+	}
+	return expired
 }
 
 // handleDataResponse routes data responses to waiting requests
 func (ec *EdgeConnection) handleDataResponse(data *grpc.DataResponse) {
+	// Marked under the lock so handOver sees whether data already went out
 	ec.pendingMu.RLock()
 	req, exists := ec.pendingRequests[data.RequestId]
+	if exists {
+		req.started.Store(true)
+		req.lastActive.Store(time.Now().UnixNano())
+	}
 	ec.pendingMu.RUnlock()
 
 	if !exists {
@@ -267,43 +735,148 @@ func (ec *EdgeConnection) handleDataResponse(data *grpc.DataResponse) {
 
 	// Check for error
 	if err := data.GetError(); err != nil {
-		req.ErrorChan <- fmt.Errorf("%s: %s", err.ErrorCode, err.ErrorMessage)
+		if ec.removePending(data.RequestId) {
+			req.ErrorChan <- fmt.Errorf("%s: %s", err.ErrorCode, err.ErrorMessage)
+		}
 		return
 	}
 
 	// Check for completion
 	if complete := data.GetComplete(); complete != nil {
-		close(req.ResponseChan) // Signal completion
-		ec.pendingMu.Lock()
-		delete(ec.pendingRequests, data.RequestId)
-		ec.pendingMu.Unlock()
+		if ec.removePending(data.RequestId) {
+			close(req.ResponseChan) // Signal completion
+		}
 		return
 	}
 
-	// Send data to response channel
-	req.ResponseChan <- data
+	// Send data to response channel; a request reaped while we wait is dropped
+	select {
+	case req.ResponseChan <- data:
+	case <-req.done:
+	}
+}
+
+// ReloadHospitals atomically replaces the statically configured hospitals.
+// Existing connections stay up; only new lookups see the updated list.
+func (s *GRPCServer) ReloadHospitals(hospitals []HospitalConfig) {
+	added, removed := s.hospitals.replaceStatic(hospitals)
+	s.logger.Info("Hospital configuration reloaded",
+		"hospitals", len(hospitals),
+		"added", added,
+		"removed", removed)
+}
+
+// removePending deletes a pending request and reports whether this call removed it.
+// Completion, edge errors and cancellation race to finish a request; only the
+// caller that gets true may signal the request's channels or notify the edge.
+func (ec *EdgeConnection) removePending(requestID string) bool {
+	ec.pendingMu.Lock()
+	defer ec.pendingMu.Unlock()
+
+	req, exists := ec.pendingRequests[requestID]
+	if !exists {
+		return false
+	}
+	delete(ec.pendingRequests, requestID)
+	ec.pendingGauge.Dec()
+	close(req.done)
+	return true
+}
+
+// handOver moves requests the edge has not started answering to next, the
+// connection replacing ec after a reconnect, and returns them for resend.
+// Requests already partly streamed, or re-sent too often, fail instead.
+// next must not be serving requests yet.
+func (ec *EdgeConnection) handOver(next *EdgeConnection) (moved []*PendingRequest, failed int) {
+	ec.pendingMu.Lock()
+	defer ec.pendingMu.Unlock()
+
+	for id, req := range ec.pendingRequests {
+		delete(ec.pendingRequests, id)
+		ec.pendingGauge.Dec()
+		if req.started.Load() || req.resends >= maxFetchResends {
+			close(req.done)
+			req.ErrorChan <- errEdgeReconnected
+			failed++
+			continue
+		}
+		req.resends++
+		req.edge.Store(next)
+		moved = append(moved, req)
+	}
+
+	next.pendingMu.Lock()
+	for _, req := range moved {
+		next.pendingRequests[req.RequestID] = req
+	}
+	next.pendingGauge.Add(float64(len(moved)))
+	next.pendingMu.Unlock()
+	return moved, failed
+}
+
+// resend sends the fetch commands of requests handed over from a previous connection
+func (ec *EdgeConnection) resend(reqs []*PendingRequest) {
+	for _, req := range reqs {
+		err := ec.send(&grpc.RelayMessage{
+			Message: &grpc.RelayMessage_Command{Command: req.cmd},
+		})
+		if err != nil && ec.removePending(req.RequestID) {
+			req.ErrorChan <- fmt.Errorf("failed to resend fetch command: %w", err)
+		}
+	}
+}
+
+// failPending fails every request still waiting on the edge
+func (ec *EdgeConnection) failPending(err error) {
+	ec.pendingMu.Lock()
+	defer ec.pendingMu.Unlock()
+
+	for id, req := range ec.pendingRequests {
+		delete(ec.pendingRequests, id)
+		ec.pendingGauge.Dec()
+		close(req.done)
+		req.ErrorChan <- err
+	}
+}
+
+// send writes a message to the edge stream, serializing concurrent senders
+func (ec *EdgeConnection) send(msg *grpc.RelayMessage) error {
+	ec.sendMu.Lock()
+	defer ec.sendMu.Unlock()
+	return ec.Stream.Send(msg)
+}
+
+// cancelRequest drops a pending request and tells the edge to stop streaming it
+func (ec *EdgeConnection) cancelRequest(requestID, reason string) error {
+	if !ec.removePending(requestID) {
+		return nil // already completed or failed
+	}
+	return ec.send(&grpc.RelayMessage{
+		Message: &grpc.RelayMessage_Cancel{
+			Cancel: &grpc.CancelCommand{
+				RequestId: requestID,
+				Reason:    reason,
+			},
+		},
+	})
 }
 
 // findHospitalByID finds hospital config by hospital ID (case-insensitive)
 func (s *GRPCServer) findHospitalByID(hospitalID string) *HospitalConfig {
-	hospitalID = strings.ToUpper(hospitalID)
-	for i := range s.config.Hospitals {
-		if strings.ToUpper(s.config.Hospitals[i].HospitalID) == hospitalID {
-			return &s.config.Hospitals[i]
-		}
+	h, ok := s.hospitals.byID(hospitalID)
+	if !ok {
+		return nil
 	}
-	return nil
+	return &h
 }
 
-// findHospitalBySubdomain finds hospital config by subdomain
+// findHospitalBySubdomain finds hospital config by subdomain, which may be an alias
 func (s *GRPCServer) findHospitalBySubdomain(subdomain string) *HospitalConfig {
-	subdomain = strings.ToLower(subdomain)
-	for i := range s.config.Hospitals {
-		if strings.ToLower(s.config.Hospitals[i].Code) == subdomain {
-			return &s.config.Hospitals[i]
-		}
+	h, ok := s.hospitals.byCodeOrAlias(subdomain)
+	if !ok {
+		return nil
 	}
-	return nil
+	return &h
 }
 
 // startHTTPServer starts the HTTP server for viewer DICOM requests
@@ -312,244 +885,834 @@ func (s *GRPCServer) startHTTPServer(ctx context.Context) error {
 	// Support both /instances/ and /api/instances/ paths for compatibility
 	mux.HandleFunc("/instances/", s.handleInstanceDownload)
 	mux.HandleFunc("/api/instances/", s.handleInstanceDownload)
+	mux.HandleFunc("/series/", s.handleInstanceDownload)
+	mux.HandleFunc("/api/series/", s.handleInstanceDownload)
+	mux.HandleFunc("/studies/", s.handleInstanceDownload)
+	mux.HandleFunc("/api/studies/", s.handleInstanceDownload)
 	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/ready", readyHandler(s.startup, s.isRunning))
+	if s.config.Endpoints.EnableStatus {
+		mux.HandleFunc("/status", corsHandler(s.config.CORS, s.handleStatus))
+		mux.HandleFunc("/status/stream", corsHandler(s.config.CORS, statusStreamHandler(s.statusSnapshot, s.events, s.logger)))
+	}
+	if s.config.Endpoints.EnableMetrics {
+		mux.Handle("/metrics", promhttp.Handler())
+	}
+	if s.config.adminEnabled() {
+		mux.HandleFunc("/admin/tokens", adminTokenHandler(s.config, s.hospitals, s.logger))
+		mux.HandleFunc("/admin/disconnect", adminDisconnectHandler(s.config, s.disconnectHospital, s.logger))
+		mux.HandleFunc("/admin/maintenance", adminMaintenanceHandler(s.config, s.hospitals, s.maintenance, s.logger))
+		mux.HandleFunc("/admin/selftest", adminSelfTestHandler(s.config, s.hospitals, s.selfTest, s.logger))
+	}
 
 	httpAddr := ":8080" // HTTP on different port (Ingress handles TLS)
 	if s.config.MetricsAddr != "" {
 		httpAddr = s.config.MetricsAddr
 	}
 
+	// Viewer connections are kept alive between requests for up to IdleTimeout
 	s.httpServer = &http.Server{
-		Addr:    httpAddr,
-		Handler: mux,
+		Addr:        httpAddr,
+		Handler:     mux,
+		IdleTimeout: s.config.IdleTimeout.ToDuration(),
+	}
+
+	// Bind synchronously so address errors surface from Start, then serve in
+	// the background so Start returns and Stop can drain requests
+	lis, err := net.Listen("tcp", httpAddr)
+	if err != nil {
+		return fmt.Errorf("failed to listen on %s: %w", httpAddr, err)
 	}
 
 	s.logger.Info("Starting HTTP server for viewer requests", "addr", httpAddr)
-	return s.httpServer.ListenAndServe()
+	go func() {
+		if err := s.httpServer.Serve(lis); err != nil && err != http.ErrServerClosed {
+			s.logger.Error("HTTP server error", "error", err)
+		}
+	}()
+	return nil
 }
 
-// handleInstanceDownload handles DICOM instance download requests from viewers
+// handleInstanceDownload handles DICOM instance, series and study download requests from viewers
 func (s *GRPCServer) handleInstanceDownload(w http.ResponseWriter, r *http.Request) {
+	requestID, logger := startRequestLog(s.logger, w)
+	ctx, span := startRequestSpan(r, "relay.fetch")
+	r = r.WithContext(ctx)
+
+	rec := newResponseRecorder(w)
+	start := time.Now()
+	hospitalCode := ""
+	outcome := outcomeOK
+	defer func() {
+		logAccess(logger, r, hospitalCode, rec, start, outcome)
+		endRequestSpan(span, hospitalCode, rec.status, outcome)
+	}()
+
 	// Extract subdomain from Host header
 	host := r.Host
 	subdomain := s.extractSubdomain(host)
 	if subdomain == "" {
-		http.Error(w, "Invalid subdomain", http.StatusBadRequest)
+		outcome = "invalid_subdomain"
+		requestFailures.WithLabelValues(modeGRPC, "", outcome).Inc()
+		http.Error(rec, "Invalid subdomain", http.StatusBadRequest)
 		return
 	}
 
 	// Find hospital by subdomain
 	hospital := s.findHospitalBySubdomain(subdomain)
 	if hospital == nil {
-		s.logger.Warn("Unknown hospital subdomain", "subdomain", subdomain)
-		http.Error(w, "Unknown hospital", http.StatusNotFound)
+		logger.Warn("Unknown hospital subdomain", "subdomain", subdomain)
+		outcome = "unknown_hospital"
+		requestFailures.WithLabelValues(modeGRPC, "", outcome).Inc()
+		http.Error(rec, "Unknown hospital", http.StatusNotFound)
+		return
+	}
+	hospitalCode = hospital.Code
+
+	// Per-hospital method and path allowlists
+	if !hospital.allowsRequest(r.Method, r.URL.Path) {
+		logger.Warn("Request not allowed for hospital", "hospital_code", hospital.Code, "method", r.Method, "path", r.URL.Path)
+		outcome = "forbidden"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		http.Error(rec, "Forbidden", http.StatusForbidden)
+		return
+	}
+
+	// Hospitals in maintenance keep their stream but take no requests
+	if retryAfter, ok := s.maintenance.retryAfter(hospital.Code); ok {
+		outcome = "maintenance"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		writeMaintenance(rec, retryAfter)
+		return
+	}
+
+	// Refuse overload, shutdown and an open breaker before validating the
+	// token, so a single-use token is not burned by a 429 or 503
+	if !s.limiter.acquire() {
+		logger.Warn("Too many concurrent requests", "hospital_code", hospital.Code, "limit", s.config.MaxConcurrentConn)
+		outcome = "overloaded"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		rec.Header().Set("Retry-After", retryAfterSeconds)
+		http.Error(rec, "Too many concurrent requests", http.StatusTooManyRequests)
+		return
+	}
+	defer s.limiter.release()
+
+	if !s.beginRequest() {
+		outcome = "shutting_down"
+		http.Error(rec, "Server shutting down", http.StatusServiceUnavailable)
 		return
 	}
+	defer s.inflight.Done()
+
+	// Fail fast while the hospital's fetches keep failing
+	probe, retryAfter, allowed := s.breakers.allow(hospital.Code)
+	if !allowed {
+		outcome = "circuit_open"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		setCircuitRetryAfter(rec, retryAfter)
+		http.Error(rec, "Hospital temporarily unavailable", http.StatusServiceUnavailable)
+		return
+	}
+	// A probe turned away below never reached the edge; let the next request probe
+	forwarded := false
+	defer func() {
+		if !forwarded {
+			s.breakers.abandon(hospital.Code, probe)
+		}
+	}()
 
 	// Validate download token using hospital's API key
 	token := r.URL.Query().Get("token")
 	if token == "" {
-		s.logger.Warn("Missing token", "path", r.URL.Path, "subdomain", subdomain)
-		http.Error(w, "Missing token parameter", http.StatusUnauthorized)
+		logger.Warn("Missing token", "path", r.URL.Path, "subdomain", subdomain)
+		s.audit.tokenValidation(requestID, hospital, r.URL.Path, s.config.ClientIP(r), nil, errTokenMissing)
+		outcome = "missing_token"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		http.Error(rec, "Missing token parameter", http.StatusUnauthorized)
 		return
 	}
 
-	if err := timetoken.ValidateToken(hospital.Token, token, r.URL.Path); err != nil {
-		s.logger.Warn("Token validation failed",
+	// IP-bound tokens are checked against the real client behind any trusted proxy
+	clientIP := s.config.ClientIP(r)
+	tokenOpts := []timetoken.Option{
+		timetoken.WithClientIP(clientIP),
+		timetoken.WithSkewTolerance(s.config.TokenSkewTolerance.ToDuration()),
+	}
+	// A HEAD transfers nothing, so it must not use up a single-use token
+	// before the viewer's GET
+	if !s.config.DisableTokenReplayCheck && r.Method != http.MethodHead {
+		tokenOpts = append(tokenOpts, timetoken.WithReplayStore(s.replayStore))
+	}
+	payload, err := timetoken.ValidateTokenPayload(hospital.TokenKeys(), token, r.URL.Path, tokenOpts...)
+	s.audit.tokenValidation(requestID, hospital, r.URL.Path, clientIP, payload, err)
+	if err != nil {
+		logger.Warn("Token validation failed",
 			"error", err,
 			"path", r.URL.Path,
-			"subdomain", subdomain)
-		http.Error(w, "Invalid or expired token", http.StatusUnauthorized)
+			"subdomain", subdomain,
+			"remote_addr", clientIP)
+		if errors.Is(err, timetoken.ErrTokenIPMismatch) {
+			outcome = "ip_mismatch"
+			requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+			http.Error(rec, "Token not valid from this network", http.StatusForbidden)
+			return
+		}
+		outcome = "invalid_token"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		http.Error(rec, "Invalid or expired token", http.StatusUnauthorized)
 		return
 	}
 
-	s.logger.Debug("Token validated successfully", "path", r.URL.Path, "subdomain", subdomain)
+	logger.Debug("Token validated successfully", "path", r.URL.Path, "subdomain", subdomain)
 
-	// Extract instance UID from path
-	instanceUID := s.extractInstanceUID(r.URL.Path)
-	if instanceUID == "" {
-		http.Error(w, "Invalid instance path", http.StatusBadRequest)
+	// Work out what is being fetched from the path
+	target, ok := parseFetchPath(r.URL.Path)
+	if !ok {
+		outcome = "invalid_path"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		http.Error(rec, "Invalid instance path", http.StatusBadRequest)
 		return
 	}
+	// The edge gets the viewer's options but never the download token
+	target.Query = stripQueryParam(r.URL.RawQuery, "token")
+
+	// A single instance is streamed as-is; series and studies are wrapped
+	// in a multipart/related body or a zip archive
+	contentType := "application/dicom"
+	disposition := fmt.Sprintf("attachment; filename=%s.dcm", target.UID())
+	newWriter := func(w io.Writer) instanceWriter { return rawInstanceWriter{w} }
+
+	// Single instances can be resumed with a Range request. Without ETags an
+	// If-Range cannot be checked, so such requests get the whole file.
+	var ranged *rangeInstanceWriter
+	if target.Level == fetchLevelInstance {
+		rec.Header().Set("Accept-Ranges", "bytes")
+		if rng, ok := parseRange(r.Header.Get("Range")); ok && r.Method == http.MethodGet && r.Header.Get("If-Range") == "" {
+			target.Range = &rng
+			ranged = newRangeInstanceWriter(nil, rng)
+			newWriter = func(w io.Writer) instanceWriter {
+				ranged.w = w
+				return ranged
+			}
+		}
+	}
+	if target.Level != fetchLevelInstance {
+		if wantsZip(r) {
+			contentType = "application/zip"
+			disposition = fmt.Sprintf("attachment; filename=%s.zip", target.UID())
+			newWriter = func(w io.Writer) instanceWriter { return newZipInstanceWriter(w) }
+		} else {
+			boundary := multipart.NewWriter(io.Discard).Boundary()
+			contentType = fmt.Sprintf(`multipart/related; type="application/dicom"; boundary=%s`, boundary)
+			disposition = ""
+			newWriter = func(w io.Writer) instanceWriter {
+				mw, _ := newMultipartInstanceWriter(w, boundary) // boundary came from multipart.Writer, always valid
+				return mw
+			}
+		}
+	}
+
+	// Fetch from edge via gRPC
+	requestsForwarded.WithLabelValues(modeGRPC, hospital.Code).Inc()
+	defer func() {
+		elapsed := time.Since(start)
+		forwardDuration.WithLabelValues(modeGRPC, hospital.Code).Observe(elapsed.Seconds())
+		logSlowRequest(logger, modeGRPC, hospital.Code, r.URL.Path, elapsed, s.config.SlowRequestThreshold.ToDuration())
+	}()
+
+	// Let the edge audit log the real client
+	forwardedFor, realIP := s.config.forwardedFor(r)
+	metadata := map[string]string{
+		"x-forwarded-for": forwardedFor,
+		"x-real-ip":       realIP,
+	}
 
-	// Fetch instance from edge via gRPC
-	reader, err := s.fetchInstanceFromEdge(r.Context(), hospital.HospitalID, instanceUID)
+	forwarded = true
+	limits := s.config.limitsFor(hospital)
+
+	// HEAD asks the edge for metadata only. Content-Length is only known for
+	// single instances; archives and multipart bodies are built on the fly.
+	if r.Method == http.MethodHead {
+		instances, size, edge, err := s.headFromEdge(r.Context(), requestID, logger, hospital.HospitalID, target, metadata, limits.FetchTimeout)
+		edge.recordResult(err)
+		s.breakers.record(hospital.Code, probe, breakerFailure(r.Context(), err))
+		if err != nil {
+			logger.Error("Failed to fetch instance metadata",
+				"hospital_code", hospital.Code,
+				"hospital_id", hospital.HospitalID,
+				"level", target.Level,
+				"uid", target.UID(),
+				"error", err)
+			outcome = "fetch_error"
+			if errors.Is(err, errEdgeBusy) {
+				outcome = "edge_busy"
+			}
+			requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+			http.Error(rec, fmt.Sprintf("Failed to fetch instance: %v", err), http.StatusServiceUnavailable)
+			return
+		}
+		rec.Header().Set("Content-Type", contentType)
+		if disposition != "" {
+			rec.Header().Set("Content-Disposition", disposition)
+		}
+		if target.Level == fetchLevelInstance && instances == 1 {
+			rec.Header().Set("Content-Length", strconv.FormatInt(size, 10))
+		}
+		rec.WriteHeader(http.StatusOK)
+		return
+	}
+
+	reader, edge, err := s.fetchFromEdge(r.Context(), requestID, logger, hospital.HospitalID, target, metadata, limits.FetchTimeout, limits.MaxResponseBodyBytes, newWriter)
+	if err == nil && ranged != nil {
+		// The range headers need the file size, which comes with the first data
+		reader, err = peekRange(reader)
+	}
+	var unsatisfiable *rangeNotSatisfiableError
+	if errors.As(err, &unsatisfiable) {
+		edge.recordResult(nil)
+		s.breakers.record(hospital.Code, probe, false)
+		outcome = "range_not_satisfiable"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		rec.Header().Set("Content-Range", fmt.Sprintf("bytes */%d", unsatisfiable.size))
+		http.Error(rec, "Requested range not satisfiable", http.StatusRequestedRangeNotSatisfiable)
+		return
+	}
 	if err != nil {
-		s.logger.Error("Failed to fetch instance",
+		logger.Error("Failed to fetch instance",
+			"hospital_code", hospital.Code,
 			"hospital_id", hospital.HospitalID,
-			"instance_uid", instanceUID,
+			"level", target.Level,
+			"uid", target.UID(),
 			"error", err)
-		http.Error(w, fmt.Sprintf("Failed to fetch instance: %v", err), http.StatusServiceUnavailable)
+		outcome = "fetch_error"
+		if errors.Is(err, errEdgeBusy) {
+			outcome = "edge_busy"
+		}
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		edge.recordResult(err)
+		s.breakers.record(hospital.Code, probe, breakerFailure(r.Context(), err))
+		http.Error(rec, fmt.Sprintf("Failed to fetch instance: %v", err), http.StatusServiceUnavailable)
 		return
 	}
 
 	// Stream to viewer
-	w.Header().Set("Content-Type", "application/dicom")
-	w.Header().Set("Content-Disposition", fmt.Sprintf("attachment; filename=%s.dcm", instanceUID))
-	io.Copy(w, reader)
-}
-
-// fetchInstanceFromEdge requests a DICOM instance from edge via gRPC
-func (s *GRPCServer) fetchInstanceFromEdge(ctx context.Context, hospitalID, instanceUID string) (io.Reader, error) {
-	// Get edge connection
-	s.edgesMu.RLock()
-	edge, exists := s.edges[hospitalID]
-	s.edgesMu.RUnlock()
-
-	if !exists {
-		return nil, fmt.Errorf("edge not connected: %s", hospitalID)
+	rec.Header().Set("Content-Type", contentType)
+	if disposition != "" {
+		rec.Header().Set("Content-Disposition", disposition)
 	}
-
-	// Create request
-	requestID := fmt.Sprintf("%d", time.Now().UnixNano())
-	req := &PendingRequest{
-		RequestID:    requestID,
-		StartTime:    time.Now(),
-		ResponseChan: make(chan *grpc.DataResponse, 10),
-		ErrorChan:    make(chan error, 1),
+	if ranged != nil {
+		ranged.setRangeHeaders(rec)
 	}
-
-	edge.pendingMu.Lock()
-	edge.pendingRequests[requestID] = req
-	edge.pendingMu.Unlock()
-
-	// Send fetch command
-	err := edge.Stream.Send(&grpc.RelayMessage{
-		Message: &grpc.RelayMessage_Command{
-			Command: &grpc.FetchCommand{
-				RequestId:   requestID,
-				Type:        "instance",
-				InstanceUid: instanceUID,
-			},
-		},
+	// Bytes are counted as they go out so long transfers show progress
+	transferred := bytesTransferred.WithLabelValues(modeGRPC, hospital.Code)
+	_, streamSpan := tracer.Start(ctx, "stream body")
+	out := throttle(ctx, rec, s.bandwidth.get(hospital.Code, hospital.MaxBytesPerSec))
+	n, err := copyFlushing(out, reader, s.config.CopyBufferSize, func(n int) {
+		transferred.Add(float64(n))
 	})
+	streamSpan.SetAttributes(semconv.HTTPResponseBodySize(int(n)))
+	endSpan(streamSpan, err)
+	edge.recordResult(err)
+	s.breakers.record(hospital.Code, probe, breakerFailure(r.Context(), err))
+	if errors.Is(err, errResponseTooLarge) {
+		logger.Warn("Response body too large, aborting", "hospital_code", hospital.Code, "path", r.URL.Path, "limit", limits.MaxResponseBodyBytes)
+		outcome = "response_too_large"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		abortResponse()
+	}
+	if err != nil && rec.wroteHeader && r.Context().Err() == nil {
+		// The viewer already has a status line, so only a connection that
+		// ends before the body is complete can tell it the response failed
+		logger.Error("Response truncated", "error", err, "hospital_code", hospital.Code, "path", r.URL.Path, "bytes", n)
+		outcome = "truncated"
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		abortResponse()
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to send fetch command: %w", err)
+		if errors.Is(err, errFetchTimeout) {
+			outcome = "timeout"
+		} else {
+			outcome = "stream_error"
+		}
+		requestFailures.WithLabelValues(modeGRPC, hospital.Code, outcome).Inc()
+		// Nothing written yet, so the status line can still report the failure
+		if !rec.wroteHeader {
+			rec.Header().Del("Content-Disposition")
+			status := http.StatusBadGateway
+			if errors.Is(err, errFetchTimeout) {
+				status = http.StatusGatewayTimeout
+			}
+			http.Error(rec, fmt.Sprintf("Failed to fetch instance: %v", err), status)
+		}
 	}
+}
 
-	s.logger.Info("Sent fetch command to edge",
-		"hospital_id", hospitalID,
-		"instance_uid", instanceUID,
-		"request_id", requestID)
+// fetchFromEdge requests an instance, series or study from edge via gRPC.
+// metadata is sent with the command alongside the trace context, and the
+// fetch fails if the edge is silent for fetchTimeout, or is cancelled once the
+// edge sent more than maxBytes of data (0 is unlimited).
+// The returned reader yields the instances as written by newWriter; see
+// sendFetch for which edge is asked and returned.
+func (s *GRPCServer) fetchFromEdge(ctx context.Context, requestID string, logger *slog.Logger, hospitalID string, target fetchTarget, metadata map[string]string, fetchTimeout time.Duration, maxBytes int64, newWriter func(io.Writer) instanceWriter) (io.Reader, *EdgeConnection, error) {
+	cmd := newFetchCommand(ctx, requestID, target, metadata)
+	req, edge, err := s.sendFetch(ctx, logger, hospitalID, cmd)
+	if err != nil {
+		return nil, edge, err
+	}
 
 	// Create pipe for streaming response
 	pr, pw := io.Pipe()
 
-	// Goroutine to assemble response and write to pipe
+	// Goroutine to assemble response and write to pipe.
+	// Chunks are written as soon as they are contiguous; only chunks that
+	// arrive ahead of the next expected index are buffered. Multi-instance
+	// responses are sent one instance after another, distinguished by sequence.
 	go func() {
 		defer pw.Close()
 
-		chunks := make(map[int32][]byte) // For chunked files
-		maxChunkIndex := int32(-1)
+		// If the viewer goes away first, tell the edge to stop sending
+		// (on whichever connection serves it after a reconnect)
+		defer func() {
+			if err := req.edge.Load().cancelRequest(requestID, "client disconnected"); err != nil {
+				logger.Debug("Failed to send cancel to edge", "error", err)
+			}
+		}()
+
+		iw := newWriter(pw)
+
+		// "await response" covers the time until the edge sends anything
+		_, awaitSpan := tracer.Start(ctx, "await response")
+		awaiting := true
+		endAwait := func(err error) {
+			if awaiting {
+				awaiting = false
+				endSpan(awaitSpan, err)
+			}
+		}
+		defer endAwait(nil)
+
+		started := false
+		received := int64(0)
+		sequence := int32(0)
+		nextIndex := int32(0)
+		lastIndex := int32(-1)
+		outOfOrder := make(map[int32][]byte)
+
+		// checkComplete verifies every chunk of the current instance was written
+		checkComplete := func() error {
+			if lastIndex >= 0 && nextIndex <= lastIndex {
+				return fmt.Errorf("incomplete transfer: missing chunk %d of %d", nextIndex, lastIndex+1)
+			}
+			if len(outOfOrder) > 0 {
+				return fmt.Errorf("incomplete transfer: missing chunk %d", nextIndex)
+			}
+			return nil
+		}
+
+		// beginInstance switches to the instance with the given sequence number
+		beginInstance := func(seq int32, instanceUID string) error {
+			if started {
+				if seq == sequence {
+					return nil
+				}
+				if err := checkComplete(); err != nil {
+					return err
+				}
+			}
+			started = true
+			sequence = seq
+			nextIndex = 0
+			lastIndex = -1
+			clear(outOfOrder)
+			return iw.BeginInstance(instanceUID)
+		}
+
+		// Fail the request if the edge goes silent for longer than fetchTimeout
+		idle := time.NewTimer(fetchTimeout)
+		defer idle.Stop()
 
 		for {
 			select {
 			case <-ctx.Done():
 				pw.CloseWithError(ctx.Err())
 				return
+			case <-idle.C:
+				logger.Warn("Edge did not respond in time",
+					"hospital_id", hospitalID,
+					"timeout", fetchTimeout.String())
+				if err := req.edge.Load().cancelRequest(requestID, "fetch timeout"); err != nil {
+					logger.Debug("Failed to send cancel to edge", "error", err)
+				}
+				err := fmt.Errorf("%w: edge did not respond within %s", errFetchTimeout, fetchTimeout)
+				endAwait(err)
+				pw.CloseWithError(err)
+				return
 			case err := <-req.ErrorChan:
-				s.logger.Error("Fetch error from edge", "error", err)
+				endAwait(err)
+				logger.Error("Fetch error from edge", "error", err)
 				pw.CloseWithError(err)
 				return
 			case data, ok := <-req.ResponseChan:
+				endAwait(nil)
 				if !ok {
-					// Channel closed = transfer complete
-					if maxChunkIndex >= 0 {
-						// Write assembled chunks in order
-						for i := int32(0); i <= maxChunkIndex; i++ {
-							if chunkData, exists := chunks[i]; exists {
-								pw.Write(chunkData)
-							}
-						}
+					// Channel closed = transfer complete; every chunk up to the last must have been written
+					if err := checkComplete(); err != nil {
+						pw.CloseWithError(err)
+						return
+					}
+					if err := iw.Close(); err != nil {
+						pw.CloseWithError(err)
 					}
 					return
 				}
 
+				if !idle.Stop() {
+					<-idle.C
+				}
+				idle.Reset(fetchTimeout)
+
 				// Handle start metadata
 				if start := data.GetStart(); start != nil {
-					s.logger.Debug("Received data start",
+					logger.Debug("Received data start",
 						"instance_uid", start.InstanceUid,
+						"sequence", start.Sequence,
 						"file_size", start.FileSize,
 						"chunked", start.Chunked)
+					if err := beginInstance(start.Sequence, start.InstanceUid); err != nil {
+						pw.CloseWithError(err)
+						return
+					}
+					if rw, ok := iw.(*rangeInstanceWriter); ok {
+						if err := rw.start(start.FileSize, start.Offset); err != nil {
+							pw.CloseWithError(err)
+							return
+						}
+					}
 					if start.Chunked {
-						maxChunkIndex = start.ChunkCount - 1
+						lastIndex = start.ChunkCount - 1
 					}
 					continue
 				}
 
 				// Handle data chunk
-				if chunk := data.GetChunk(); chunk != nil {
-					if chunk.ChunkIndex == 0 && chunk.IsLastChunk {
-						// Whole file in one message
-						pw.Write(chunk.Data)
-					} else {
-						// Store chunk for reassembly
-						chunks[chunk.ChunkIndex] = chunk.Data
+				chunk := data.GetChunk()
+				if chunk == nil {
+					continue
+				}
+				if err := beginInstance(chunk.Sequence, chunk.InstanceUid); err != nil {
+					pw.CloseWithError(err)
+					return
+				}
+				if chunk.IsLastChunk {
+					lastIndex = chunk.ChunkIndex
+				}
+				received += int64(len(chunk.Data))
+				if maxBytes > 0 && received > maxBytes {
+					if err := req.edge.Load().cancelRequest(requestID, "response too large"); err != nil {
+						logger.Debug("Failed to send cancel to edge", "error", err)
 					}
+					pw.CloseWithError(fmt.Errorf("%w: over %d bytes", errResponseTooLarge, maxBytes))
+					return
+				}
+
+				switch {
+				case chunk.ChunkIndex < nextIndex:
+					logger.Warn("Duplicate chunk ignored", "chunk_index", chunk.ChunkIndex)
+					continue
+				case chunk.ChunkIndex > nextIndex:
+					outOfOrder[chunk.ChunkIndex] = chunk.Data
+					continue
+				}
+
+				// Write this chunk and any buffered chunks that are now contiguous
+				buf := chunk.Data
+				for {
+					if _, err := iw.Write(buf); err != nil {
+						if errors.Is(err, errRangeComplete) {
+							// The rest of the file is not needed
+							if err := req.edge.Load().cancelRequest(requestID, "range complete"); err != nil {
+								logger.Debug("Failed to send cancel to edge", "error", err)
+							}
+						}
+						return // range done or reader gone
+					}
+					nextIndex++
+					next, buffered := outOfOrder[nextIndex]
+					if !buffered {
+						break
+					}
+					delete(outOfOrder, nextIndex)
+					buf = next
 				}
 			}
 		}
 	}()
 
-	return pr, nil
+	return pr, edge, nil
 }
 
-// extractSubdomain extracts subdomain from Host header
-func (s *GRPCServer) extractSubdomain(host string) string {
-	// Remove port if present
-	if idx := strings.Index(host, ":"); idx != -1 {
-		host = host[:idx]
+// headFromEdge asks the edge whether target exists without transferring it.
+// It returns the number of instances and their total size in bytes.
+func (s *GRPCServer) headFromEdge(ctx context.Context, requestID string, logger *slog.Logger, hospitalID string, target fetchTarget, metadata map[string]string, fetchTimeout time.Duration) (instances int, size int64, edge *EdgeConnection, err error) {
+	cmd := newFetchCommand(ctx, requestID, target, metadata)
+	cmd.MetadataOnly = true
+	req, edge, err := s.sendFetch(ctx, logger, hospitalID, cmd)
+	if err != nil {
+		return 0, 0, edge, err
+	}
+
+	_, awaitSpan := tracer.Start(ctx, "await response")
+	defer func() { endSpan(awaitSpan, err) }()
+
+	idle := time.NewTimer(fetchTimeout)
+	defer idle.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			_ = req.edge.Load().cancelRequest(requestID, "client disconnected")
+			return 0, 0, edge, ctx.Err()
+		case <-idle.C:
+			_ = req.edge.Load().cancelRequest(requestID, "fetch timeout")
+			return 0, 0, edge, fmt.Errorf("%w: edge did not respond within %s", errFetchTimeout, fetchTimeout)
+		case err := <-req.ErrorChan:
+			return 0, 0, edge, err
+		case data, ok := <-req.ResponseChan:
+			if !ok {
+				return instances, size, edge, nil
+			}
+			if !idle.Stop() {
+				<-idle.C
+			}
+			idle.Reset(fetchTimeout)
+			// Only the metadata counts; chunks from edges that ignore
+			// metadata_only are dropped
+			if start := data.GetStart(); start != nil {
+				instances++
+				size += start.FileSize
+			}
+		}
 	}
+}
 
-	// Extract subdomain: demo-samsun.zenpacs.com.tr → demo-samsun
-	domainSuffix := "." + s.config.Domain
-	if strings.HasSuffix(host, domainSuffix) {
-		subdomain := strings.TrimSuffix(host, domainSuffix)
-		return subdomain
+// newFetchCommand builds the command for target, carrying the trace context
+// so the edge can continue it
+func newFetchCommand(ctx context.Context, requestID string, target fetchTarget, metadata map[string]string) *grpc.FetchCommand {
+	cmd := &grpc.FetchCommand{
+		RequestId:   requestID,
+		Type:        target.Level,
+		StudyUid:    target.StudyUID,
+		SeriesUid:   target.SeriesUID,
+		InstanceUid: target.InstanceUID,
+		SubPath:     target.SubPath,
+		Query:       target.Query,
+		Metadata:    maps.Clone(metadata),
+	}
+	if target.Range != nil {
+		cmd.ByteRange = target.Range.proto()
 	}
+	if cmd.Metadata == nil {
+		cmd.Metadata = make(map[string]string)
+	}
+	injectTraceContext(ctx, propagation.MapCarrier(cmd.Metadata))
+	return cmd
+}
 
-	return ""
+// sendFetch sends cmd to one of the hospital's edges. With redundant edges,
+// the least busy one is asked first and the next one is tried if the command
+// cannot be sent. The edge asked last is returned for its /status counters,
+// nil if none is connected.
+func (s *GRPCServer) sendFetch(ctx context.Context, logger *slog.Logger, hospitalID string, cmd *grpc.FetchCommand) (*PendingRequest, *EdgeConnection, error) {
+	var (
+		tried   []*EdgeConnection
+		sendErr error
+	)
+	for {
+		edge := s.pickEdge(hospitalID, tried)
+		if edge == nil {
+			if sendErr != nil {
+				return nil, tried[len(tried)-1], sendErr
+			}
+			return nil, nil, fmt.Errorf("edge not connected: %s", hospitalID)
+		}
+		tried = append(tried, edge)
+
+		req, err := edge.startFetch(ctx, cmd)
+		if err == nil {
+			logger.Info("Sent fetch command to edge",
+				"hospital_id", hospitalID,
+				"edge_server_id", edge.EdgeServerID,
+				"level", cmd.Type,
+				"uid", cmp.Or(cmd.InstanceUid, cmd.SeriesUid, cmd.StudyUid),
+				"metadata_only", cmd.MetadataOnly)
+			return req, edge, nil
+		}
+		sendErr = err
+		logger.Warn("Edge did not accept fetch command",
+			"hospital_id", hospitalID,
+			"edge_server_id", edge.EdgeServerID,
+			"error", err)
+	}
 }
 
-// extractInstanceUID extracts instance UID from various path formats
-func (s *GRPCServer) extractInstanceUID(path string) string {
-	// Supported formats:
-	// - /instances/{uid}/download or /instances/{uid}
-	// - /api/instances/{uid}/download or /api/instances/{uid}
-	parts := strings.Split(strings.Trim(path, "/"), "/")
+// startFetch registers cmd as pending on the edge and sends it
+func (ec *EdgeConnection) startFetch(ctx context.Context, cmd *grpc.FetchCommand) (*PendingRequest, error) {
+	// The relay request ID doubles as the edge request ID
+	req := &PendingRequest{
+		RequestID:    cmd.RequestId,
+		StartTime:    time.Now(),
+		ResponseChan: make(chan *grpc.DataResponse, 10),
+		ErrorChan:    make(chan error, 1),
+		done:         make(chan struct{}),
+		cmd:          cmd,
+	}
+	req.edge.Store(ec)
+	req.lastActive.Store(req.StartTime.UnixNano())
 
-	// /api/instances/{uid}/download
-	if len(parts) >= 3 && parts[0] == "api" && parts[1] == "instances" {
-		return parts[2]
+	ec.pendingMu.Lock()
+	if len(ec.pendingRequests) >= ec.maxPending {
+		ec.pendingMu.Unlock()
+		return nil, fmt.Errorf("%w: %d", errEdgeBusy, ec.maxPending)
 	}
+	ec.pendingRequests[cmd.RequestId] = req
+	ec.pendingGauge.Inc()
+	ec.pendingMu.Unlock()
 
-	// /instances/{uid}/download
-	if len(parts) >= 2 && parts[0] == "instances" {
-		return parts[1]
+	_, writeSpan := tracer.Start(ctx, "write request", trace.WithAttributes(attrRequestSize.Int(proto.Size(cmd))))
+	err := ec.send(&grpc.RelayMessage{
+		Message: &grpc.RelayMessage_Command{Command: cmd},
+	})
+	endSpan(writeSpan, err)
+	if err != nil {
+		req.edge.Load().removePending(cmd.RequestId)
+		return nil, fmt.Errorf("failed to send fetch command: %w", err)
 	}
+	return req, nil
+}
 
-	return ""
+// extractSubdomain extracts the hospital code from the Host header
+// (demo-samsun.zenpacs.com.tr → demo-samsun)
+func (s *GRPCServer) extractSubdomain(host string) string {
+	return hospitalCodeFromHost(host, s.config.ApexDomains(), s.config.subdomainRe)
 }
 
 // handleHealth handles health check requests
 func (s *GRPCServer) handleHealth(w http.ResponseWriter, r *http.Request) {
 	s.edgesMu.RLock()
-	edgeCount := len(s.edges)
+	edgeCount := 0
+	for _, pool := range s.edges {
+		edgeCount += len(pool)
+	}
 	s.edgesMu.RUnlock()
 
-	w.Header().Set("Content-Type", "application/json")
-	fmt.Fprintf(w, `{"status":"ok","connected_edges":%d}`, edgeCount)
+	_ = writeJSON(w, http.StatusOK, healthResponse{Status: "ok", Version: Version, ConnectedEdges: &edgeCount})
+}
+
+// statusSnapshot collects the /status document
+func (s *GRPCServer) statusSnapshot() StatusResponse {
+	s.edgesMu.RLock()
+	status := StatusResponse{
+		Version:            Version,
+		ConnectedHospitals: len(s.edges),
+		MaxHospitals:       s.config.MaxHospitals,
+		InFlightRequests:   s.limiter.current(),
+		MaxConcurrent:      s.config.MaxConcurrentConn,
+		Hospitals:          make([]HospitalStatus, 0, len(s.edges)),
+	}
+	// One entry per edge, so a hospital with redundant edges is listed once per edge
+	for hospitalID, pool := range s.edges {
+		hospital := s.findHospitalByID(hospitalID)
+		for _, edge := range pool {
+			hs := HospitalStatus{
+				Code:         hospitalID,
+				HospitalID:   hospitalID,
+				EdgeServerID: edge.EdgeServerID,
+			}
+			fillConnection(&hs, edge.Connected, s.reconnects.reconnects(edge.reconnectKey()))
+			if hospital != nil {
+				hs.Code = hospital.Code
+				hs.Subdomain = hospital.Subdomain
+			}
+			edge.mu.RLock()
+			hs.LastSeen = edge.LastSeen
+			edge.mu.RUnlock()
+			hs.PendingRequests = edge.pendingCount()
+			edge.stats.fill(&hs)
+			hs.CircuitBreaker = s.breakers.state(hs.Code)
+			status.Hospitals = append(status.Hospitals, hs)
+		}
+	}
+	s.edgesMu.RUnlock()
+	status.Total = len(status.Hospitals)
+	return status
+}
+
+// handleStatus lists connected edges with their request counters
+func (s *GRPCServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	query, err := parseStatusQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := s.statusSnapshot()
+	query.apply(&status, s.hospitals)
+
+	if err := writeJSON(w, http.StatusOK, status); err != nil {
+		s.logger.Debug("Failed to write status response", "error", err)
+	}
+}
+
+// recordResult updates the edge's /status counters; ec may be nil
+func (ec *EdgeConnection) recordResult(err error) {
+	if ec != nil {
+		ec.stats.record(err)
+	}
+}
+
+// beginRequest registers an in-flight request unless the server is stopping.
+// Callers must call s.inflight.Done() when it returns true.
+func (s *GRPCServer) beginRequest() bool {
+	s.runMutex.RLock()
+	defer s.runMutex.RUnlock()
+	if !s.running {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// isRunning reports whether the server accepts new work
+func (s *GRPCServer) isRunning() bool {
+	s.runMutex.RLock()
+	defer s.runMutex.RUnlock()
+	return s.running
 }
 
 // Stop gracefully shuts down the server
 func (s *GRPCServer) Stop() {
+	s.runMutex.Lock()
+	s.running = false
+	s.runMutex.Unlock()
+
 	s.logger.Info("Stopping gRPC relay server")
+
+	// Drain: new registrations and downloads are refused, let in-flight downloads finish
+	grace := s.config.ShutdownGracePeriod.ToDuration()
+	s.logger.Info("Waiting for in-flight requests to finish", "grace_period", grace.String())
+	if !waitTimeout(&s.inflight, grace) {
+		s.logger.Warn("Grace period expired, aborting remaining requests", "grace_period", grace.String())
+	}
+
+	// Edge streams never finish on their own, so stop (not GracefulStop) after draining
 	if s.grpcServer != nil {
-		s.grpcServer.GracefulStop()
+		s.grpcServer.Stop()
 	}
 	if s.httpServer != nil {
 		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
 		defer cancel()
 		s.httpServer.Shutdown(ctx)
 	}
+	if err := s.audit.Close(); err != nil {
+		s.logger.Warn("Failed to close audit log", "error", err)
+	}
 }
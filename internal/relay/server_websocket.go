@@ -5,16 +5,25 @@ import (
 	"bytes"
 	"context"
 	"crypto/tls"
+	"encoding/binary"
+	"errors"
 	"fmt"
 	"io"
 	"log/slog"
 	"net"
 	"net/http"
+	"strconv"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"go.opentelemetry.io/otel/propagation"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/crypto/acme"
 	"golang.org/x/crypto/acme/autocert"
 )
 
@@ -27,14 +36,15 @@ type WebSocketServer struct {
 	// Hospital agent management
 	agents      map[string]*WSAgentConnection // hospitalCode -> connection
 	agentsMutex sync.RWMutex
+	hospitals   *hospitalRegistry
 
 	// TLS certificate management
 	tlsConfig   *tls.Config
 	acmeManager *autocert.Manager
+	certs       *certReloader // file-based certificates; nil with autocert
 
 	// Rate limiting for authentication
-	failedAttempts map[string]*authAttempts
-	attemptsMutex  sync.RWMutex
+	attempts AttemptStore
 
 	// WebSocket upgrader
 	upgrader websocket.Upgrader
@@ -42,41 +52,116 @@ type WebSocketServer struct {
 	// Graceful shutdown
 	running  bool
 	runMutex sync.RWMutex
-}
+	inflight sync.WaitGroup // forwarded requests in progress
+
+	// Caps concurrently forwarded requests (MaxConcurrentConn)
+	limiter *concurrencyLimiter
+
+	// Per-hospital response bandwidth
+	bandwidth *bandwidthLimiters
+
+	// Hospitals answered with 503 while under maintenance
+	maintenance *maintenanceSet
+
+	// Hospitals answered with 503 while their requests keep failing (nil when disabled)
+	breakers *circuitBreakers
+
+	// Landing and error pages shown to viewers
+	pages *pageTemplates
 
-// authAttempts tracks failed authentication attempts for rate limiting
-type authAttempts struct {
-	Count        int
-	LastAttempt  time.Time
-	BlockedUntil time.Time
+	// Connect/disconnect notifications
+	events *eventHub
+
+	// Registrations per hospital, kept across connections for /status
+	reconnects *reconnectCounter
+
+	// Holds /ready until the startup hospitals have registered
+	startup *startupGate
+
+	// Tunnel connections that have not registered yet (MaxPendingRegistrations)
+	registering *registrationSlots
 }
 
+// Tunnel protocol versions negotiated in the REGISTER message.
+//
+// Version 1 carries one request at a time: the relay writes a raw HTTP request
+// as a single binary message and the agent replies with a header message, body
+// chunks and an empty terminator.
+//
+// Version 2 multiplexes requests: every binary message in either direction is
+// prefixed with an 8-byte big-endian request ID, and the agent must echo the ID
+// of the request it is answering on every response frame (headers, chunks and
+// the empty terminator).
+const (
+	TunnelProtocolV1 = 1
+	TunnelProtocolV2 = 2
+
+	wsFrameHeaderSize = 8
+)
+
+// maxRegistrationSize bounds the REGISTER message read from a new tunnel
+const maxRegistrationSize = 4096
+
 // WSAgentConnection represents a WebSocket connection from a hospital agent
 type WSAgentConnection struct {
 	HospitalCode string
 	Subdomain    string
 	Conn         *websocket.Conn
+	Protocol     int
+	Connected    time.Time
 	LastSeen     time.Time
 	Mutex        sync.RWMutex
 
-	// message delivery and request synchronization
-	MsgCh    chan []byte
-	Done     chan struct{}
-	ReqMutex sync.Mutex
+	// message delivery and request queueing (protocol v1)
+	MsgCh chan []byte
+	Done  chan struct{}
+	queue *requestQueue
+
+	// per-request response streams (protocol v2)
+	streams   map[uint64]*wsStream
+	streamsMu sync.Mutex
+	nextID    atomic.Uint64
+
+	// gorilla/websocket supports one concurrent writer; once the agent is
+	// registered, every write goes through safeWrite
+	writeMu sync.Mutex
+
+	// signalled by the pong handler for the ping loop
+	pong chan struct{}
+
+	// per-hospital counters for /status
+	stats forwardStats
+}
+
+// wsStream receives the response frames for one multiplexed request
+type wsStream struct {
+	ch   chan []byte
+	done chan struct{}
+
+	// err is why ch was closed early; set by the read loop before closing
+	err error
 }
 
 // NewWebSocketServer creates a new WebSocket-based relay server
 func NewWebSocketServer(config *Config, logger *slog.Logger) *WebSocketServer {
 	return &WebSocketServer{
-		config:         config,
-		logger:         logger,
-		agents:         make(map[string]*WSAgentConnection),
-		failedAttempts: make(map[string]*authAttempts),
+		config:      config,
+		logger:      logger,
+		agents:      make(map[string]*WSAgentConnection),
+		hospitals:   newHospitalRegistry(config.Hospitals),
+		limiter:     newConcurrencyLimiter(config.MaxConcurrentConn),
+		bandwidth:   newBandwidthLimiters(),
+		maintenance: newMaintenanceSet(config.Maintenance),
+		breakers:    newCircuitBreakers(config.CircuitBreaker, logger),
+		events:      newEventHub(logger),
+		reconnects:  newReconnectCounter(),
+		startup:     newStartupGate(config.StartupWaitHospitals, config.StartupWaitMinHospitals, config.StartupWaitTimeout.ToDuration(), logger),
+		registering: newRegistrationSlots(config.MaxPendingRegistrations),
 		upgrader: websocket.Upgrader{
 			CheckOrigin: func(r *http.Request) bool {
-				return true // Allow all origins for tunnel connections
+				return checkTunnelOrigin(config.WebSocket.AllowedOrigins, r, logger)
 			},
-			EnableCompression: false,
+			EnableCompression: config.WebSocket.EnableCompression,
 		},
 	}
 }
@@ -91,40 +176,78 @@ func (s *WebSocketServer) Start(ctx context.Context) error {
 	if err := s.setupTLS(); err != nil {
 		return fmt.Errorf("failed to setup TLS: %w", err)
 	}
+	if s.certs != nil {
+		go s.certs.run(ctx, certReloadInterval)
+	}
+
+	// Failed-attempt tracking, shared across replicas with the Redis backend
+	attempts, err := newAttemptStore(s.config.RateLimit)
+	if err != nil {
+		return fmt.Errorf("failed to setup rate limiting: %w", err)
+	}
+	s.attempts = attempts
+
+	pages, err := loadPages(s.config.Pages)
+	if err != nil {
+		return fmt.Errorf("failed to load pages: %w", err)
+	}
+	s.pages = pages
+
+	// Subscribe to dynamic hospital registrations
+	if s.config.NATS != nil {
+		if err := startNATSDiscovery(ctx, s.config, s.hospitals, s.logger); err != nil {
+			return err
+		}
+	}
+
+	s.startup.begin()
 
 	// Create HTTPS server with WebSocket handler
 	mux := http.NewServeMux()
 	mux.HandleFunc("/tunnel", s.handleTunnelConnection)
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "OK")
-	})
-	mux.HandleFunc("/status", s.handleStatus)
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/ready", readyHandler(s.startup, s.isRunning))
+	if s.config.Endpoints.EnableStatus {
+		mux.HandleFunc("/status", corsHandler(s.config.CORS, s.handleStatus))
+		mux.HandleFunc("/status/stream", corsHandler(s.config.CORS, statusStreamHandler(s.statusSnapshot, s.events, s.logger)))
+	}
 	mux.HandleFunc("/", s.handleHTTPRequest)
 
+	// Viewer connections are kept alive between requests for up to
+	// IdleTimeout; tunnel connections are hijacked and not affected. A
+	// request's headers must arrive within RequestTimeout, so clients that
+	// stall mid-request are closed.
 	s.server = &http.Server{
-		Addr:      s.config.ListenAddr,
-		Handler:   mux,
-		TLSConfig: s.tlsConfig,
+		Addr:              s.config.ListenAddr,
+		Handler:           mux,
+		TLSConfig:         s.tlsConfig,
+		IdleTimeout:       s.config.IdleTimeout.ToDuration(),
+		ReadHeaderTimeout: s.config.RequestTimeout.ToDuration(),
 	}
 
 	// Start server (HTTPS or HTTP depending on TLS config)
 	go func() {
+		lis, err := net.Listen("tcp", s.config.ListenAddr)
+		if err != nil {
+			s.logger.Error("Failed to listen", "addr", s.config.ListenAddr, "error", err)
+			return
+		}
+		lis = newLimitListener(lis, s.config.MaxConnections, modeWebSocket)
 		if s.tlsConfig != nil {
 			s.logger.Info("HTTPS/WebSocket listener started", "addr", s.config.ListenAddr)
-			if err := s.server.ListenAndServeTLS("", ""); err != nil && err != http.ErrServerClosed {
+			if err := s.server.ServeTLS(lis, "", ""); err != nil && err != http.ErrServerClosed {
 				s.logger.Error("HTTPS server error", "error", err)
 			}
 		} else {
 			s.logger.Info("HTTP/WebSocket listener started (TLS handled by Ingress)", "addr", s.config.ListenAddr)
-			if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			if err := s.server.Serve(lis); err != nil && err != http.ErrServerClosed {
 				s.logger.Error("HTTP server error", "error", err)
 			}
 		}
 	}()
 
-	// Start HTTP redirect server on port 80 for ACME challenges (only if TLS enabled)
-	if s.config.TLS.Enabled {
+	// Start HTTP redirect server for ACME challenges (only if TLS enabled)
+	if s.config.TLS.Enabled && !s.config.TLS.DisableHTTPRedirect {
 		go s.startHTTPRedirectServer(ctx)
 	}
 
@@ -133,8 +256,17 @@ func (s *WebSocketServer) Start(ctx context.Context) error {
 		go s.startMetricsServer(ctx)
 	}
 
-	// Start cleanup routine for failed attempts
-	go s.cleanupFailedAttempts(ctx)
+	// Start cleanup routine for failed attempts (Redis keys expire on their own)
+	if store, ok := s.attempts.(*memoryAttemptStore); ok {
+		go store.cleanup(ctx)
+	}
+
+	// Start eviction of agents whose heartbeats stopped
+	go s.monitorHeartbeats(ctx)
+
+	if s.config.Webhooks != nil && s.config.Webhooks.ConnectURL != "" {
+		go runConnectWebhook(ctx, s.config.Webhooks, s.events, s.logger)
+	}
 
 	return nil
 }
@@ -147,13 +279,21 @@ func (s *WebSocketServer) Stop() {
 
 	s.logger.Info("Stopping relay server")
 
+	// Drain: new registrations and forwards are refused, let in-flight requests finish
+	grace := s.config.ShutdownGracePeriod.ToDuration()
+	s.logger.Info("Waiting for in-flight requests to finish", "grace_period", grace.String())
+	if !waitTimeout(&s.inflight, grace) {
+		s.logger.Warn("Grace period expired, aborting remaining requests", "grace_period", grace.String())
+	}
+
 	// Close all agent connections
 	s.agentsMutex.Lock()
 	for hospitalCode, agent := range s.agents {
-		s.logger.Info("Closing agent connection", "hospital", hospitalCode)
+		s.logger.Info("Closing agent connection", "hospital_code", hospitalCode)
 		agent.Conn.Close()
 	}
 	s.agents = make(map[string]*WSAgentConnection)
+	connectedHospitals.WithLabelValues(modeWebSocket).Set(0)
 	s.agentsMutex.Unlock()
 
 	// Shutdown HTTPS server
@@ -163,6 +303,10 @@ func (s *WebSocketServer) Stop() {
 		s.server.Shutdown(ctx)
 	}
 
+	if closer, ok := s.attempts.(io.Closer); ok {
+		closer.Close()
+	}
+
 	s.logger.Info("Relay server stopped")
 }
 
@@ -181,22 +325,35 @@ func (s *WebSocketServer) setupTLS() error {
 		}
 
 		m := &autocert.Manager{
-			Cache:  autocert.DirCache("certs"),
+			Cache:  autocert.DirCache(s.config.TLS.CacheDir),
 			Prompt: autocert.AcceptTOS,
 			Email:  s.config.TLS.ACMEEmail,
+			Client: &acme.Client{DirectoryURL: s.config.TLS.ACMEDirectoryURL},
 			HostPolicy: func(ctx context.Context, host string) error {
-				// Allow apex domain and any subdomain
-				if host == s.config.Domain || strings.HasSuffix(host, "."+s.config.Domain) {
+				// Allow every configured apex domain and any subdomain
+				if matchApexDomain(strings.ToLower(host), s.config.ApexDomains()) != "" {
 					return nil
 				}
 				return fmt.Errorf("acme: unauthorized host %q", host)
 			},
 		}
 
+		// Offering acme.ALPNProto lets TLS-ALPN-01 challenges complete on
+		// listen_addr when the plain HTTP server is disabled or unreachable
 		s.acmeManager = m
+		getCertificate := m.GetCertificate
+		if s.config.TLS.hasACMEFallback() {
+			fallback, err := newACMEFallback(m, &s.config.TLS, s.config.ApexDomains(), s.logger)
+			if err != nil {
+				return err
+			}
+			getCertificate = fallback.getCertificate
+		}
 		s.tlsConfig = &tls.Config{
-			GetCertificate: m.GetCertificate,
-			MinVersion:     tls.VersionTLS12,
+			GetCertificate: getCertificate,
+			NextProtos:     []string{"h2", "http/1.1", acme.ALPNProto},
+			MinVersion:     s.config.TLS.minVersion(),
+			CipherSuites:   s.config.TLS.cipherSuites(),
 		}
 	} else {
 		// Use provided certificate files
@@ -204,21 +361,23 @@ func (s *WebSocketServer) setupTLS() error {
 			return fmt.Errorf("cert_file and key_file are required when TLS is enabled but auto_cert is false")
 		}
 
-		cert, err := tls.LoadX509KeyPair(s.config.TLS.CertFile, s.config.TLS.KeyFile)
+		certs, err := newCertReloader(s.config.TLS.CertFile, s.config.TLS.KeyFile, s.logger)
 		if err != nil {
-			return fmt.Errorf("failed to load TLS certificate: %w", err)
+			return err
 		}
 
+		s.certs = certs
 		s.tlsConfig = &tls.Config{
-			Certificates: []tls.Certificate{cert},
-			MinVersion:   tls.VersionTLS12,
+			GetCertificate: certs.getCertificate,
+			MinVersion:     s.config.TLS.minVersion(),
+			CipherSuites:   s.config.TLS.cipherSuites(),
 		}
 	}
 
 	return nil
 }
 
-// startHTTPRedirectServer starts HTTP server on port 80 for ACME and redirects
+// startHTTPRedirectServer starts HTTP server on the challenge port for ACME and redirects
 func (s *WebSocketServer) startHTTPRedirectServer(ctx context.Context) {
 	redirectToHTTPS := func(w http.ResponseWriter, r *http.Request) {
 		target := "https://" + r.Host + r.URL.Path
@@ -235,13 +394,14 @@ func (s *WebSocketServer) startHTTPRedirectServer(ctx context.Context) {
 		httpHandler = http.HandlerFunc(redirectToHTTPS)
 	}
 
+	addr := net.JoinHostPort("", strconv.Itoa(s.config.TLS.HTTPChallengePort))
 	httpServer := &http.Server{
-		Addr:    ":80",
+		Addr:    addr,
 		Handler: httpHandler,
 	}
 
 	go func() {
-		s.logger.Info("Starting HTTP server (ACME/redirect)", "addr", ":80")
+		s.logger.Info("Starting HTTP server (ACME/redirect)", "addr", addr)
 		if err := httpServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
 			s.logger.Error("HTTP server error", "error", err)
 		}
@@ -257,6 +417,19 @@ func (s *WebSocketServer) startHTTPRedirectServer(ctx context.Context) {
 
 // handleTunnelConnection handles WebSocket tunnel connections from hospitals
 func (s *WebSocketServer) handleTunnelConnection(w http.ResponseWriter, r *http.Request) {
+	// Bound connections still waiting for their REGISTER message, so stalled
+	// handshakes cannot pile up; the slot is given back once registered
+	release, ok := s.registering.tryAcquire()
+	if !ok {
+		s.logger.Warn("Rejecting tunnel connection, too many pending registrations",
+			"remote_addr", s.config.ClientIP(r),
+			"max_pending_registrations", s.config.MaxPendingRegistrations)
+		connectionsRejected.WithLabelValues(modeWebSocket, rejectMaxPendingRegistrations).Inc()
+		http.Error(w, "Too many pending registrations", http.StatusServiceUnavailable)
+		return
+	}
+	defer release()
+
 	// Upgrade to WebSocket
 	conn, err := s.upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -265,20 +438,56 @@ func (s *WebSocketServer) handleTunnelConnection(w http.ResponseWriter, r *http.
 	}
 	defer conn.Close()
 
-	s.logger.Info("New tunnel connection attempt", "remote", r.RemoteAddr)
+	// Compression, if negotiated, is switched on per message in writeToAgent
+	conn.EnableWriteCompression(false)
+	if s.config.WebSocket.EnableCompression {
+		if err := conn.SetCompressionLevel(s.config.WebSocket.CompressionLevel); err != nil {
+			s.logger.Warn("Invalid WebSocket compression level", "level", s.config.WebSocket.CompressionLevel, "error", err)
+		}
+	}
+
+	remoteIP := s.config.ClientIP(r)
+	s.logger.Info("New tunnel connection attempt", "remote_addr", remoteIP)
 
-	// Read registration message
+	if !s.isRunning() {
+		conn.WriteMessage(websocket.TextMessage, registrationError(regShuttingDown, "Server shutting down"))
+		return
+	}
+
+	// Read registration message; a client that never sends one must not
+	// hold the connection open
+	registrationTimeout := s.config.RegistrationTimeout.ToDuration()
+	_ = conn.SetReadDeadline(time.Now().Add(registrationTimeout))
+	conn.SetReadLimit(maxRegistrationSize)
 	_, message, err := conn.ReadMessage()
 	if err != nil {
+		if errors.Is(err, websocket.ErrReadLimit) {
+			s.logger.Warn("Registration message too large", "remote_addr", remoteIP, "limit", maxRegistrationSize)
+			registrations.WithLabelValues(modeWebSocket, "too_large").Inc()
+			return
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) && netErr.Timeout() {
+			s.logger.Warn("Registration not received in time", "remote_addr", remoteIP, "timeout", registrationTimeout.String())
+			registrations.WithLabelValues(modeWebSocket, "timeout").Inc()
+			_ = conn.WriteControl(websocket.CloseMessage,
+				websocket.FormatCloseMessage(websocket.ClosePolicyViolation, "registration timeout"),
+				time.Now().Add(time.Second))
+			return
+		}
 		s.logger.Error("Failed to read registration", "error", err)
 		return
 	}
+	_ = conn.SetReadDeadline(time.Time{})
+	conn.SetReadLimit(0)
 
-	// Parse REGISTER command
+	// Parse REGISTER command: REGISTER <code> <subdomain> <token> [protocol].
+	// The message carries the token, so it is never logged.
 	parts := strings.Fields(string(message))
-	if len(parts) != 4 || parts[0] != "REGISTER" {
-		s.logger.Error("Invalid registration message", "message", string(message))
-		conn.WriteMessage(websocket.TextMessage, []byte("ERROR Invalid registration format"))
+	if len(parts) < 4 || len(parts) > 5 || parts[0] != "REGISTER" {
+		s.logger.Error("Invalid registration message", "remote_addr", remoteIP, "fields", len(parts))
+		registrations.WithLabelValues(modeWebSocket, "invalid_format").Inc()
+		conn.WriteMessage(websocket.TextMessage, registrationError(regInvalidFormat, "Invalid registration format"))
 		return
 	}
 
@@ -286,70 +495,278 @@ func (s *WebSocketServer) handleTunnelConnection(w http.ResponseWriter, r *http.
 	subdomain := strings.ToLower(parts[2])
 	providedToken := parts[3]
 
+	// Reject malformed names before they reach lookups, maps or logs
+	if !validHospitalCode(hospitalCode) {
+		s.logger.Warn("Invalid hospital code in registration", "remote_addr", remoteIP, "length", len(hospitalCode))
+		registrations.WithLabelValues(modeWebSocket, "invalid_format").Inc()
+		conn.WriteMessage(websocket.TextMessage, registrationError(regInvalidHospitalCode, "Invalid hospital code (expected [a-z0-9-], at most 63 characters)"))
+		return
+	}
+	logger := agentLogger(s.logger, hospitalCode, remoteIP)
+	if !validDNSName(subdomain) {
+		logger.Warn("Invalid subdomain in registration")
+		registrations.WithLabelValues(modeWebSocket, "invalid_format").Inc()
+		conn.WriteMessage(websocket.TextMessage, registrationError(regInvalidSubdomain, "Invalid subdomain"))
+		return
+	}
+
+	protocol := TunnelProtocolV1
+	if len(parts) == 5 {
+		v, err := strconv.Atoi(parts[4])
+		if err != nil || v < TunnelProtocolV1 || v > TunnelProtocolV2 {
+			logger.Error("Unsupported tunnel protocol", "protocol", parts[4])
+			registrations.WithLabelValues(modeWebSocket, "unsupported_protocol").Inc()
+			conn.WriteMessage(websocket.TextMessage, registrationError(regUnsupportedProtocol, "Unsupported protocol version"))
+			return
+		}
+		protocol = v
+	}
+
 	// Check rate limiting
-	remoteIP, _, _ := net.SplitHostPort(r.RemoteAddr)
-	if s.isRateLimited(remoteIP) {
-		s.logger.Warn("Rate limited authentication attempt", "remote", remoteIP, "hospital", hospitalCode)
-		conn.WriteMessage(websocket.TextMessage, []byte("ERROR Too many failed attempts"))
+	if s.isRateLimited(r.Context(), remoteIP) {
+		logger.Warn("Rate limited authentication attempt")
+		registrations.WithLabelValues(modeWebSocket, "rate_limited").Inc()
+		conn.WriteMessage(websocket.TextMessage, registrationError(regRateLimited, "Too many failed attempts"))
 		return
 	}
 
 	// Validate subdomain and token against configured hospitals
-	expectedToken, ok := s.getHospitalToken(hospitalCode, subdomain)
-	if !ok || expectedToken == "" || providedToken != expectedToken {
-		s.logger.Error("Invalid token for hospital", "hospital", hospitalCode)
-		s.recordFailedAttempt(remoteIP)
-		conn.WriteMessage(websocket.TextMessage, []byte("ERROR Invalid token"))
+	canonicalCode, expectedToken, ok := s.getHospitalToken(hospitalCode, subdomain)
+	if !ok || expectedToken == "" || !secureTokenEqual(providedToken, expectedToken) {
+		logger.Error("Invalid token for hospital")
+		registrations.WithLabelValues(modeWebSocket, "invalid_token").Inc()
+		s.recordFailedAttempt(r.Context(), remoteIP)
+		conn.WriteMessage(websocket.TextMessage, registrationError(regInvalidToken, "Invalid token"))
 		return
 	}
 
+	// An agent registering under an alias serves its canonical hospital
+	if canonicalCode != hospitalCode {
+		logger.Info("Agent registered under alias", "alias", hospitalCode, "canonical_code", canonicalCode)
+		hospitalCode = canonicalCode
+		logger = agentLogger(s.logger, hospitalCode, remoteIP)
+	}
+
 	// Clear failed attempts on successful auth
-	s.clearFailedAttempts(remoteIP)
+	s.clearFailedAttempts(r.Context(), remoteIP)
 
 	// Register agent
 	agent := &WSAgentConnection{
 		HospitalCode: hospitalCode,
 		Subdomain:    subdomain,
 		Conn:         conn,
+		Protocol:     protocol,
+		Connected:    time.Now(),
 		LastSeen:     time.Now(),
+		MsgCh:        make(chan []byte, s.config.WebSocket.MessageBufferSize),
+		Done:         make(chan struct{}),
+		streams:      make(map[uint64]*wsStream),
+		pong:         make(chan struct{}, 1),
 	}
+	// Requests still leaving the queue of a closed agent must not bring its
+	// gauge back, or overwrite that of the connection that replaced it
+	agent.queue = newRequestQueue(s.config.WebSocket.QueueDepth, func(depth int) {
+		select {
+		case <-agent.Done:
+		default:
+			agentQueueDepth.WithLabelValues(hospitalCode).Set(float64(depth))
+		}
+	})
 
+	// Pongs count as signs of life like heartbeats; the handler runs on the
+	// read loop, so it must be set before the loop starts
+	conn.SetPongHandler(func(string) error {
+		agent.Mutex.Lock()
+		agent.LastSeen = time.Now()
+		agent.Mutex.Unlock()
+		select {
+		case agent.pong <- struct{}{}:
+		default:
+		}
+		return nil
+	})
+
+	// Check capacity and install the agent in one step, so a hospital
+	// replacing its own connection cannot lose its slot to another one. The
+	// old connection is closed once the new one is in place.
 	s.agentsMutex.Lock()
+	existing := s.agents[hospitalCode]
+	if existing != nil && s.config.RejectDuplicateRegistration {
+		s.agentsMutex.Unlock()
+		logger.Warn("Rejecting duplicate registration")
+		registrations.WithLabelValues(modeWebSocket, "duplicate").Inc()
+		conn.WriteMessage(websocket.TextMessage, registrationError(regAlreadyConnected, "Already connected"))
+		return
+	}
+	if existing == nil && s.config.atCapacity(len(s.agents)) {
+		s.agentsMutex.Unlock()
+		logger.Warn("Rejecting registration, server at capacity", "max_hospitals", s.config.MaxHospitals)
+		registrations.WithLabelValues(modeWebSocket, "at_capacity").Inc()
+		conn.WriteMessage(websocket.TextMessage, registrationError(regAtCapacity, "Server at capacity"))
+		return
+	}
 	s.agents[hospitalCode] = agent
+	connectedHospitals.WithLabelValues(modeWebSocket).Set(float64(len(s.agents)))
 	s.agentsMutex.Unlock()
+	registrations.WithLabelValues(modeWebSocket, "success").Inc()
+	s.reconnects.registered(hospitalCode)
+	s.startup.registered(hospitalCode)
+	s.events.publish(hospitalCode, EventConnected, remoteIP)
 
-	s.logger.Info("Agent registered", "hospital", hospitalCode, "subdomain", subdomain)
+	logger.Info("Agent registered", "subdomain", subdomain, "protocol", protocol)
 
-	// Send success response
-	conn.WriteMessage(websocket.TextMessage, []byte("OK Registered"))
+	// Send success response; v2 agents get the negotiated version echoed back
+	ack := "OK Registered"
+	if protocol >= TunnelProtocolV2 {
+		ack = fmt.Sprintf("OK Registered %d", protocol)
+	}
+	// Requests may already be forwarded to the agent, so the ack takes the write lock
+	agent.safeWrite(func(conn *websocket.Conn) error {
+		return conn.WriteMessage(websocket.TextMessage, []byte(ack))
+	})
 
-	// Initialize channels and start single reader loop
-	agent.MsgCh = make(chan []byte, 64)
-	agent.Done = make(chan struct{})
+	if existing != nil {
+		logger.Info("Replacing existing agent connection")
+		s.closeAgentAndWait(existing)
+	}
+
+	// Start single reader loop
 	go s.agentReadLoop(agent)
+	if interval := s.config.WebSocket.PingInterval.ToDuration(); interval > 0 {
+		go s.pingAgent(agent, interval, s.config.WebSocket.PongTimeout.ToDuration())
+	}
 
 	// Block until connection is closed by reader loop
+	release()
 	<-agent.Done
 
-	// Clean up on disconnect
+	// Clean up on disconnect (unless a newer connection already replaced us)
+	s.agentsMutex.Lock()
+	removed := s.agents[hospitalCode] == agent
+	if removed {
+		delete(s.agents, hospitalCode)
+	}
+	// Also covers agents already dropped by disconnectHospital or Stop
+	if _, replaced := s.agents[hospitalCode]; !replaced {
+		agentQueueDepth.DeleteLabelValues(hospitalCode)
+	}
+	connectedHospitals.WithLabelValues(modeWebSocket).Set(float64(len(s.agents)))
+	s.agentsMutex.Unlock()
+	if removed {
+		s.events.publish(hospitalCode, EventDisconnected, remoteIP)
+	}
+
+	logger.Info("Agent disconnected")
+}
+
+// beginRequest registers an in-flight request unless the server is stopping.
+// Callers must call s.inflight.Done() when it returns true.
+func (s *WebSocketServer) beginRequest() bool {
+	s.runMutex.RLock()
+	defer s.runMutex.RUnlock()
+	if !s.running {
+		return false
+	}
+	s.inflight.Add(1)
+	return true
+}
+
+// isRunning reports whether the server accepts new work
+func (s *WebSocketServer) isRunning() bool {
+	s.runMutex.RLock()
+	defer s.runMutex.RUnlock()
+	return s.running
+}
+
+// closeAgentAndWait closes an agent connection and waits for its reader loop to exit
+func (s *WebSocketServer) closeAgentAndWait(agent *WSAgentConnection) {
+	agent.Conn.Close()
+
+	select {
+	case <-agent.Done:
+	case <-time.After(5 * time.Second):
+		s.logger.Warn("Timed out waiting for previous agent connection to close", "hospital_code", agent.HospitalCode)
+	}
+
 	s.agentsMutex.Lock()
-	delete(s.agents, hospitalCode)
+	if s.agents[agent.HospitalCode] == agent {
+		delete(s.agents, agent.HospitalCode)
+	}
+	connectedHospitals.WithLabelValues(modeWebSocket).Set(float64(len(s.agents)))
 	s.agentsMutex.Unlock()
+}
+
+// pingAgent sends a WebSocket ping every interval until the agent disconnects,
+// and closes the connection when a pong does not arrive within pongTimeout
+func (s *WebSocketServer) pingAgent(agent *WSAgentConnection, interval, pongTimeout time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
 
-	s.logger.Info("Agent disconnected", "hospital", hospitalCode)
+	for {
+		select {
+		case <-agent.Done:
+			return
+		case <-ticker.C:
+		}
+
+		// Drop a late pong from the previous round
+		select {
+		case <-agent.pong:
+		default:
+		}
+
+		if err := agent.ping(pongTimeout); err != nil {
+			s.logger.Debug("Failed to ping agent", "hospital_code", agent.HospitalCode, "error", err)
+			agent.Conn.Close()
+			return
+		}
+
+		timer := time.NewTimer(pongTimeout)
+		select {
+		case <-agent.pong:
+			timer.Stop()
+		case <-agent.Done:
+			timer.Stop()
+			return
+		case <-timer.C:
+			s.logger.Warn("Closing agent that did not answer a ping",
+				"hospital_code", agent.HospitalCode,
+				"pong_timeout", pongTimeout.String())
+			agent.Conn.Close()
+			return
+		}
+	}
+}
+
+// ping sends a ping frame under the agent's write lock
+func (a *WSAgentConnection) ping(timeout time.Duration) error {
+	return a.safeWrite(func(conn *websocket.Conn) error {
+		return conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(timeout))
+	})
+}
+
+// safeWrite runs write with the agent's write lock held, so writes from
+// concurrent requests, pings and the registration ack never interleave
+func (a *WSAgentConnection) safeWrite(write func(conn *websocket.Conn) error) error {
+	a.writeMu.Lock()
+	defer a.writeMu.Unlock()
+	return write(a.Conn)
 }
 
 // agentReadLoop is the single reader for an agent WebSocket.
-// It updates heartbeats and forwards non-heartbeat messages to MsgCh.
+// It updates heartbeats and forwards non-heartbeat messages to MsgCh (v1)
+// or to the stream registered for the frame's request ID (v2).
 func (s *WebSocketServer) agentReadLoop(agent *WSAgentConnection) {
 	defer func() {
-		// signal disconnect
+		// signal disconnect and release any requests still waiting
+		agent.closeStreams()
 		close(agent.Done)
 	}()
+	deliveryTimeout := s.config.WebSocket.DeliveryTimeout.ToDuration()
 	for {
 		msgType, message, err := agent.Conn.ReadMessage()
 		if err != nil {
-			s.logger.Debug("Agent connection closed", "hospital", agent.HospitalCode, "error", err)
+			s.logger.Debug("Agent connection closed", "hospital_code", agent.HospitalCode, "error", err)
 			return
 		}
 
@@ -360,27 +777,262 @@ func (s *WebSocketServer) agentReadLoop(agent *WSAgentConnection) {
 				agent.Mutex.Lock()
 				agent.LastSeen = time.Now()
 				agent.Mutex.Unlock()
-				s.logger.Debug("Heartbeat received", "hospital", agent.HospitalCode)
+				s.logger.Debug("Heartbeat received", "hospital_code", agent.HospitalCode)
 				continue
 			}
 		}
 
-		// Forward all non-heartbeat messages (BINARY messages for HTTP responses, other TEXT messages)
-		if agent.MsgCh != nil {
-			agent.MsgCh <- message
+		if agent.Protocol >= TunnelProtocolV2 {
+			if msgType != websocket.BinaryMessage {
+				s.logger.Debug("Ignoring text message from multiplexed agent", "hospital_code", agent.HospitalCode)
+				continue
+			}
+			id, payload, err := decodeWSFrame(message)
+			if err != nil {
+				s.logger.Warn("Invalid tunnel frame", "hospital_code", agent.HospitalCode, "error", err)
+				continue
+			}
+			if !agent.deliver(id, payload, deliveryTimeout) {
+				s.logger.Warn("Dropping stalled request: response not consumed in time",
+					"hospital_code", agent.HospitalCode, "stream_id", id, "delivery_timeout", deliveryTimeout.String())
+			}
+			continue
+		}
+
+		// Forward all non-heartbeat messages (BINARY messages for HTTP responses, other TEXT messages).
+		// v1 has no framing to skip the rest of a stalled response, so the
+		// connection is dropped; the agent reconnects.
+		if !sendWithTimeout(agent.MsgCh, message, deliveryTimeout) {
+			s.logger.Warn("Closing agent connection: response not consumed in time",
+				"hospital_code", agent.HospitalCode, "delivery_timeout", deliveryTimeout.String())
+			return
+		}
+	}
+}
+
+// encodeWSFrame prefixes payload with the request ID header (protocol v2)
+func encodeWSFrame(id uint64, payload []byte) []byte {
+	frame := make([]byte, wsFrameHeaderSize+len(payload))
+	binary.BigEndian.PutUint64(frame, id)
+	copy(frame[wsFrameHeaderSize:], payload)
+	return frame
+}
+
+// decodeWSFrame splits a protocol v2 message into request ID and payload
+func decodeWSFrame(frame []byte) (uint64, []byte, error) {
+	if len(frame) < wsFrameHeaderSize {
+		return 0, nil, fmt.Errorf("frame too short: %d bytes", len(frame))
+	}
+	return binary.BigEndian.Uint64(frame), frame[wsFrameHeaderSize:], nil
+}
+
+// openStream allocates a request ID and registers its response channel
+func (a *WSAgentConnection) openStream(bufferSize int) (uint64, *wsStream) {
+	id := a.nextID.Add(1)
+	st := &wsStream{
+		ch:   make(chan []byte, bufferSize),
+		done: make(chan struct{}),
+	}
+	a.streamsMu.Lock()
+	a.streams[id] = st
+	a.streamsMu.Unlock()
+	return id, st
+}
+
+// closeStream unregisters a request; late frames for its ID are dropped
+func (a *WSAgentConnection) closeStream(id uint64) {
+	a.streamsMu.Lock()
+	st, ok := a.streams[id]
+	delete(a.streams, id)
+	a.streamsMu.Unlock()
+	if ok {
+		close(st.done)
+	}
+}
+
+// closeStreams closes every pending response channel on agent disconnect.
+// Only the read loop sends on stream channels, so closing them here is safe.
+func (a *WSAgentConnection) closeStreams() {
+	a.streamsMu.Lock()
+	defer a.streamsMu.Unlock()
+	for id, st := range a.streams {
+		close(st.ch)
+		delete(a.streams, id)
+	}
+}
+
+// deliver routes a response frame to the request waiting on its ID. If the
+// request does not take the frame within timeout (e.g. a stalled client), it
+// is failed with errDeliveryStalled so the read loop can move on; deliver
+// then returns false.
+func (a *WSAgentConnection) deliver(id uint64, payload []byte, timeout time.Duration) bool {
+	a.streamsMu.Lock()
+	st, ok := a.streams[id]
+	a.streamsMu.Unlock()
+	if !ok {
+		return true // request finished or timed out
+	}
+
+	select {
+	case st.ch <- payload:
+		return true
+	case <-st.done:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case st.ch <- payload:
+		return true
+	case <-st.done:
+		return true
+	case <-timer.C:
+	}
+
+	// Only the read loop sends on st.ch, so closing it here is safe
+	a.streamsMu.Lock()
+	defer a.streamsMu.Unlock()
+	if a.streams[id] == st {
+		delete(a.streams, id)
+		st.err = errDeliveryStalled
+		close(st.ch)
+	}
+	return false
+}
+
+// sendWithTimeout sends msg on ch, giving up after timeout
+func sendWithTimeout(ch chan<- []byte, msg []byte, timeout time.Duration) bool {
+	select {
+	case ch <- msg:
+		return true
+	default:
+	}
+
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case ch <- msg:
+		return true
+	case <-timer.C:
+		return false
+	}
+}
+
+// disconnectHospital closes a hospital's tunnel on operator request.
+// Closing the connection unblocks agentReadLoop, which fails pending requests.
+func (s *WebSocketServer) disconnectHospital(hospitalCode string) bool {
+	s.agentsMutex.Lock()
+	agent, ok := s.agents[hospitalCode]
+	if ok {
+		delete(s.agents, hospitalCode)
+	}
+	connectedHospitals.WithLabelValues(modeWebSocket).Set(float64(len(s.agents)))
+	s.agentsMutex.Unlock()
+
+	if !ok {
+		return false
+	}
+	agent.Conn.Close()
+	s.events.publish(hospitalCode, EventDisconnected, "")
+	return true
+}
+
+// Events subscribes to hospital connect/disconnect events. Call the returned
+// function to unsubscribe; a subscriber that falls behind misses events.
+func (s *WebSocketServer) Events() (<-chan Event, func()) {
+	return s.events.subscribe()
+}
+
+// monitorHeartbeats periodically closes agents that stopped sending heartbeats.
+// Closing the connection unblocks agentReadLoop, which triggers the normal cleanup.
+func (s *WebSocketServer) monitorHeartbeats(ctx context.Context) {
+	ticker := time.NewTicker(s.config.HeartbeatCheckInterval.ToDuration())
+	defer ticker.Stop()
+
+	timeout := s.config.HeartbeatTimeout.ToDuration()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.agentsMutex.RLock()
+			for hospitalCode, agent := range s.agents {
+				agent.Mutex.RLock()
+				age := time.Since(agent.LastSeen)
+				agent.Mutex.RUnlock()
+
+				if age > timeout {
+					s.logger.Warn("Evicting stale agent",
+						"hospital_code", hospitalCode,
+						"last_heartbeat_age", age.Round(time.Second).String())
+					agent.Conn.Close()
+				}
+			}
+			s.agentsMutex.RUnlock()
 		}
 	}
 }
 
 // handleHTTPRequest handles incoming HTTP/HTTPS requests and forwards through tunnel
 func (s *WebSocketServer) handleHTTPRequest(w http.ResponseWriter, r *http.Request) {
-	s.logger.Debug("Received HTTP request", "method", r.Method, "path", r.URL.Path, "host", r.Host)
+	_, logger := startRequestLog(s.logger, w)
+	ctx, span := startRequestSpan(r, "relay.forward")
+	r = r.WithContext(ctx)
+	logger.Debug("Received HTTP request", "method", r.Method, "path", r.URL.Path, "host", r.Host, "remote_addr", s.config.ClientIP(r))
+
+	rec := newResponseRecorder(w)
+	start := time.Now()
+	hospitalCode := ""
+	outcome := outcomeOK
+	defer func() {
+		logAccess(logger, r, hospitalCode, rec, start, outcome)
+		endRequestSpan(span, hospitalCode, rec.status, outcome)
+	}()
 
 	// Extract hospital code from subdomain
-	hospitalCode := s.extractHospitalCode(r.Host)
+	hospitalCode = s.extractHospitalCode(r.Host)
+	if hospitalCode == "" && s.isApexHost(r.Host) && s.pages.serveLanding(rec, r, logger) {
+		outcome = "landing"
+		return
+	}
 	if hospitalCode == "" {
-		s.logger.Warn("No hospital code found in request", "host", r.Host)
-		http.Error(w, "Invalid subdomain", http.StatusBadRequest)
+		logger.Warn("No hospital code found in request", "host", r.Host)
+		outcome = "invalid_subdomain"
+		requestFailures.WithLabelValues(modeWebSocket, "", outcome).Inc()
+		http.Error(rec, "Invalid subdomain", http.StatusBadRequest)
+		return
+	}
+
+	// Aliases route to the canonical hospital's agent
+	hospital, known := s.hospitals.byCodeOrAlias(hospitalCode)
+	if known {
+		hospitalCode = hospital.Code
+	}
+
+	// Hospitals in maintenance keep their tunnel but take no requests
+	if retryAfter, ok := s.maintenance.retryAfter(hospitalCode); ok {
+		outcome = "maintenance"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		s.pages.writeMaintenance(rec, r, hospitalCode, retryAfter, logger)
+		return
+	}
+
+	if !known {
+		logger.Warn("Request for unknown hospital", "hospital_code", hospitalCode, "host", r.Host)
+		outcome = "unknown_hospital"
+		requestFailures.WithLabelValues(modeWebSocket, "", outcome).Inc()
+		s.pages.writeNotFound(rec, r, hospitalCode, logger)
+		return
+	}
+
+	// Per-hospital method and path allowlists
+	if !hospital.allowsRequest(r.Method, r.URL.Path) {
+		logger.Warn("Request not allowed for hospital", "hospital_code", hospitalCode, "method", r.Method, "path", r.URL.Path)
+		outcome = "forbidden"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		http.Error(rec, "Forbidden", http.StatusForbidden)
 		return
 	}
 
@@ -390,205 +1042,552 @@ func (s *WebSocketServer) handleHTTPRequest(w http.ResponseWriter, r *http.Reque
 	s.agentsMutex.RUnlock()
 
 	if !exists {
-		s.logger.Warn("No agent found for hospital", "hospital", hospitalCode, "host", r.Host)
-		http.Error(w, "Hospital not connected", http.StatusServiceUnavailable)
+		logger.Warn("No agent found for hospital", "hospital_code", hospitalCode, "host", r.Host)
+		outcome = "not_connected"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		if s.config.ExposeUpstreamErrors {
+			writeUpstreamError(rec, true, http.StatusServiceUnavailable, upstreamNotConnected, "Hospital not connected")
+			return
+		}
+		s.pages.writeUnavailable(rec, r, hospitalCode, logger, func() {
+			http.Error(rec, "Hospital not connected", http.StatusServiceUnavailable)
+		})
+		return
+	}
+
+	// Upgraded connections need their own stream, which only v2 agents provide
+	if isWebSocketUpgrade(r) && agent.Protocol < TunnelProtocolV2 {
+		logger.Warn("WebSocket upgrade requires tunnel protocol 2", "hospital_code", hospitalCode, "protocol", agent.Protocol)
+		outcome = "upgrade_unsupported"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		http.Error(rec, "WebSocket passthrough not supported by hospital agent", http.StatusNotImplemented)
+		return
+	}
+
+	if !s.limiter.acquire() {
+		logger.Warn("Too many concurrent requests", "hospital_code", hospitalCode, "limit", s.config.MaxConcurrentConn)
+		outcome = "overloaded"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		rec.Header().Set("Retry-After", retryAfterSeconds)
+		http.Error(rec, "Too many concurrent requests", http.StatusTooManyRequests)
+		return
+	}
+	defer s.limiter.release()
+
+	if !s.beginRequest() {
+		outcome = "shutting_down"
+		http.Error(rec, "Server shutting down", http.StatusServiceUnavailable)
+		return
+	}
+	defer s.inflight.Done()
+
+	// Fail fast while the hospital's requests keep failing
+	probe, retryAfter, allowed := s.breakers.allow(hospitalCode)
+	if !allowed {
+		outcome = "circuit_open"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		setCircuitRetryAfter(rec, retryAfter)
+		if s.config.ExposeUpstreamErrors {
+			writeUpstreamError(rec, true, http.StatusServiceUnavailable, upstreamCircuitOpen, "Hospital temporarily unavailable")
+			return
+		}
+		s.pages.writeUnavailable(rec, r, hospitalCode, logger, func() {
+			http.Error(rec, "Hospital temporarily unavailable", http.StatusServiceUnavailable)
+		})
 		return
 	}
 
 	// Forward request through tunnel
-	s.logger.Debug("Forwarding request to agent", "hospital", hospitalCode, "method", r.Method, "path", r.URL.Path)
-	if err := s.forwardRequest(w, r, agent); err != nil {
-		s.logger.Error("Failed to forward request", "error", err, "hospital", hospitalCode)
-		http.Error(w, "Internal server error", http.StatusInternalServerError)
+	logger.Debug("Forwarding request to agent", "hospital_code", hospitalCode, "method", r.Method, "path", r.URL.Path)
+	requestsForwarded.WithLabelValues(modeWebSocket, hospitalCode).Inc()
+	limits := s.config.limitsFor(&hospital)
+	defer setRequestDeadline(w, limits.RequestTimeout)()
+	out := throttle(r.Context(), rec, s.bandwidth.get(hospitalCode, hospital.MaxBytesPerSec))
+	err := s.forwardRequest(out, r, agent, limits, logger)
+	agent.stats.record(err)
+	s.breakers.record(hospitalCode, probe, breakerFailure(r.Context(), err))
+	elapsed := time.Since(start)
+	forwardDuration.WithLabelValues(modeWebSocket, hospitalCode).Observe(elapsed.Seconds())
+	logSlowRequest(logger, modeWebSocket, hospitalCode, r.URL.Path, elapsed, s.config.SlowRequestThreshold.ToDuration())
+	bytesTransferred.WithLabelValues(modeWebSocket, hospitalCode).Add(float64(rec.bytes))
+	if errors.Is(err, errRequestTooLarge) {
+		logger.Warn("Request body too large", "hospital_code", hospitalCode, "limit", limits.MaxRequestBodyBytes, "remote_addr", s.config.ClientIP(r))
+		outcome = "body_too_large"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		http.Error(rec, "Request body too large", http.StatusRequestEntityTooLarge)
+		return
+	}
+	if errors.Is(err, errQueueFull) || errors.Is(err, errQueueTimeout) {
+		logger.Warn("Agent busy, request not forwarded", "hospital_code", hospitalCode, "error", err)
+		message := "Hospital agent busy"
+		outcome = "queue_full"
+		if errors.Is(err, errQueueTimeout) {
+			message = "Timed out waiting for hospital agent"
+			outcome = "queue_timeout"
+		} else {
+			rec.Header().Set("Retry-After", retryAfterSeconds)
+		}
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		code, status := classifyUpstreamError(err)
+		writeUpstreamError(rec, s.config.ExposeUpstreamErrors, status, code, message)
 		return
 	}
-	s.logger.Debug("Successfully forwarded request", "hospital", hospitalCode)
+	if errors.Is(err, errResponseTooLarge) {
+		logger.Warn("Response body too large, aborting", "hospital_code", hospitalCode, "path", r.URL.Path, "limit", limits.MaxResponseBodyBytes)
+		outcome = "response_too_large"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		abortResponse()
+	}
+	if err != nil && rec.wroteHeader && r.Context().Err() == nil {
+		// The viewer already has a status line, so only a connection that
+		// ends before the body is complete can tell it the response failed
+		logger.Error("Response truncated", "error", err, "hospital_code", hospitalCode, "path", r.URL.Path, "bytes", rec.bytes)
+		outcome = "truncated"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		abortResponse()
+	}
+	if err != nil {
+		logger.Error("Failed to forward request", "error", err, "hospital_code", hospitalCode)
+		outcome = "forward_error"
+		requestFailures.WithLabelValues(modeWebSocket, hospitalCode, outcome).Inc()
+		code, status := classifyUpstreamError(err)
+		writeUpstreamError(rec, s.config.ExposeUpstreamErrors, status, code, "Bad gateway")
+		return
+	}
+	logger.Debug("Successfully forwarded request", "hospital_code", hospitalCode)
 }
 
 // extractHospitalCode extracts hospital code from subdomain
 func (s *WebSocketServer) extractHospitalCode(host string) string {
-	// Normalize to lowercase for case-insensitive host matching
-	host = strings.ToLower(host)
+	return hospitalCodeFromHost(host, s.config.ApexDomains(), s.config.subdomainRe)
+}
 
-	// Remove port if present
-	if colonIndex := strings.Index(host, ":"); colonIndex != -1 {
-		host = host[:colonIndex]
+// isApexHost reports whether host (with or without port) is one of the
+// configured apex domains itself
+func (s *WebSocketServer) isApexHost(host string) bool {
+	host = strings.ToLower(host)
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
 	}
+	return matchApexDomain(host, s.config.ApexDomains()) == host
+}
+
+// forwardRequest forwards an HTTP request through the WebSocket tunnel
+func (s *WebSocketServer) forwardRequest(w http.ResponseWriter, r *http.Request, agent *WSAgentConnection, limits forwardLimits, logger *slog.Logger) error {
+	logger.Debug("Starting request forwarding", "protocol", agent.Protocol)
 
-	// Check if it's a subdomain of our domain
-	domainSuffix := "." + strings.ToLower(s.config.Domain)
-	if !strings.HasSuffix(host, domainSuffix) {
-		return ""
+	// The body is buffered whole, so cap it. Streaming it to the agent in
+	// frames would lift this limit but needs agent support for chunked
+	// request bodies.
+	var bodyData []byte
+	if r.Body != nil {
+		limit := limits.MaxRequestBodyBytes
+		if r.ContentLength > limit {
+			return errRequestTooLarge
+		}
+		bodyBuf := getRequestBuffer()
+		defer putRequestBuffer(bodyBuf)
+		if r.ContentLength > 0 {
+			bodyBuf.Grow(int(r.ContentLength))
+		}
+		if _, err := bodyBuf.ReadFrom(io.LimitReader(r.Body, limit+1)); err != nil {
+			return fmt.Errorf("failed to read body: %w", err)
+		}
+		if int64(bodyBuf.Len()) > limit {
+			return errRequestTooLarge
+		}
+		bodyData = bodyBuf.Bytes()
 	}
 
-	// The subdomain is the hospital code
-	return strings.TrimSuffix(host, domainSuffix)
-}
+	// Serialize HTTP request (headers + body in a SINGLE message). Writes
+	// copy the bytes, so the pooled buffers are free once the request is sent.
+	reqBuf := getRequestBuffer()
+	defer putRequestBuffer(reqBuf)
 
-// forwardRequest forwards an HTTP request through the WebSocket tunnel
-func (s *WebSocketServer) forwardRequest(w http.ResponseWriter, r *http.Request, agent *WSAgentConnection) error {
-	s.logger.Debug("Starting request forwarding")
+	s.writeForwardedRequest(reqBuf, r, bodyData)
+
+	timeout := limits.RequestTimeout
+
+	if agent.Protocol >= TunnelProtocolV2 {
+		id, st := agent.openStream(s.config.WebSocket.MessageBufferSize)
+		defer agent.closeStream(id)
+
+		logger.Debug("Sending complete HTTP request to agent", "total_size", reqBuf.Len(), "stream_id", id)
+		if err := s.writeRequest(r.Context(), agent, encodeWSFrame(id, reqBuf.Bytes()), timeout); err != nil {
+			return fmt.Errorf("%w: %w", errStreamOpen, err)
+		}
 
-	// ensure single in-flight request per agent
-	agent.ReqMutex.Lock()
-	defer agent.ReqMutex.Unlock()
+		var upgrade func(*http.Response) error
+		if isWebSocketUpgrade(r) {
+			upgrade = func(resp *http.Response) error {
+				return s.bridgeUpgrade(w, resp, agent, id, st, logger)
+			}
+		}
 
-	agent.Mutex.RLock()
-	conn := agent.Conn
-	agent.Mutex.RUnlock()
+		return s.relayResponse(w, r, logger, limits, func(wait <-chan time.Time) ([]byte, error) {
+			select {
+			case data, ok := <-st.ch:
+				if !ok {
+					if st.err != nil {
+						return nil, st.err
+					}
+					return nil, errAgentDisconnected
+				}
+				return data, nil
+			case <-wait:
+				return nil, errTunnelTimeout
+			}
+		}, upgrade)
+	}
 
-	deadline := time.Now().Add(time.Duration(s.config.RequestTimeout))
-	// only set write deadline; reads are via channel with select timeouts
-	_ = conn.SetWriteDeadline(deadline)
+	// One request at a time; the others wait their turn in order
+	if err := agent.queue.acquire(r.Context(), s.config.WebSocket.QueueTimeout.ToDuration(), agent.Done); err != nil {
+		return err
+	}
+	defer agent.queue.release()
+
+	logger.Debug("Sending complete HTTP request to agent", "total_size", reqBuf.Len())
+	if err := s.writeRequest(r.Context(), agent, reqBuf.Bytes(), timeout); err != nil {
+		return fmt.Errorf("%w: %w", errStreamOpen, err)
+	}
 
-	s.logger.Debug("Set WebSocket deadlines", "timeout", s.config.RequestTimeout)
+	// Protocol 1 cannot skip the rest of a response: whatever the agent still
+	// sends would be read as the next request's response. Unless this one
+	// ran to its end frame (the viewer left, a timeout, a write error, a
+	// body over the limit), the agent has to reconnect.
+	ended := false
+	defer func() {
+		if !ended {
+			logger.Warn("Closing protocol 1 agent connection after an incomplete response")
+			agent.Conn.Close()
+		}
+	}()
 
-	// Serialize HTTP request (headers + body in a SINGLE message)
-	var reqBuf bytes.Buffer
+	return s.relayResponse(w, r, logger, limits, func(wait <-chan time.Time) ([]byte, error) {
+		for {
+			select {
+			case data := <-agent.MsgCh:
+				if string(data) == "HEARTBEAT" {
+					logger.Debug("Skipping heartbeat message")
+					continue
+				}
+				ended = len(data) == 0
+				return data, nil
+			case <-agent.Done:
+				return nil, errAgentDisconnected
+			case <-wait:
+				return nil, errTunnelTimeout
+			}
+		}
+	}, nil)
+}
 
-	fmt.Fprintf(&reqBuf, "%s %s %s\r\n", r.Method, r.RequestURI, r.Proto)
+// writeForwardedRequest serializes r with its buffered body as the agent
+// receives it: end-to-end headers only, with framing set for the body
+func (s *WebSocketServer) writeForwardedRequest(buf *bytes.Buffer, r *http.Request, body []byte) {
+	fmt.Fprintf(buf, "%s %s %s\r\n", r.Method, r.RequestURI, r.Proto)
 	if r.Host != "" {
-		fmt.Fprintf(&reqBuf, "Host: %s\r\n", r.Host)
+		fmt.Fprintf(buf, "Host: %s\r\n", r.Host)
+	}
+	header := r.Header.Clone()
+	s.config.ForwardHeaders.apply(header)
+	removeHopByHopHeaders(header)
+	if isWebSocketUpgrade(r) {
+		// The one hop-by-hop exchange the agent must see to accept the upgrade
+		header.Set("Connection", "Upgrade")
+		header.Set("Upgrade", r.Header.Get("Upgrade"))
 	}
-	for key, values := range r.Header {
+	// The body travels with the request, so the agent is not asked to
+	// confirm it first. net/http sent the client its 100 Continue when the
+	// body was read.
+	header.Del("Expect")
+	// net/http has already decoded any chunked body, so it is sent with its
+	// actual length; a client Content-Length is replaced, never duplicated
+	header.Del("Content-Length")
+	if len(body) > 0 || r.Header.Get("Content-Length") != "" {
+		header.Set("Content-Length", strconv.Itoa(len(body)))
+	}
+	// Continue the relay's trace in the hospital backend
+	injectTraceContext(r.Context(), propagation.HeaderCarrier(header))
+	// Let the backend log the real client instead of the agent's address
+	forwardedFor, realIP := s.config.forwardedFor(r)
+	header.Set("X-Forwarded-For", forwardedFor)
+	header.Set("X-Real-IP", realIP)
+	for key, values := range header {
 		for _, value := range values {
 			if strings.ToLower(key) == "host" {
 				continue
 			}
-			fmt.Fprintf(&reqBuf, "%s: %s\r\n", key, value)
+			fmt.Fprintf(buf, "%s: %s\r\n", key, value)
 		}
 	}
-	reqBuf.WriteString("\r\n")
+	buf.WriteString("\r\n")
+	buf.Write(body)
+}
 
-	if r.Body != nil {
-		bodyData, err := io.ReadAll(r.Body)
-		if err != nil {
-			return fmt.Errorf("failed to read body: %w", err)
-		}
-		if len(bodyData) > 0 {
-			reqBuf.Write(bodyData)
-		}
-	}
+// errDeliveryStalled fails a request that did not consume its response
+// frames within WebSocket.DeliveryTimeout
+var errDeliveryStalled = errors.New("response delivery stalled")
 
-	s.logger.Debug("Sending complete HTTP request to agent", "total_size", reqBuf.Len())
-	if err := conn.WriteMessage(websocket.BinaryMessage, reqBuf.Bytes()); err != nil {
-		return fmt.Errorf("failed to write request: %w", err)
-	}
-	s.logger.Debug("Successfully sent HTTP request to agent")
+// errTunnelTimeout is returned by a response reader when the agent is silent
+var errTunnelTimeout = errors.New("timeout")
+
+// errRequestTooLarge is returned when a request body exceeds the hospital's MaxRequestBodyBytes
+var errRequestTooLarge = errors.New("request body too large")
+
+// writeToAgent writes one binary message to the agent under its write lock
+func (s *WebSocketServer) writeToAgent(agent *WSAgentConnection, data []byte, timeout time.Duration) error {
+	return agent.safeWrite(func(conn *websocket.Conn) error {
+		// Small frames are not worth deflating; no-op unless compression was negotiated
+		conn.EnableWriteCompression(s.config.WebSocket.EnableCompression && len(data) >= s.config.WebSocket.CompressionThreshold)
 
-	// Read response headers (first message) via message channel, skipping heartbeats
-	s.logger.Debug("Waiting for response headers from agent")
-	var respData []byte
-	timeout := time.Duration(s.config.RequestTimeout)
-	deadlineTimer := time.NewTimer(timeout)
+		// only set write deadline; reads are via channel with select timeouts
+		_ = conn.SetWriteDeadline(time.Now().Add(timeout))
+		return conn.WriteMessage(websocket.BinaryMessage, data)
+	})
+}
+
+// writeRequest sends a serialized request to the agent inside a "write request" span
+func (s *WebSocketServer) writeRequest(ctx context.Context, agent *WSAgentConnection, data []byte, timeout time.Duration) error {
+	_, span := tracer.Start(ctx, "write request", trace.WithAttributes(attrRequestSize.Int(len(data))))
+	err := s.writeToAgent(agent, data, timeout)
+	endSpan(span, err)
+	return err
+}
+
+// relayResponse reads the agent's response (headers message, body chunks,
+// empty terminator) through recv and writes it to the client. A 101 response
+// is handed to upgrade, if set, instead. recv returns errTunnelTimeout when
+// wait fires. The whole response must arrive within limits.RequestTimeout,
+// and body chunks no more than limits.IdleChunkTimeout apart.
+func (s *WebSocketServer) relayResponse(w http.ResponseWriter, r *http.Request, logger *slog.Logger, limits forwardLimits, recv func(wait <-chan time.Time) ([]byte, error), upgrade func(*http.Response) error) error {
+	// Read response headers (first message)
+	logger.Debug("Waiting for response headers from agent")
+	deadline := time.Now().Add(limits.RequestTimeout)
+	deadlineTimer := time.NewTimer(limits.RequestTimeout)
 	defer deadlineTimer.Stop()
-	for {
-		select {
-		case data := <-agent.MsgCh:
-			if string(data) == "HEARTBEAT" {
-				s.logger.Debug("Skipping heartbeat message")
-				continue
+	_, awaitSpan := tracer.Start(r.Context(), "await response")
+	respData, err := recv(deadlineTimer.C)
+	if errors.Is(err, errTunnelTimeout) {
+		err = fmt.Errorf("%w: no response within %s", err, limits.RequestTimeout)
+	}
+	endSpan(awaitSpan, err)
+	if err != nil {
+		return fmt.Errorf("failed to read response headers: %w", err)
+	}
+	logger.Debug("Received response headers from agent", "response_size", len(respData))
+
+	// Parse HTTP response headers. Interim responses, such as a backend's
+	// 100 Continue, are dropped; the final response follows in the same
+	// message or in the next one.
+	br := bufio.NewReader(bytes.NewReader(respData))
+	resp, err := http.ReadResponse(br, r)
+	for err == nil && resp.StatusCode < 200 && resp.StatusCode != http.StatusSwitchingProtocols {
+		logger.Debug("Skipping interim response from agent", "status", resp.StatusCode)
+		if _, perr := br.Peek(1); perr != nil {
+			respData, err = recv(deadlineTimer.C)
+			if err != nil {
+				return fmt.Errorf("failed to read response headers: %w", err)
 			}
-			respData = data
-			goto HAVE_HEADERS
-		case <-deadlineTimer.C:
-			return fmt.Errorf("failed to read response headers: timeout after %s", timeout.String())
+			br = bufio.NewReader(bytes.NewReader(respData))
 		}
+		resp, err = http.ReadResponse(br, r)
 	}
-HAVE_HEADERS:
-	s.logger.Debug("Received response headers from agent", "response_size", len(respData))
-
-	// Parse HTTP response headers
-	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(respData)), r)
 	if err != nil {
-		return fmt.Errorf("failed to parse response: %w", err)
+		return fmt.Errorf("%w: failed to parse response: %w", errBadFraming, err)
+	}
+
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		if upgrade == nil {
+			return fmt.Errorf("%w: unexpected protocol switch from agent", errBadFraming)
+		}
+		return upgrade(resp)
+	}
+
+	// Server-sent events may legitimately stay idle longer than the request
+	// timeout, so they run until the agent ends them or the viewer leaves.
+	// Other bodies may stream as long as chunks keep coming, up to the
+	// request deadline.
+	eventStream := isEventStream(resp)
+	idleWrite := limits.IdleChunkTimeout
+	if eventStream {
+		idleWrite = 0
 	}
+	writeDeadline := newStreamDeadline(w, idleWrite)
 
-	// Copy response headers to client
+	// Copy end-to-end response headers to client; framing is net/http's job
+	removeHopByHopHeaders(resp.Header)
 	for key, values := range resp.Header {
 		for _, value := range values {
 			w.Header().Add(key, value)
 		}
 	}
+	w.Header().Set(versionHeader, Version)
+	if c := s.config.Compression; c != nil && c.compressible(r, resp) {
+		w.Header().Add("Vary", "Accept-Encoding")
+		if encoding := negotiateEncoding(r.Header.Get("Accept-Encoding")); encoding != "" {
+			cw := startCompression(w, encoding, c.Level)
+			defer func() {
+				if cerr := cw.Close(); cerr != nil {
+					logger.Debug("Failed to finish compressed response", "error", cerr)
+				}
+			}()
+			w = cw
+		}
+	}
 	w.WriteHeader(resp.StatusCode)
 
 	// Stream body chunks to client
+	_, streamSpan := tracer.Start(r.Context(), "stream body")
+	var streamed int64
+	defer func() {
+		streamSpan.SetAttributes(semconv.HTTPResponseBodySize(int(streamed)))
+		endSpan(streamSpan, err)
+	}()
+	var viewerGone <-chan time.Time
+	if eventStream {
+		viewerGone = afterDone(r.Context())
+	}
 	for {
-		select {
-		case chunk := <-agent.MsgCh:
-			// Skip heartbeat messages
-			if string(chunk) == "HEARTBEAT" {
-				s.logger.Debug("Skipping heartbeat message in body")
-				continue
-			}
-			// Empty message signals end
-			if len(chunk) == 0 {
-				return nil
-			}
-			// Write chunk to client
-			if _, err := w.Write(chunk); err != nil {
-				return fmt.Errorf("failed to write chunk to client: %w", err)
+		wait := viewerGone
+		if !eventStream {
+			wait = time.After(min(limits.IdleChunkTimeout, time.Until(deadline)))
+		}
+		chunk, rerr := recv(wait)
+		if rerr != nil {
+			if eventStream && r.Context().Err() != nil {
+				return nil // viewer closed the event stream
 			}
-			// Flush to ensure progressive download
-			if flusher, ok := w.(http.Flusher); ok {
-				flusher.Flush()
+			if errors.Is(rerr, errTunnelTimeout) {
+				if time.Now().Before(deadline) {
+					rerr = fmt.Errorf("%w: no data for %s", rerr, limits.IdleChunkTimeout)
+				} else {
+					rerr = fmt.Errorf("%w: response not complete within %s", rerr, limits.RequestTimeout)
+				}
 			}
-		case <-time.After(timeout):
-			return fmt.Errorf("failed to read body chunk: timeout after %s", timeout.String())
+			err = fmt.Errorf("failed to read body chunk: %w", rerr)
+			return err
+		}
+		// Empty message signals end
+		if len(chunk) == 0 {
+			return nil
+		}
+		// HEAD responses carry no body; drain whatever the agent sends anyway
+		if r.Method == http.MethodHead {
+			continue
+		}
+		if limits.MaxResponseBodyBytes > 0 && streamed+int64(len(chunk)) > limits.MaxResponseBodyBytes {
+			err = fmt.Errorf("%w: over %d bytes", errResponseTooLarge, limits.MaxResponseBodyBytes)
+			return err
+		}
+		// Write chunk to client
+		writeDeadline.extend()
+		if _, werr := w.Write(chunk); werr != nil {
+			err = fmt.Errorf("failed to write chunk to client: %w", werr)
+			return err
+		}
+		streamed += int64(len(chunk))
+		// Flush to ensure progressive download
+		if flusher, ok := w.(http.Flusher); ok {
+			flusher.Flush()
 		}
 	}
 }
 
-func (s *WebSocketServer) getHospitalToken(code, subdomain string) (string, bool) {
-	subdomain = strings.ToLower(subdomain)
-	for _, h := range s.config.Hospitals {
-		if h.Code == code && strings.ToLower(h.Subdomain) == subdomain {
-			return h.Token, true
+// ReloadHospitals atomically replaces the statically configured hospitals.
+// Existing connections stay up; only new lookups see the updated list.
+func (s *WebSocketServer) ReloadHospitals(hospitals []HospitalConfig) {
+	added, removed := s.hospitals.replaceStatic(hospitals)
+	s.logger.Info("Hospital configuration reloaded",
+		"hospitals", len(hospitals),
+		"added", added,
+		"removed", removed)
+}
+
+func (s *WebSocketServer) getHospitalToken(code, subdomain string) (canonicalCode, token string, ok bool) {
+	h, ok := s.hospitals.byCodeAndSubdomain(code, subdomain)
+	if !ok {
+		return "", "", false
+	}
+	return h.Code, h.Token, true
+}
+
+// statusSnapshot collects the /status document
+func (s *WebSocketServer) statusSnapshot() StatusResponse {
+	s.agentsMutex.RLock()
+	status := StatusResponse{
+		Version:            Version,
+		ConnectedHospitals: len(s.agents),
+		MaxHospitals:       s.config.MaxHospitals,
+		InFlightRequests:   s.limiter.current(),
+		MaxConcurrent:      s.config.MaxConcurrentConn,
+		Hospitals:          make([]HospitalStatus, 0, len(s.agents)),
+	}
+	for hospitalCode, agent := range s.agents {
+		agent.Mutex.RLock()
+		hs := HospitalStatus{
+			Code:      hospitalCode,
+			Subdomain: agent.Subdomain,
+			LastSeen:  agent.LastSeen,
 		}
+		agent.Mutex.RUnlock()
+		agent.streamsMu.Lock()
+		hs.PendingRequests = len(agent.streams)
+		agent.streamsMu.Unlock()
+		agent.stats.fill(&hs)
+		fillConnection(&hs, agent.Connected, s.reconnects.reconnects(hospitalCode))
+		hs.CircuitBreaker = s.breakers.state(hospitalCode)
+		status.Hospitals = append(status.Hospitals, hs)
 	}
-	return "", false
+	s.agentsMutex.RUnlock()
+	status.Total = len(status.Hospitals)
+	return status
 }
 
 // handleStatus returns current relay status (shared by main and metrics server)
 func (s *WebSocketServer) handleStatus(w http.ResponseWriter, r *http.Request) {
-	s.agentsMutex.RLock()
-	defer s.agentsMutex.RUnlock()
-
-	w.Header().Set("Content-Type", "application/json")
-	w.Header().Set("Access-Control-Allow-Origin", "*")
-	w.Header().Set("Access-Control-Allow-Methods", "GET, OPTIONS")
-	w.Header().Set("Access-Control-Allow-Headers", "Content-Type")
-	fmt.Fprintf(w, `{
-		"connected_hospitals": %d,
-		"hospitals": [`, len(s.agents))
+	query, err := parseStatusQuery(r.URL.Query())
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	status := s.statusSnapshot()
+	query.apply(&status, s.hospitals)
 
-	first := true
-	for hospitalCode, agent := range s.agents {
-		if !first {
-			fmt.Fprintf(w, ",")
-		}
-		fmt.Fprintf(w, `{
-			"code": "%s",
-			"subdomain": "%s",
-			"last_seen": "%s"
-		}`, hospitalCode, agent.Subdomain, agent.LastSeen.Format(time.RFC3339))
-		first = false
+	if err := writeJSON(w, http.StatusOK, status); err != nil {
+		s.logger.Debug("Failed to write status response", "error", err)
 	}
+}
 
-	fmt.Fprintf(w, `]
-	}`)
+// handleHealth answers liveness probes
+func (s *WebSocketServer) handleHealth(w http.ResponseWriter, r *http.Request) {
+	_ = writeJSON(w, http.StatusOK, healthResponse{Status: "ok", Version: Version})
 }
 
 // startMetricsServer starts a metrics/status server
 func (s *WebSocketServer) startMetricsServer(ctx context.Context) {
 	mux := http.NewServeMux()
 
-	mux.HandleFunc("/health", func(w http.ResponseWriter, r *http.Request) {
-		w.WriteHeader(http.StatusOK)
-		fmt.Fprintf(w, "OK")
-	})
+	mux.HandleFunc("/health", s.handleHealth)
+	mux.HandleFunc("/ready", readyHandler(s.startup, s.isRunning))
 
-	mux.HandleFunc("/status", s.handleStatus)
+	if s.config.Endpoints.EnableStatus {
+		mux.HandleFunc("/status", corsHandler(s.config.CORS, s.handleStatus))
+		mux.HandleFunc("/status/stream", corsHandler(s.config.CORS, statusStreamHandler(s.statusSnapshot, s.events, s.logger)))
+	}
+	if s.config.Endpoints.EnableMetrics {
+		mux.Handle("/metrics", promhttp.Handler())
+	}
+	if s.config.adminEnabled() {
+		mux.HandleFunc("/admin/tokens", adminTokenHandler(s.config, s.hospitals, s.logger))
+		mux.HandleFunc("/admin/disconnect", adminDisconnectHandler(s.config, s.disconnectHospital, s.logger))
+		mux.HandleFunc("/admin/maintenance", adminMaintenanceHandler(s.config, s.hospitals, s.maintenance, s.logger))
+		mux.HandleFunc("/admin/selftest", adminSelfTestHandler(s.config, s.hospitals, s.selfTest, s.logger))
+	}
+	if s.config.EnablePprof {
+		registerPprof(mux)
+		s.logger.Warn("Profiling endpoints enabled", "addr", s.config.MetricsAddr, "path", "/debug/pprof/")
+	}
 
 	server := &http.Server{
 		Addr:    s.config.MetricsAddr,
@@ -609,68 +1608,42 @@ func (s *WebSocketServer) startMetricsServer(ctx context.Context) {
 	server.Shutdown(shutdownCtx)
 }
 
-// Rate limiting functions (same as before)
+// Rate limiting functions. Store errors fail open: a Redis outage must not
+// lock every hospital out.
 
-func (s *WebSocketServer) isRateLimited(remoteAddr string) bool {
-	// Read-only check; mutations happen in recordFailedAttempt
-	s.attemptsMutex.RLock()
-	attempts, exists := s.failedAttempts[remoteAddr]
-	s.attemptsMutex.RUnlock()
-	if !exists {
+func (s *WebSocketServer) isRateLimited(ctx context.Context, remoteAddr string) bool {
+	if s.config.RateLimit.IsAllowed(remoteAddr) {
 		return false
 	}
-	return time.Now().Before(attempts.BlockedUntil)
-}
-
-func (s *WebSocketServer) recordFailedAttempt(remoteAddr string) {
-	s.attemptsMutex.Lock()
-	defer s.attemptsMutex.Unlock()
 
-	attempts, exists := s.failedAttempts[remoteAddr]
-	if !exists {
-		attempts = &authAttempts{}
-		s.failedAttempts[remoteAddr] = attempts
+	blocked, err := s.attempts.IsBlocked(ctx, remoteAddr)
+	if err != nil {
+		s.logger.Warn("Rate limit check failed", "remote_addr", remoteAddr, "error", err)
+		return false
 	}
+	return blocked
+}
 
-	attempts.Count++
-	attempts.LastAttempt = time.Now()
-
-	const maxAttempts = 100
-	const blockDuration = 5 * time.Minute
+func (s *WebSocketServer) recordFailedAttempt(ctx context.Context, remoteAddr string) {
+	if s.config.RateLimit.IsAllowed(remoteAddr) {
+		return
+	}
 
-	if attempts.Count >= maxAttempts {
-		attempts.BlockedUntil = time.Now().Add(blockDuration)
+	count, blocked, err := s.attempts.Record(ctx, remoteAddr)
+	if err != nil {
+		s.logger.Warn("Failed to record authentication failure", "remote_addr", remoteAddr, "error", err)
+		return
+	}
+	if blocked {
 		s.logger.Warn("IP blocked due to too many failed attempts",
-			"remote", remoteAddr,
-			"attempts", attempts.Count,
-			"blocked_until", attempts.BlockedUntil)
+			"remote_addr", remoteAddr,
+			"attempts", count,
+			"blocked_for", s.config.RateLimit.BlockDuration.ToDuration().String())
 	}
 }
 
-func (s *WebSocketServer) clearFailedAttempts(remoteAddr string) {
-	s.attemptsMutex.Lock()
-	defer s.attemptsMutex.Unlock()
-
-	delete(s.failedAttempts, remoteAddr)
-}
-
-func (s *WebSocketServer) cleanupFailedAttempts(ctx context.Context) {
-	ticker := time.NewTicker(1 * time.Hour)
-	defer ticker.Stop()
-
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			s.attemptsMutex.Lock()
-			now := time.Now()
-			for addr, attempts := range s.failedAttempts {
-				if now.Sub(attempts.LastAttempt) > 24*time.Hour {
-					delete(s.failedAttempts, addr)
-				}
-			}
-			s.attemptsMutex.Unlock()
-		}
+func (s *WebSocketServer) clearFailedAttempts(ctx context.Context, remoteAddr string) {
+	if err := s.attempts.Clear(ctx, remoteAddr); err != nil {
+		s.logger.Warn("Failed to clear authentication failures", "remote_addr", remoteAddr, "error", err)
 	}
-}
\ No newline at end of file
+}
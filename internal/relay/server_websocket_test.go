@@ -0,0 +1,99 @@
+package relay
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestForwardedRequestReframesChunkedBody(t *testing.T) {
+	s := &WebSocketServer{config: &Config{}}
+	forwarded := make(chan []byte, 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			t.Error(err)
+		}
+		var buf bytes.Buffer
+		s.writeForwardedRequest(&buf, r, body)
+		forwarded <- buf.Bytes()
+	}))
+	defer srv.Close()
+
+	conn, err := net.Dial("tcp", srv.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	_, err = io.WriteString(conn, "POST /upload HTTP/1.1\r\nHost: ankara.example.com\r\n"+
+		"Transfer-Encoding: chunked\r\n\r\n5\r\nhello\r\n6\r\n world\r\n0\r\n\r\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var raw []byte
+	select {
+	case raw = <-forwarded:
+	case <-time.After(5 * time.Second):
+		t.Fatal("request not forwarded")
+	}
+
+	var framing []string
+	for _, line := range strings.Split(string(raw), "\r\n") {
+		if line == "" {
+			break
+		}
+		name, _, _ := strings.Cut(strings.ToLower(line), ":")
+		if name == "content-length" || name == "transfer-encoding" {
+			framing = append(framing, line)
+		}
+	}
+	if len(framing) != 1 || framing[0] != "Content-Length: 11" {
+		t.Fatalf("framing headers %q, want a single Content-Length: 11", framing)
+	}
+
+	req, err := http.ReadRequest(bufio.NewReader(bytes.NewReader(raw)))
+	if err != nil {
+		t.Fatalf("agent cannot parse the forwarded request: %v", err)
+	}
+	body, _ := io.ReadAll(req.Body)
+	if string(body) != "hello world" {
+		t.Errorf("body %q, want %q", body, "hello world")
+	}
+}
+
+func TestSilentTunnelClientDropped(t *testing.T) {
+	cfg := &Config{RegistrationTimeout: Duration(200 * time.Millisecond)}
+	s := NewWebSocketServer(cfg, slog.New(slog.NewTextHandler(io.Discard, nil)))
+	s.running = true
+	srv := httptest.NewServer(http.HandlerFunc(s.handleTunnelConnection))
+	defer srv.Close()
+
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(srv.URL, "http")+"/tunnel", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	// Never send REGISTER
+	start := time.Now()
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	_, _, err = conn.ReadMessage()
+	var closeErr *websocket.CloseError
+	if !errors.As(err, &closeErr) || closeErr.Code != websocket.ClosePolicyViolation {
+		t.Fatalf("got %v, want a policy violation close", err)
+	}
+	if elapsed := time.Since(start); elapsed > 2*time.Second {
+		t.Errorf("dropped after %s, want about the registration timeout", elapsed)
+	}
+}
@@ -0,0 +1,185 @@
+package relay
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// StatusResponse is the JSON document served by /status
+type StatusResponse struct {
+	Version            string           `json:"version"`
+	ConnectedHospitals int              `json:"connected_hospitals"`
+	MaxHospitals       int              `json:"max_hospitals"` // 0 is unlimited
+	InFlightRequests   int64            `json:"in_flight_requests"`
+	MaxConcurrent      int              `json:"max_concurrent_requests"`
+	Total              int              `json:"total"` // Hospitals matching the /status query, before limit and offset
+	Hospitals          []HospitalStatus `json:"hospitals"`
+}
+
+// healthResponse is the JSON document served by /health
+type healthResponse struct {
+	Status         string `json:"status"`
+	Version        string `json:"version"`
+	ConnectedEdges *int   `json:"connected_edges,omitempty"` // gRPC mode
+}
+
+// HospitalStatus describes one connected hospital in /status
+type HospitalStatus struct {
+	Code            string     `json:"code"`
+	Subdomain       string     `json:"subdomain"`
+	Connected       bool       `json:"connected"`
+	HospitalID      string     `json:"hospital_id,omitempty"`    // gRPC mode
+	EdgeServerID    string     `json:"edge_server_id,omitempty"` // gRPC mode
+	ConnectedAt     *time.Time `json:"connected_at,omitempty"`
+	UptimeSeconds   int64      `json:"uptime_seconds"` // Time since the current connection was established
+	Reconnects      int64      `json:"reconnects"`     // Registrations after the first since the relay started
+	LastSeen        time.Time  `json:"last_seen"`
+	PendingRequests int        `json:"pending_requests"` // Requests awaiting a response; stuck transfers show up here
+	Requests        int64      `json:"requests"`
+	Failures        int64      `json:"failures"`
+	LastError       string     `json:"last_error,omitempty"`
+	LastErrorAt     *time.Time `json:"last_error_at,omitempty"`
+	CircuitBreaker  string     `json:"circuit_breaker,omitempty"` // closed, open or half_open; omitted when disabled
+}
+
+// forwardStats counts the requests forwarded to one connected hospital.
+// It is updated lock-free from the forward paths.
+type forwardStats struct {
+	requests  atomic.Int64
+	failures  atomic.Int64
+	lastError atomic.Pointer[forwardError]
+}
+
+// forwardError is the most recent forwarding failure of a hospital
+type forwardError struct {
+	at      time.Time
+	message string
+}
+
+// record counts one forwarded request and remembers err if it failed
+func (f *forwardStats) record(err error) {
+	f.requests.Add(1)
+	if err != nil {
+		f.failures.Add(1)
+		f.lastError.Store(&forwardError{at: time.Now(), message: err.Error()})
+	}
+}
+
+// fill copies the counters into a /status entry
+func (f *forwardStats) fill(hs *HospitalStatus) {
+	hs.Requests = f.requests.Load()
+	hs.Failures = f.failures.Load()
+	if last := f.lastError.Load(); last != nil {
+		at := last.at
+		hs.LastError = last.message
+		hs.LastErrorAt = &at
+	}
+}
+
+// reconnectCounter counts registrations per hospital across connections, so
+// the count survives the connection it was recorded on
+type reconnectCounter struct {
+	mu    sync.Mutex
+	count map[string]int64 // key -> registrations seen, including the first
+}
+
+func newReconnectCounter() *reconnectCounter {
+	return &reconnectCounter{count: make(map[string]int64)}
+}
+
+// registered records a registration for key
+func (c *reconnectCounter) registered(key string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.count[key]++
+}
+
+// reconnects returns how often key registered again after its first registration
+func (c *reconnectCounter) reconnects(key string) int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return max(0, c.count[key]-1)
+}
+
+// fillConnection sets the connection age fields of a /status entry
+func fillConnection(hs *HospitalStatus, connected time.Time, reconnects int64) {
+	hs.Connected = true
+	hs.ConnectedAt = &connected
+	hs.UptimeSeconds = int64(time.Since(connected).Seconds())
+	hs.Reconnects = reconnects
+}
+
+// writeJSON marshals v as the response body with the given status code
+func writeJSON(w http.ResponseWriter, status int, v any) error {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	return json.NewEncoder(w).Encode(v)
+}
+
+// statusStreamKeepAlive is how often an idle /status/stream sends a comment
+// so proxies do not close it
+const statusStreamKeepAlive = 30 * time.Second
+
+// statusStreamHandler serves /status/stream as server-sent events: a
+// "snapshot" event with the current status, then a "connected" or
+// "disconnected" event (an Event) whenever the set of hospitals changes.
+func statusStreamHandler(snapshot func() StatusResponse, hub *eventHub, logger *slog.Logger) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		flusher, ok := w.(http.Flusher)
+		if !ok {
+			http.Error(w, "Streaming not supported", http.StatusInternalServerError)
+			return
+		}
+
+		// Subscribe first so no change between snapshot and stream is lost
+		events, unsubscribe := hub.subscribe()
+		defer unsubscribe()
+
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.Header().Set("Cache-Control", "no-cache")
+		w.WriteHeader(http.StatusOK)
+
+		if err := writeSSE(w, "snapshot", snapshot()); err != nil {
+			return
+		}
+		flusher.Flush()
+
+		keepAlive := time.NewTicker(statusStreamKeepAlive)
+		defer keepAlive.Stop()
+		for {
+			var err error
+			select {
+			case <-r.Context().Done():
+				return
+			case e, ok := <-events:
+				if !ok {
+					return
+				}
+				err = writeSSE(w, e.Event, e)
+			case <-keepAlive.C:
+				_, err = io.WriteString(w, ": keep-alive\n\n")
+			}
+			if err != nil {
+				logger.Debug("Status stream client gone", "error", err)
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
+
+// writeSSE writes one server-sent event with a JSON payload
+func writeSSE(w io.Writer, event string, v any) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "event: %s\ndata: %s\n\n", event, data)
+	return err
+}
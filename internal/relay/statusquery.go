@@ -0,0 +1,89 @@
+package relay
+
+import (
+	"cmp"
+	"fmt"
+	"net/url"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+// statusQuery filters and pages the hospitals listed by /status:
+//
+//	?connected=false   configured hospitals without a connection instead of connected ones
+//	?code=ank          hospitals whose code starts with the prefix, ignoring case
+//	?limit=50&offset=100
+//
+// Entries are sorted by code (and edge server in gRPC mode), so pages are stable.
+type statusQuery struct {
+	disconnected bool
+	codePrefix   string // lowercase
+	limit        int    // 0 is unlimited
+	offset       int
+}
+
+// parseStatusQuery reads the /status query parameters
+func parseStatusQuery(q url.Values) (statusQuery, error) {
+	var query statusQuery
+	if v := q.Get("connected"); v != "" {
+		connected, err := strconv.ParseBool(v)
+		if err != nil {
+			return statusQuery{}, fmt.Errorf("connected must be true or false, got %q", v)
+		}
+		query.disconnected = !connected
+	}
+	query.codePrefix = strings.ToLower(q.Get("code"))
+	for _, p := range []struct {
+		name string
+		dst  *int
+	}{{"limit", &query.limit}, {"offset", &query.offset}} {
+		v := q.Get(p.name)
+		if v == "" {
+			continue
+		}
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 0 {
+			return statusQuery{}, fmt.Errorf("%s must be a non-negative integer, got %q", p.name, v)
+		}
+		*p.dst = n
+	}
+	return query, nil
+}
+
+// apply replaces status.Hospitals with the requested page and sets Total to
+// the number of matching entries. Disconnected hospitals are looked up in
+// hospitals.
+func (q statusQuery) apply(status *StatusResponse, hospitals *hospitalRegistry) {
+	entries := status.Hospitals
+	if q.disconnected {
+		connected := make(map[string]bool, len(entries))
+		for _, hs := range entries {
+			connected[hs.Code] = true
+		}
+		entries = nil
+		for _, h := range hospitals.all() {
+			if !connected[h.Code] {
+				entries = append(entries, HospitalStatus{Code: h.Code, Subdomain: h.Subdomain, HospitalID: h.HospitalID})
+			}
+		}
+	}
+
+	matching := make([]HospitalStatus, 0, len(entries))
+	for _, hs := range entries {
+		if strings.HasPrefix(strings.ToLower(hs.Code), q.codePrefix) {
+			matching = append(matching, hs)
+		}
+	}
+	slices.SortFunc(matching, func(a, b HospitalStatus) int {
+		return cmp.Or(cmp.Compare(a.Code, b.Code), cmp.Compare(a.EdgeServerID, b.EdgeServerID))
+	})
+
+	status.Total = len(matching)
+	start := min(q.offset, len(matching))
+	end := len(matching)
+	if q.limit > 0 {
+		end = min(start+q.limit, end)
+	}
+	status.Hospitals = matching[start:end]
+}
@@ -0,0 +1,36 @@
+package relay
+
+import (
+	"net/url"
+	"slices"
+	"testing"
+)
+
+func TestStatusQueryCodePrefix(t *testing.T) {
+	tests := []struct {
+		code string
+		want []string
+	}{
+		{"", []string{"DEMO_SAMSUN", "ankara", "demo_izmir"}},
+		{"demo", []string{"DEMO_SAMSUN", "demo_izmir"}},
+		{"DEMO", []string{"DEMO_SAMSUN", "demo_izmir"}},
+		{"Ank", []string{"ankara"}},
+		{"x", nil},
+	}
+	for _, tt := range tests {
+		query, err := parseStatusQuery(url.Values{"code": {tt.code}})
+		if err != nil {
+			t.Fatal(err)
+		}
+		status := StatusResponse{Hospitals: []HospitalStatus{{Code: "demo_izmir"}, {Code: "ankara"}, {Code: "DEMO_SAMSUN"}}}
+		query.apply(&status, nil)
+
+		var got []string
+		for _, hs := range status.Hospitals {
+			got = append(got, hs.Code)
+		}
+		if !slices.Equal(got, tt.want) || status.Total != len(tt.want) {
+			t.Errorf("code=%q: got %q (total %d), want %q", tt.code, got, status.Total, tt.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package relay
+
+import (
+	"regexp"
+	"strings"
+)
+
+// matchApexDomain returns the configured domain host belongs to (the apex
+// itself or a subdomain of it), preferring the longest match, or "".
+// host must be lowercase without a port.
+func matchApexDomain(host string, domains []string) string {
+	match := ""
+	for _, d := range domains {
+		if (host == d || strings.HasSuffix(host, "."+d)) && len(d) > len(match) {
+			match = d
+		}
+	}
+	return match
+}
+
+// hospitalCodeFromHost extracts the hospital code from a request host.
+//
+// The host must be a subdomain of one of domains. With no pattern, a multi-level
+// subdomain such as "viewer.ankara" resolves to its last label ("ankara") so
+// optional service-label prefixes are tolerated. With a pattern, the first
+// capture group (or the group named "code") of a match against the subdomain
+// part is the hospital code. Returns "" for an apex domain or foreign hosts.
+func hospitalCodeFromHost(host string, domains []string, pattern *regexp.Regexp) string {
+	// Normalize to lowercase for case-insensitive host matching
+	host = strings.ToLower(host)
+
+	// Remove port if present
+	if colonIndex := strings.Index(host, ":"); colonIndex != -1 {
+		host = host[:colonIndex]
+	}
+
+	// Check if it's a subdomain of one of our domains
+	domain := matchApexDomain(host, domains)
+	if domain == "" || host == domain {
+		return ""
+	}
+	subdomain := strings.TrimSuffix(host, "."+domain)
+
+	if pattern != nil {
+		m := pattern.FindStringSubmatch(subdomain)
+		if m == nil {
+			return ""
+		}
+		if i := pattern.SubexpIndex("code"); i > 0 {
+			return m[i]
+		}
+		if len(m) > 1 {
+			return m[1]
+		}
+		return m[0]
+	}
+
+	// viewer.ankara.zenpacs.com.tr -> ankara
+	if dot := strings.LastIndex(subdomain, "."); dot != -1 {
+		return subdomain[dot+1:]
+	}
+	return subdomain
+}
+
+// hospitalCodePattern is what an agent may register as its hospital code;
+// codes become map keys, metric labels and DNS labels
+var hospitalCodePattern = regexp.MustCompile(`^[a-z0-9-]{1,63}$`)
+
+// validHospitalCode reports whether code is a safe hospital code
+func validHospitalCode(code string) bool {
+	return hospitalCodePattern.MatchString(code)
+}
+
+// validDNSName reports whether name is a well-formed lowercase DNS name:
+// dot-separated labels of letters, digits and inner hyphens, each at most
+// 63 bytes, 253 bytes in total
+func validDNSName(name string) bool {
+	if name == "" || len(name) > 253 {
+		return false
+	}
+	for _, label := range strings.Split(name, ".") {
+		if len(label) == 0 || len(label) > 63 || label[0] == '-' || label[len(label)-1] == '-' {
+			return false
+		}
+		for i := 0; i < len(label); i++ {
+			c := label[i]
+			if (c < 'a' || c > 'z') && (c < '0' || c > '9') && c != '-' {
+				return false
+			}
+		}
+	}
+	return true
+}
@@ -0,0 +1,38 @@
+package relay
+
+import (
+	"regexp"
+	"testing"
+)
+
+func TestHospitalCodeFromHost(t *testing.T) {
+	domains := []string{"zenpacs.com.tr", "eu.zenpacs.com.tr"}
+	pattern := regexp.MustCompile(`^pacs-(?P<code>[a-z0-9-]+)$`)
+
+	tests := []struct {
+		name    string
+		host    string
+		pattern *regexp.Regexp
+		want    string
+	}{
+		{"apex", "zenpacs.com.tr", nil, ""},
+		{"apex with port", "zenpacs.com.tr:443", nil, ""},
+		{"single level", "ankara.zenpacs.com.tr", nil, "ankara"},
+		{"single level with port", "Ankara.ZenPACS.com.tr:8443", nil, "ankara"},
+		{"multi level", "viewer.ankara.zenpacs.com.tr", nil, "ankara"},
+		{"longest domain wins", "izmir.eu.zenpacs.com.tr", nil, "izmir"},
+		{"nested apex", "eu.zenpacs.com.tr", nil, ""},
+		{"foreign host", "ankara.example.com", nil, ""},
+		{"suffix without dot", "ankarazenpacs.com.tr", nil, ""},
+		{"pattern match", "pacs-samsun.zenpacs.com.tr", pattern, "samsun"},
+		{"pattern mismatch", "samsun.zenpacs.com.tr", pattern, ""},
+		{"pattern on apex", "zenpacs.com.tr", pattern, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hospitalCodeFromHost(tt.host, domains, tt.pattern); got != tt.want {
+				t.Errorf("hospitalCodeFromHost(%q) = %q, want %q", tt.host, got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,73 @@
+package relay
+
+import (
+	"crypto/tls"
+	"fmt"
+	"slices"
+)
+
+// tlsVersions are the accepted TLSConfig.MinVersion values
+var tlsVersions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// http2CipherSuites are the TLS 1.2 suites HTTP/2 requires one of (RFC 7540 section 9.2.2)
+var http2CipherSuites = []uint16{
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256,
+}
+
+// minVersion returns the lowest TLS version the relay's listeners accept
+func (t *TLSConfig) minVersion() uint16 {
+	if v, ok := tlsVersions[t.MinVersion]; ok {
+		return v
+	}
+	return tls.VersionTLS12
+}
+
+// cipherSuites returns the configured TLS 1.2 cipher suites, or nil for Go's
+// defaults. TLS 1.3 suites are not configurable in Go.
+func (t *TLSConfig) cipherSuites() []uint16 {
+	var ids []uint16
+	for _, name := range t.CipherSuites {
+		if id, ok := secureCipherSuite(name); ok {
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// secureCipherSuite looks up a cipher suite by name among those Go considers
+// secure
+func secureCipherSuite(name string) (uint16, bool) {
+	for _, suite := range tls.CipherSuites() {
+		if suite.Name == name {
+			return suite.ID, slices.Contains(suite.SupportedVersions, tls.VersionTLS12)
+		}
+	}
+	return 0, false
+}
+
+// validatePolicy checks min_version and cipher_suites
+func (t *TLSConfig) validatePolicy() []string {
+	var problems []string
+	if _, ok := tlsVersions[t.MinVersion]; t.MinVersion != "" && !ok {
+		problems = append(problems, fmt.Sprintf("tls.min_version must be \"1.2\" or \"1.3\", got %q", t.MinVersion))
+	}
+	if len(t.CipherSuites) == 0 {
+		return problems
+	}
+	if t.minVersion() == tls.VersionTLS13 {
+		problems = append(problems, "tls.cipher_suites cannot be set with tls.min_version \"1.3\"; TLS 1.3 cipher suites are not configurable")
+	}
+	for _, name := range t.CipherSuites {
+		if _, ok := secureCipherSuite(name); !ok {
+			problems = append(problems, fmt.Sprintf("tls.cipher_suites entry %q is not a secure TLS 1.2 cipher suite", name))
+		}
+	}
+	if !slices.ContainsFunc(t.cipherSuites(), func(id uint16) bool { return slices.Contains(http2CipherSuites, id) }) {
+		problems = append(problems, "tls.cipher_suites must include TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256, which HTTP/2 requires")
+	}
+	return problems
+}
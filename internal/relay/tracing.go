@@ -0,0 +1,93 @@
+package relay
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracegrpc"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.26.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer creates the relay's spans. It goes through the global provider, so
+// it is a no-op until SetupTracing installs an exporter.
+var tracer = otel.Tracer("github.com/minasoft-technology/gordion-relay/internal/relay")
+
+// Span attribute keys shared by both server modes
+const (
+	attrHospitalCode = attribute.Key("relay.hospital_code")
+	attrRequestSize  = attribute.Key("relay.request_size")
+)
+
+// SetupTracing installs an OTLP trace exporter when cfg names an endpoint.
+// Without one, spans are not recorded. The returned function flushes and
+// stops the exporter.
+func SetupTracing(ctx context.Context, cfg *TracingConfig) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(propagation.TraceContext{}, propagation.Baggage{}))
+
+	if cfg == nil || cfg.OTLPEndpoint == "" {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	opts := []otlptracegrpc.Option{otlptracegrpc.WithEndpoint(cfg.OTLPEndpoint)}
+	if cfg.Insecure {
+		opts = append(opts, otlptracegrpc.WithInsecure())
+	}
+	exporter, err := otlptracegrpc.New(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OTLP exporter: %w", err)
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(cfg.SampleRatio))),
+		sdktrace.WithResource(resource.NewSchemaless(semconv.ServiceName(cfg.ServiceName))),
+	)
+	otel.SetTracerProvider(provider)
+	return provider.Shutdown, nil
+}
+
+// startRequestSpan starts the server span for a viewer request, continuing
+// any trace the viewer propagated
+func startRequestSpan(r *http.Request, name string) (context.Context, trace.Span) {
+	ctx := otel.GetTextMapPropagator().Extract(r.Context(), propagation.HeaderCarrier(r.Header))
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindServer),
+		trace.WithAttributes(
+			semconv.HTTPRequestMethodKey.String(r.Method),
+			semconv.URLPath(r.URL.Path),
+		))
+}
+
+// endRequestSpan records the response status and outcome and ends span
+func endRequestSpan(span trace.Span, hospitalCode string, status int, outcome string) {
+	span.SetAttributes(
+		attrHospitalCode.String(hospitalCode),
+		semconv.HTTPResponseStatusCode(status),
+	)
+	if outcome != outcomeOK {
+		span.SetStatus(codes.Error, outcome)
+	}
+	span.End()
+}
+
+// endSpan records err, if any, and ends span
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// injectTraceContext adds the current trace context from ctx to carrier
+func injectTraceContext(ctx context.Context, carrier propagation.TextMapCarrier) {
+	otel.GetTextMapPropagator().Inject(ctx, carrier)
+}
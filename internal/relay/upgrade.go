@@ -0,0 +1,125 @@
+package relay
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"log/slog"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// WebSocket passthrough (tunnel protocol v2 only).
+//
+// An incoming request carrying "Connection: Upgrade" and "Upgrade: websocket"
+// is forwarded like any other request on a fresh request ID. If the agent
+// answers with 101 Switching Protocols, the stream stays open after the
+// response head: every later frame with that ID, in either direction, carries
+// raw bytes of the upgraded connection, and an empty frame from either side
+// closes it. Any other status is relayed as a normal response.
+
+// upgradeBufferSize is the largest client read sent to the agent in one frame
+const upgradeBufferSize = 32 * 1024
+
+// isWebSocketUpgrade reports whether r asks to switch to the WebSocket protocol
+func isWebSocketUpgrade(r *http.Request) bool {
+	if !strings.EqualFold(r.Header.Get("Upgrade"), "websocket") {
+		return false
+	}
+	for _, value := range r.Header.Values("Connection") {
+		for _, token := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(token), "upgrade") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bridgeUpgrade takes over the client connection after the agent accepted an
+// upgrade and copies bytes both ways until either side closes
+func (s *WebSocketServer) bridgeUpgrade(w http.ResponseWriter, resp *http.Response, agent *WSAgentConnection, id uint64, st *wsStream, logger *slog.Logger) error {
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return fmt.Errorf("failed to hijack client connection: %w", err)
+	}
+	defer conn.Close()
+
+	// The upgraded connection lives as long as both ends want it
+	_ = conn.SetDeadline(time.Time{})
+
+	var head bytes.Buffer
+	fmt.Fprintf(&head, "HTTP/1.1 %s\r\n", resp.Status)
+	resp.Header.Write(&head)
+	head.WriteString("\r\n")
+	if _, err := conn.Write(head.Bytes()); err != nil {
+		return nil // client gone before the switch completed
+	}
+
+	logger.Debug("WebSocket passthrough established", "hospital_code", agent.HospitalCode, "stream_id", id)
+
+	// client -> agent; bytes the server already buffered are read first
+	clientDone := make(chan struct{})
+	go func() {
+		defer close(clientDone)
+		bp := getCopyBuffer(upgradeBufferSize)
+		defer putCopyBuffer(bp)
+		buf := *bp
+		for {
+			n, err := brw.Reader.Read(buf)
+			if n > 0 {
+				if werr := s.writeToAgent(agent, encodeWSFrame(id, buf[:n]), time.Duration(s.config.RequestTimeout)); werr != nil {
+					return
+				}
+			}
+			if err != nil {
+				if err != io.EOF {
+					logger.Debug("WebSocket passthrough client read ended", "stream_id", id, "error", err)
+				}
+				// Tell the agent the client side is closed
+				_ = s.writeToAgent(agent, encodeWSFrame(id, nil), time.Duration(s.config.RequestTimeout))
+				return
+			}
+		}
+	}()
+
+	// agent -> client
+	for {
+		select {
+		case data, ok := <-st.ch:
+			if !ok || len(data) == 0 {
+				// Agent closed the stream or disconnected
+				conn.Close()
+				<-clientDone
+				return nil
+			}
+			if _, err := conn.Write(data); err != nil {
+				conn.Close()
+				<-clientDone
+				return nil
+			}
+		case <-clientDone:
+			return nil
+		}
+	}
+}
+
+// checkTunnelOrigin reports whether a /tunnel upgrade may proceed. Requests
+// without an Origin header (non-browser agents) and any origin when allowed
+// is empty are accepted; otherwise the origin must match an entry exactly
+// (case-insensitive).
+func checkTunnelOrigin(allowed []string, r *http.Request, logger *slog.Logger) bool {
+	origin := r.Header.Get("Origin")
+	if origin == "" || len(allowed) == 0 {
+		return true
+	}
+	for _, a := range allowed {
+		if strings.EqualFold(strings.TrimSuffix(a, "/"), origin) {
+			logger.Debug("Tunnel origin allowed", "origin", origin, "remote_addr", r.RemoteAddr)
+			return true
+		}
+	}
+	logger.Debug("Tunnel origin rejected", "origin", origin, "remote_addr", r.RemoteAddr)
+	return false
+}
@@ -0,0 +1,79 @@
+package relay
+
+import (
+	"errors"
+	"net/http"
+)
+
+// Errors from the tunnel forwarder, classified for the client response
+var (
+	// errAgentDisconnected is returned when the agent goes away mid-request
+	errAgentDisconnected = errors.New("agent disconnected")
+
+	// errStreamOpen is returned when the request cannot be written to the agent
+	errStreamOpen = errors.New("failed to write request")
+
+	// errBadFraming is returned when the agent's response cannot be parsed
+	errBadFraming = errors.New("bad response framing")
+
+	// errResponseTooLarge is returned when a response body exceeds MaxResponseBodyBytes
+	errResponseTooLarge = errors.New("response body too large")
+)
+
+// Machine-readable upstream error codes (ExposeUpstreamErrors)
+const (
+	upstreamNotConnected = "agent_not_connected"
+	upstreamStreamOpen   = "stream_open_failed"
+	upstreamTimeout      = "upstream_timeout"
+	upstreamBadFraming   = "bad_response_framing"
+	upstreamError        = "upstream_error"
+	upstreamCircuitOpen  = "circuit_open"
+	upstreamAgentBusy    = "agent_busy"
+)
+
+// upstreamMessages are the only details sent to clients. They are fixed
+// strings so tokens, addresses and agent error text never leak.
+var upstreamMessages = map[string]string{
+	upstreamNotConnected: "hospital agent is not connected",
+	upstreamStreamOpen:   "failed to send request to hospital agent",
+	upstreamTimeout:      "hospital agent did not respond in time",
+	upstreamBadFraming:   "hospital agent sent a malformed response",
+	upstreamError:        "request to hospital agent failed",
+	upstreamCircuitOpen:  "hospital requests are failing, try again later",
+	upstreamAgentBusy:    "hospital agent is busy, try again later",
+}
+
+// upstreamErrorBody is the JSON body of a detailed upstream error
+type upstreamErrorBody struct {
+	Error   string `json:"error"`
+	Message string `json:"message"`
+}
+
+// classifyUpstreamError maps a forwarding error to an upstream error code and
+// the status to answer with
+func classifyUpstreamError(err error) (code string, status int) {
+	switch {
+	case errors.Is(err, errQueueFull):
+		return upstreamAgentBusy, http.StatusServiceUnavailable
+	case errors.Is(err, errTunnelTimeout), errors.Is(err, errQueueTimeout):
+		return upstreamTimeout, http.StatusGatewayTimeout
+	case errors.Is(err, errAgentDisconnected):
+		return upstreamNotConnected, http.StatusBadGateway
+	case errors.Is(err, errStreamOpen):
+		return upstreamStreamOpen, http.StatusBadGateway
+	case errors.Is(err, errBadFraming):
+		return upstreamBadFraming, http.StatusBadGateway
+	default:
+		return upstreamError, http.StatusBadGateway
+	}
+}
+
+// writeUpstreamError answers with a JSON error body when expose is set, and
+// with the plain generic message otherwise
+func writeUpstreamError(w http.ResponseWriter, expose bool, status int, code, generic string) {
+	if !expose {
+		http.Error(w, generic, status)
+		return
+	}
+	_ = writeJSON(w, status, upstreamErrorBody{Error: code, Message: upstreamMessages[code]})
+}
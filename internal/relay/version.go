@@ -0,0 +1,11 @@
+package relay
+
+// Version is the relay's build version, reported in /status, /health and
+// the X-Relay-Version header so rollouts can tell instances apart. Release
+// builds set it with
+//
+//	go build -ldflags "-X github.com/minasoft-technology/gordion-relay/internal/relay.Version=v1.4.0"
+var Version = "dev"
+
+// versionHeader carries Version on responses to viewers
+const versionHeader = "X-Relay-Version"
@@ -1,13 +1,19 @@
 package timetoken
 
 import (
+	"context"
 	"crypto/aes"
 	"crypto/cipher"
+	"crypto/hmac"
 	"crypto/rand"
 	"crypto/sha256"
 	"encoding/base64"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/uuid"
@@ -15,20 +21,174 @@ import (
 
 // TokenPayload represents the data stored in the time-limited token
 type TokenPayload struct {
-	Exp  int64  `json:"exp"`  // Expiration timestamp (Unix)
-	Path string `json:"path"` // Resource path being protected
-	Iat  int64  `json:"iat"`  // Issued at timestamp (Unix)
-	Jti  string `json:"jti"`  // Unique token ID for replay protection
+	Exp  int64  `json:"exp"`          // Expiration timestamp (Unix)
+	Path string `json:"path"`         // Resource path being protected
+	Iat  int64  `json:"iat"`          // Issued at timestamp (Unix)
+	Jti  string `json:"jti"`          // Unique token ID for replay protection
+	IP   string `json:"ip,omitempty"` // Client IP the token is bound to (empty = any client)
 }
 
-// GenerateToken creates a time-limited encrypted token for the given path
+// TokenScheme selects how a token protects its payload
+type TokenScheme string
+
+const (
+	// SchemeAESGCM encrypts the payload; tokens are opaque without the key (default)
+	SchemeAESGCM TokenScheme = "aes-gcm"
+
+	// SchemeHMAC signs a readable payload as "payload.signature" (base64url,
+	// HMAC-SHA256). Tamper-proof but not confidential, so only use it where
+	// the path and expiry may be visible.
+	SchemeHMAC TokenScheme = "hmac"
+)
+
+// hmacSeparator splits payload and signature in SchemeHMAC tokens. It never
+// occurs in base64url output, which is how ValidateToken tells the schemes apart.
+const hmacSeparator = "."
+
+// Validation failures callers may want to tell apart, e.g. for auditing
+var (
+	ErrTokenInvalid      = errors.New("invalid token") // undecodable, undecryptable or badly signed
+	ErrTokenExpired      = errors.New("token has expired")
+	ErrTokenNotYetValid  = errors.New("token issued in the future")
+	ErrTokenPathMismatch = errors.New("token path mismatch")
+)
+
+// ErrTokenReplayed is returned when a single-use token is presented again
+var ErrTokenReplayed = errors.New("token has already been used")
+
+// ErrTokenIPMismatch is returned when an IP-bound token is used from another network
+var ErrTokenIPMismatch = errors.New("token is not valid for this client IP")
+
+// IP-bound tokens accept any address in the same network, so clients behind
+// NAT pools or with IPv6 privacy addresses keep working
+const (
+	ipv4BindPrefix = 24
+	ipv6BindPrefix = 64
+)
+
+// ReplayStore records token IDs (Jti) that have already been accepted
+type ReplayStore interface {
+	// MarkUsed records jti until expiresAt and reports whether it was previously unused
+	MarkUsed(jti string, expiresAt time.Time) bool
+}
+
+// Option customizes token validation
+type Option func(*validateOptions)
+
+type validateOptions struct {
+	replayStore ReplayStore
+	clientIP    string
+	checkIP     bool
+	skew        time.Duration
+}
+
+// DefaultSkewTolerance is how far the issuer's and validator's clocks may
+// disagree before a token is rejected, unless WithSkewTolerance overrides it
+const DefaultSkewTolerance = 30 * time.Second
+
+// WithSkewTolerance sets the allowed clock skew for the expiry and issued-at
+// checks. Zero makes validation strict.
+func WithSkewTolerance(skew time.Duration) Option {
+	return func(o *validateOptions) {
+		o.skew = skew
+	}
+}
+
+// WithReplayStore makes the token single-use by recording its Jti in store.
+// Leave it out for idempotent GETs that may legitimately be retried.
+func WithReplayStore(store ReplayStore) Option {
+	return func(o *validateOptions) {
+		o.replayStore = store
+	}
+}
+
+// WithClientIP enforces IP binding: a token carrying an IP is only accepted
+// from an address in the same /24 (IPv4) or /64 (IPv6). Tokens without an IP
+// are accepted from anywhere.
+func WithClientIP(ip string) Option {
+	return func(o *validateOptions) {
+		o.clientIP = ip
+		o.checkIP = true
+	}
+}
+
+// MemoryReplayStore is an in-memory ReplayStore backed by sync.Map
+type MemoryReplayStore struct {
+	entries sync.Map // jti -> expiry (time.Time)
+}
+
+// NewMemoryReplayStore creates an empty in-memory replay store
+func NewMemoryReplayStore() *MemoryReplayStore {
+	return &MemoryReplayStore{}
+}
+
+// MarkUsed implements ReplayStore
+func (m *MemoryReplayStore) MarkUsed(jti string, expiresAt time.Time) bool {
+	if prev, loaded := m.entries.LoadOrStore(jti, expiresAt); loaded {
+		// An expired entry that hasn't been swept yet no longer blocks reuse
+		if time.Now().After(prev.(time.Time)) && m.entries.CompareAndSwap(jti, prev, expiresAt) {
+			return true
+		}
+		return false
+	}
+	return true
+}
+
+// Cleanup removes entries whose tokens have expired
+func (m *MemoryReplayStore) Cleanup() {
+	now := time.Now()
+	m.entries.Range(func(key, value any) bool {
+		if now.After(value.(time.Time)) {
+			m.entries.CompareAndDelete(key, value)
+		}
+		return true
+	})
+}
+
+// Run periodically cleans up expired entries until ctx is cancelled
+func (m *MemoryReplayStore) Run(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			m.Cleanup()
+		}
+	}
+}
+
+// GenerateToken creates a time-limited encrypted token for the given path.
+// During key rotation apiKey must be the current (newest) key.
 func GenerateToken(apiKey, path string, duration time.Duration) (string, error) {
+	return GenerateTokenWithScheme(SchemeAESGCM, apiKey, path, duration)
+}
+
+// GenerateTokenWithScheme creates a time-limited token for the given path
+// using the given scheme. ValidateToken accepts tokens of either scheme.
+func GenerateTokenWithScheme(scheme TokenScheme, apiKey, path string, duration time.Duration) (string, error) {
+	return generateToken(scheme, apiKey, path, "", duration)
+}
+
+// GenerateTokenForIP creates a token that is only valid when presented from
+// clientIP's network (see WithClientIP). An empty clientIP binds nothing.
+func GenerateTokenForIP(scheme TokenScheme, apiKey, path, clientIP string, duration time.Duration) (string, error) {
+	if clientIP != "" && net.ParseIP(clientIP) == nil {
+		return "", fmt.Errorf("invalid client IP %q", clientIP)
+	}
+	return generateToken(scheme, apiKey, path, clientIP, duration)
+}
+
+func generateToken(scheme TokenScheme, apiKey, path, clientIP string, duration time.Duration) (string, error) {
 	now := time.Now().Unix()
 	payload := TokenPayload{
 		Exp:  now + int64(duration.Seconds()),
 		Path: path,
 		Iat:  now,
 		Jti:  uuid.New().String(),
+		IP:   clientIP,
 	}
 
 	// Marshal payload to JSON
@@ -37,50 +197,164 @@ func GenerateToken(apiKey, path string, duration time.Duration) (string, error)
 		return "", fmt.Errorf("failed to marshal token payload: %w", err)
 	}
 
-	// Encrypt the payload using AES-GCM
-	encryptedToken, err := encryptAESGCM(payloadBytes, apiKey)
-	if err != nil {
-		return "", fmt.Errorf("failed to encrypt token: %w", err)
+	switch scheme {
+	case SchemeAESGCM:
+		// Encrypt the payload using AES-GCM
+		encryptedToken, err := encryptAESGCM(payloadBytes, apiKey)
+		if err != nil {
+			return "", fmt.Errorf("failed to encrypt token: %w", err)
+		}
+
+		// Base64 URL encode for safe use in URLs
+		return base64.URLEncoding.EncodeToString(encryptedToken), nil
+	case SchemeHMAC:
+		encodedPayload := base64.RawURLEncoding.EncodeToString(payloadBytes)
+		signature := base64.RawURLEncoding.EncodeToString(signHMAC(encodedPayload, apiKey))
+		return encodedPayload + hmacSeparator + signature, nil
+	default:
+		return "", fmt.Errorf("unknown token scheme %q", scheme)
 	}
+}
 
-	// Base64 URL encode for safe use in URLs
-	token := base64.URLEncoding.EncodeToString(encryptedToken)
-	return token, nil
+// ValidateToken decrypts or verifies and validates a time-limited token
+func ValidateToken(apiKey, token, requestedPath string, opts ...Option) error {
+	return ValidateTokenWithKeys([]string{apiKey}, token, requestedPath, opts...)
 }
 
-// ValidateToken decrypts and validates a time-limited token
-func ValidateToken(apiKey, token, requestedPath string) error {
-	// Base64 URL decode
-	encryptedToken, err := base64.URLEncoding.DecodeString(token)
-	if err != nil {
-		return fmt.Errorf("invalid token encoding: %w", err)
+// ValidateTokenWithKeys validates a token against an ordered list of candidate
+// keys (current first, then previous ones), so a key can be rotated while
+// outstanding tokens remain valid. The first key that decrypts the token is
+// used for validation.
+func ValidateTokenWithKeys(keys []string, token, requestedPath string, opts ...Option) error {
+	_, err := ValidateTokenPayload(keys, token, requestedPath, opts...)
+	return err
+}
+
+// ValidateTokenPayload is ValidateTokenWithKeys that also returns the token's
+// payload. The payload is returned whenever the token could be decoded, even
+// if a later check failed, so callers can record which token was refused.
+func ValidateTokenPayload(keys []string, token, requestedPath string, opts ...Option) (*TokenPayload, error) {
+	o := validateOptions{skew: DefaultSkewTolerance}
+	for _, opt := range opts {
+		opt(&o)
 	}
 
-	// Decrypt the token
-	payloadBytes, err := decryptAESGCM(encryptedToken, apiKey)
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("no token keys configured")
+	}
+
+	// Recover the payload with the first matching key
+	var payloadBytes []byte
+	var err error
+	if strings.Contains(token, hmacSeparator) {
+		payloadBytes, err = verifyHMACToken(token, keys)
+	} else {
+		payloadBytes, err = decryptToken(token, keys)
+	}
 	if err != nil {
-		return fmt.Errorf("failed to decrypt token: %w", err)
+		return nil, fmt.Errorf("%w: %w", ErrTokenInvalid, err)
 	}
 
 	// Unmarshal payload
 	var payload TokenPayload
 	if err := json.Unmarshal(payloadBytes, &payload); err != nil {
-		return fmt.Errorf("invalid token payload: %w", err)
+		return nil, fmt.Errorf("%w: invalid token payload: %w", ErrTokenInvalid, err)
 	}
 
-	// Check expiration
-	now := time.Now().Unix()
-	if now > payload.Exp {
-		return fmt.Errorf("token has expired")
+	// Check expiration and issue time, allowing for clock skew with the issuer
+	now := time.Now()
+	if now.After(time.Unix(payload.Exp, 0).Add(o.skew)) {
+		return &payload, ErrTokenExpired
+	}
+	if time.Unix(payload.Iat, 0).After(now.Add(o.skew)) {
+		return &payload, ErrTokenNotYetValid
 	}
 
 	// Check path matches
 	if payload.Path != requestedPath {
-		return fmt.Errorf("token path mismatch: expected %s, got %s", payload.Path, requestedPath)
+		return &payload, fmt.Errorf("%w: expected %s, got %s", ErrTokenPathMismatch, payload.Path, requestedPath)
+	}
+
+	// Check the client is on the network the token was issued for
+	if o.checkIP && payload.IP != "" && !sameNetwork(payload.IP, o.clientIP) {
+		return &payload, ErrTokenIPMismatch
+	}
+
+	// Reject tokens that have already been used (single-use mode)
+	if o.replayStore != nil {
+		if payload.Jti == "" {
+			return &payload, fmt.Errorf("%w: token has no jti for replay protection", ErrTokenInvalid)
+		}
+		if !o.replayStore.MarkUsed(payload.Jti, time.Unix(payload.Exp, 0)) {
+			return &payload, ErrTokenReplayed
+		}
 	}
 
 	// Token is valid
-	return nil
+	return &payload, nil
+}
+
+// sameNetwork reports whether client lies in bound's /24 (IPv4) or /64 (IPv6)
+func sameNetwork(bound, client string) bool {
+	boundIP, clientIP := net.ParseIP(bound), net.ParseIP(client)
+	if boundIP == nil || clientIP == nil {
+		return false
+	}
+	if b4, c4 := boundIP.To4(), clientIP.To4(); b4 != nil || c4 != nil {
+		if b4 == nil || c4 == nil {
+			return false
+		}
+		mask := net.CIDRMask(ipv4BindPrefix, 32)
+		return b4.Mask(mask).Equal(c4.Mask(mask))
+	}
+	mask := net.CIDRMask(ipv6BindPrefix, 128)
+	return boundIP.Mask(mask).Equal(clientIP.Mask(mask))
+}
+
+// decryptToken decodes a SchemeAESGCM token and decrypts it with the first key that works
+func decryptToken(token string, keys []string) ([]byte, error) {
+	// Base64 URL decode
+	encryptedToken, err := base64.URLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token encoding: %w", err)
+	}
+
+	var payloadBytes []byte
+	for _, key := range keys {
+		payloadBytes, err = decryptAESGCM(encryptedToken, key)
+		if err == nil {
+			return payloadBytes, nil
+		}
+	}
+	return nil, fmt.Errorf("failed to decrypt token: %w", err)
+}
+
+// verifyHMACToken checks a SchemeHMAC token's signature against each key
+// and returns the decoded payload
+func verifyHMACToken(token string, keys []string) ([]byte, error) {
+	encodedPayload, encodedSignature, _ := strings.Cut(token, hmacSeparator)
+	signature, err := base64.RawURLEncoding.DecodeString(encodedSignature)
+	if err != nil {
+		return nil, fmt.Errorf("invalid token signature encoding: %w", err)
+	}
+
+	for _, key := range keys {
+		if hmac.Equal(signature, signHMAC(encodedPayload, key)) {
+			payloadBytes, err := base64.RawURLEncoding.DecodeString(encodedPayload)
+			if err != nil {
+				return nil, fmt.Errorf("invalid token encoding: %w", err)
+			}
+			return payloadBytes, nil
+		}
+	}
+	return nil, fmt.Errorf("invalid token signature")
+}
+
+// signHMAC computes the HMAC-SHA256 of the encoded payload with the given key
+func signHMAC(encodedPayload, key string) []byte {
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(encodedPayload))
+	return mac.Sum(nil)
 }
 
 // encryptAESGCM encrypts data using AES-GCM with the given key
@@ -0,0 +1,249 @@
+package timetoken
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testPath = "/instances/1.2.3/download"
+
+var schemes = []TokenScheme{SchemeAESGCM, SchemeHMAC}
+
+// signPayload builds a SchemeHMAC token for a hand-made payload
+func signPayload(t *testing.T, key string, payload TokenPayload) string {
+	t.Helper()
+	data, err := json.Marshal(payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(data)
+	return encoded + hmacSeparator + base64.RawURLEncoding.EncodeToString(signHMAC(encoded, key))
+}
+
+func TestRoundTrip(t *testing.T) {
+	for _, scheme := range schemes {
+		t.Run(string(scheme), func(t *testing.T) {
+			token, err := GenerateTokenWithScheme(scheme, "key", testPath, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// The separator is what tells the schemes apart
+			if got := strings.Contains(token, hmacSeparator); got != (scheme == SchemeHMAC) {
+				t.Fatalf("token %q contains %q = %v", token, hmacSeparator, got)
+			}
+
+			payload, err := ValidateTokenPayload([]string{"key"}, token, testPath)
+			if err != nil {
+				t.Fatalf("ValidateTokenPayload() error = %v", err)
+			}
+			if payload.Path != testPath || payload.Jti == "" || payload.Exp-payload.Iat != 60 {
+				t.Errorf("payload = %+v", payload)
+			}
+
+			if err := ValidateToken("key", token, "/instances/other/download"); !errors.Is(err, ErrTokenPathMismatch) {
+				t.Errorf("other path: error = %v, want ErrTokenPathMismatch", err)
+			}
+			if err := ValidateToken("other-key", token, testPath); !errors.Is(err, ErrTokenInvalid) {
+				t.Errorf("wrong key: error = %v, want ErrTokenInvalid", err)
+			}
+		})
+	}
+}
+
+func TestTamperedToken(t *testing.T) {
+	hmacToken, err := GenerateTokenWithScheme(SchemeHMAC, "key", testPath, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	encodedPayload, signature, _ := strings.Cut(hmacToken, hmacSeparator)
+	forged := signPayload(t, "key", TokenPayload{Path: "/instances/other/download", Exp: time.Now().Add(time.Minute).Unix()})
+	forgedPayload, _, _ := strings.Cut(forged, hmacSeparator)
+
+	aesToken, err := GenerateTokenWithScheme(SchemeAESGCM, "key", testPath, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext, err := base64.URLEncoding.DecodeString(aesToken)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ciphertext[len(ciphertext)-1] ^= 0x01
+
+	flipped := []byte(signature)
+	flipped[0] ^= 0x01
+
+	tests := []struct {
+		name  string
+		token string
+	}{
+		{"hmac signature changed", encodedPayload + hmacSeparator + string(flipped)},
+		{"hmac signature removed", encodedPayload + hmacSeparator},
+		{"hmac payload swapped", forgedPayload + hmacSeparator + signature},
+		{"aes-gcm ciphertext changed", base64.URLEncoding.EncodeToString(ciphertext)},
+		{"not base64", "not a token!"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, err := ValidateTokenPayload([]string{"key"}, tt.token, testPath)
+			if !errors.Is(err, ErrTokenInvalid) {
+				t.Errorf("error = %v, want ErrTokenInvalid", err)
+			}
+			if payload != nil {
+				t.Errorf("payload = %+v, want nil", payload)
+			}
+		})
+	}
+}
+
+func TestRotatedKey(t *testing.T) {
+	for _, scheme := range schemes {
+		t.Run(string(scheme), func(t *testing.T) {
+			token, err := GenerateTokenWithScheme(scheme, "old-key", testPath, time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidateTokenWithKeys([]string{"new-key", "old-key"}, token, testPath); err != nil {
+				t.Errorf("during rotation: error = %v", err)
+			}
+			if err := ValidateTokenWithKeys([]string{"new-key"}, token, testPath); !errors.Is(err, ErrTokenInvalid) {
+				t.Errorf("after rotation: error = %v, want ErrTokenInvalid", err)
+			}
+			if _, err := ValidateTokenPayload(nil, token, testPath); err == nil {
+				t.Error("no keys: want error")
+			}
+		})
+	}
+}
+
+func TestSameNetwork(t *testing.T) {
+	tests := []struct {
+		bound, client string
+		want          bool
+	}{
+		{"203.0.113.7", "203.0.113.7", true},
+		{"203.0.113.7", "203.0.113.250", true},
+		{"203.0.113.7", "203.0.114.7", false},
+		{"203.0.113.7", "::ffff:203.0.113.9", true},
+		{"2001:db8:1:2::1", "2001:db8:1:2:ffff::9", true},
+		{"2001:db8:1:2::1", "2001:db8:1:3::1", false},
+		{"203.0.113.7", "2001:db8::1", false},
+		{"2001:db8::1", "203.0.113.7", false},
+		{"203.0.113.7", "", false},
+		{"not-an-ip", "203.0.113.7", false},
+	}
+	for _, tt := range tests {
+		if got := sameNetwork(tt.bound, tt.client); got != tt.want {
+			t.Errorf("sameNetwork(%q, %q) = %v, want %v", tt.bound, tt.client, got, tt.want)
+		}
+	}
+}
+
+func TestClientIPBinding(t *testing.T) {
+	for _, scheme := range schemes {
+		t.Run(string(scheme), func(t *testing.T) {
+			token, err := GenerateTokenForIP(scheme, "key", testPath, "2001:db8:1:2::1", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := ValidateToken("key", token, testPath, WithClientIP("2001:db8:1:2::99")); err != nil {
+				t.Errorf("same /64: error = %v", err)
+			}
+			if err := ValidateToken("key", token, testPath, WithClientIP("2001:db8:1:3::1")); !errors.Is(err, ErrTokenIPMismatch) {
+				t.Errorf("other /64: error = %v, want ErrTokenIPMismatch", err)
+			}
+			// Without WithClientIP the binding is not enforced
+			if err := ValidateToken("key", token, testPath); err != nil {
+				t.Errorf("unchecked: error = %v", err)
+			}
+		})
+	}
+
+	if _, err := GenerateTokenForIP(SchemeHMAC, "key", testPath, "203.0.113", time.Minute); err == nil {
+		t.Error("GenerateTokenForIP() with an invalid IP: want error")
+	}
+}
+
+func TestSkewTolerance(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name     string
+		exp, iat time.Time
+		skew     time.Duration
+		want     error
+	}{
+		{"expired within skew", now.Add(-20 * time.Second), now.Add(-time.Minute), 30 * time.Second, nil},
+		{"expired beyond skew", now.Add(-40 * time.Second), now.Add(-time.Minute), 30 * time.Second, ErrTokenExpired},
+		{"expired, strict", now.Add(-2 * time.Second), now.Add(-time.Minute), 0, ErrTokenExpired},
+		{"issued ahead within skew", now.Add(time.Minute), now.Add(20 * time.Second), 30 * time.Second, nil},
+		{"issued ahead beyond skew", now.Add(time.Minute), now.Add(40 * time.Second), 30 * time.Second, ErrTokenNotYetValid},
+		{"issued ahead, strict", now.Add(time.Minute), now.Add(2 * time.Second), 0, ErrTokenNotYetValid},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			token := signPayload(t, "key", TokenPayload{Path: testPath, Exp: tt.exp.Unix(), Iat: tt.iat.Unix(), Jti: "jti"})
+			err := ValidateToken("key", token, testPath, WithSkewTolerance(tt.skew))
+			if !errors.Is(err, tt.want) {
+				t.Errorf("error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestReplay(t *testing.T) {
+	store := NewMemoryReplayStore()
+	token, err := GenerateToken("key", testPath, time.Minute)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := ValidateToken("key", token, testPath, WithReplayStore(store)); err != nil {
+		t.Fatalf("first use: error = %v", err)
+	}
+	if err := ValidateToken("key", token, testPath, WithReplayStore(store)); !errors.Is(err, ErrTokenReplayed) {
+		t.Errorf("second use: error = %v, want ErrTokenReplayed", err)
+	}
+	// Without a store the same token may be retried
+	if err := ValidateToken("key", token, testPath); err != nil {
+		t.Errorf("without store: error = %v", err)
+	}
+
+	noJti := signPayload(t, "key", TokenPayload{Path: testPath, Exp: time.Now().Add(time.Minute).Unix()})
+	if err := ValidateToken("key", noJti, testPath, WithReplayStore(store)); !errors.Is(err, ErrTokenInvalid) {
+		t.Errorf("without jti: error = %v, want ErrTokenInvalid", err)
+	}
+}
+
+func TestMemoryReplayStore(t *testing.T) {
+	store := NewMemoryReplayStore()
+	future := time.Now().Add(time.Minute)
+
+	if !store.MarkUsed("a", future) {
+		t.Error("first MarkUsed(a) = false")
+	}
+	if store.MarkUsed("a", future) {
+		t.Error("second MarkUsed(a) = true")
+	}
+
+	// An expired entry no longer blocks reuse, swept or not
+	if !store.MarkUsed("b", time.Now().Add(-time.Second)) {
+		t.Error("first MarkUsed(b) = false")
+	}
+	if !store.MarkUsed("b", future) {
+		t.Error("MarkUsed(b) after expiry = false")
+	}
+	if store.MarkUsed("b", future) {
+		t.Error("MarkUsed(b) again = true")
+	}
+
+	store.MarkUsed("c", time.Now().Add(-time.Second))
+	store.Cleanup()
+	if _, ok := store.entries.Load("c"); ok {
+		t.Error("Cleanup() kept expired entry c")
+	}
+	if _, ok := store.entries.Load("a"); !ok {
+		t.Error("Cleanup() removed live entry a")
+	}
+}
@@ -3,11 +3,13 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log/slog"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/minasoft-technology/gordion-relay/internal/relay"
 )
@@ -16,9 +18,14 @@ func main() {
 	var (
 		configFile = flag.String("config", "config.json", "Path to configuration file")
 		debug      = flag.Bool("debug", false, "Enable debug logging")
+		validate   = flag.Bool("validate", false, "Check the configuration and exit without starting the server")
 	)
 	flag.Parse()
 
+	if *validate {
+		os.Exit(validateConfig(*configFile))
+	}
+
 	// Setup logging
 	logLevel := slog.LevelInfo
 	if *debug {
@@ -56,7 +63,7 @@ func main() {
 	logger := slog.New(handler)
 	slog.SetDefault(logger)
 
-	slog.Info("Starting Gordion Relay Server")
+	slog.Info("Starting Gordion Relay Server", "version", relay.Version)
 
 	// Load configuration
 	cfg, err := relay.LoadConfig(*configFile)
@@ -72,6 +79,7 @@ func main() {
 	var server interface {
 		Start(context.Context) error
 		Stop()
+		ReloadHospitals([]relay.HospitalConfig)
 	}
 
 	switch cfg.Mode {
@@ -86,6 +94,12 @@ func main() {
 		os.Exit(1)
 	}
 
+	shutdownTracing, err := relay.SetupTracing(ctx, cfg.Tracing)
+	if err != nil {
+		slog.Error("Failed to set up tracing", "error", err)
+		os.Exit(1)
+	}
+
 	if err := server.Start(ctx); err != nil {
 		slog.Error("Failed to start relay server", "error", err, "mode", cfg.Mode)
 		os.Exit(1)
@@ -93,12 +107,67 @@ func main() {
 
 	slog.Info("Relay server started successfully", "mode", cfg.Mode)
 
-	// Wait for interrupt signal
+	// Wait for interrupt signal; SIGHUP reloads hospital tokens in place
 	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM)
-	<-sigChan
+	signal.Notify(sigChan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
+	for sig := range sigChan {
+		if sig != syscall.SIGHUP {
+			break
+		}
+
+		slog.Info("SIGHUP received, reloading hospital configuration", "config", *configFile)
+		newCfg, err := relay.LoadConfig(*configFile)
+		if err != nil {
+			slog.Error("Config reload failed, keeping current configuration", "error", err)
+			continue
+		}
+		server.ReloadHospitals(newCfg.Hospitals)
+	}
 
 	slog.Info("Shutdown signal received, stopping server...")
 	server.Stop()
+
+	// Flush spans recorded during shutdown
+	flushCtx, flushCancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer flushCancel()
+	if err := shutdownTracing(flushCtx); err != nil {
+		slog.Warn("Failed to flush traces", "error", err)
+	}
 	slog.Info("Relay server stopped")
-}
\ No newline at end of file
+}
+
+// validateConfig loads and checks the configuration file without binding
+// ports or connecting to NATS, prints a report and returns the exit code
+func validateConfig(path string) int {
+	cfg, err := relay.LoadConfig(path)
+	if err != nil {
+		fmt.Printf("%s: %v\n", path, err)
+		return 1
+	}
+	if err := cfg.TLS.CheckFiles(); err != nil {
+		fmt.Printf("%s: invalid TLS files:\n  - %v\n", path, err)
+		return 1
+	}
+	if err := cfg.Pages.CheckFiles(); err != nil {
+		fmt.Printf("%s: invalid page templates:\n  - %v\n", path, err)
+		return 1
+	}
+
+	fmt.Printf("%s: configuration is valid\n", path)
+	fmt.Printf("  mode:      %s\n", cfg.Mode)
+	fmt.Printf("  domains:   %s\n", strings.Join(cfg.ApexDomains(), ", "))
+	fmt.Printf("  listen:    %s\n", cfg.ListenAddr)
+	fmt.Printf("  hospitals: %d\n", len(cfg.Hospitals))
+	switch {
+	case !cfg.TLS.Enabled:
+		fmt.Println("  tls:       disabled (terminated upstream)")
+	case cfg.TLS.AutoCert:
+		fmt.Println("  tls:       autocert")
+	default:
+		fmt.Printf("  tls:       %s\n", cfg.TLS.CertFile)
+	}
+	if cfg.NATS != nil {
+		fmt.Println("  nats:      configured (not contacted)")
+	}
+	return 0
+}
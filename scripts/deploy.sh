@@ -70,7 +70,7 @@ if [ "$BUILD_IMAGE" = true ]; then
         FULL_IMAGE="$IMAGE_NAME:$IMAGE_TAG"
     fi
 
-    docker build -t "$FULL_IMAGE" .
+    docker build --build-arg VERSION="$IMAGE_TAG" -t "$FULL_IMAGE" .
 
     if [ -n "$REGISTRY" ]; then
         echo -e "${BLUE}📤 Pushing image to registry...${NC}"